@@ -0,0 +1,536 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jjboykin/gator/internal/config"
+	"github.com/jjboykin/gator/internal/database"
+	"github.com/jjboykin/gator/internal/logging"
+	"github.com/jjboykin/gator/internal/ratelimit"
+	"github.com/jjboykin/gator/internal/schedule"
+)
+
+func handlerAggregator(s *state, cmd command) error {
+	fs := flag.NewFlagSet("agg", flag.ContinueOnError)
+	metricsAddr := fs.String("metrics-addr", "", "serve Prometheus metrics on this address, e.g. :9100")
+	daemon := fs.Bool("daemon", false, "run as a long-lived service with a pidfile, health checks and config reload on SIGHUP")
+	pidFile := fs.String("pidfile", defaultPidFile(), "pidfile written in daemon mode")
+	healthAddr := fs.String("health-addr", "127.0.0.1:8081", "serve /healthz and /readyz on this address in daemon mode")
+	concurrency := fs.Int("concurrency", 1, "fetch up to this many due feeds in parallel each cycle")
+	args, err := parseCommandFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		return errors.New("time_between_reqs not given")
+	}
+
+	if len(args) > 1 {
+		return errors.New("too many command args given")
+	}
+
+	time_between_reqs := args[0]
+	timeBetweenRequests, err := time.ParseDuration(time_between_reqs)
+	if err != nil {
+		return err
+	}
+
+	health := &aggHealth{interval: timeBetweenRequests}
+
+	if *daemon && *pidFile != "" {
+		if err := writePidFile(*pidFile); err != nil {
+			return err
+		}
+		defer os.Remove(*pidFile)
+	}
+
+	// Metrics and health checks share a server when given the same address.
+	muxes := make(map[string]*http.ServeMux)
+	muxFor := func(addr string) *http.ServeMux {
+		if muxes[addr] == nil {
+			muxes[addr] = http.NewServeMux()
+		}
+		return muxes[addr]
+	}
+	if *metricsAddr != "" {
+		s.metrics.watchQueue(s.db, timeBetweenRequests)
+		muxFor(*metricsAddr).Handle("GET /metrics", s.metrics.registry.Handler())
+	}
+	if *daemon && *healthAddr != "" {
+		mux := muxFor(*healthAddr)
+		mux.HandleFunc("GET /healthz", health.handleHealthz)
+		mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+			health.handleReadyz(w, r, s.dbConn)
+		})
+	}
+	for addr, mux := range muxes {
+		server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+		defer server.Close()
+		// The logger is loaded on use so errors after a reload go to the
+		// logger the new config built, not the one reloadConfig closed.
+		go func() {
+			s.logger().Info("serving http", "addr", addr)
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.logger().Error("http server stopped", "addr", addr, "error", err)
+			}
+		}()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	hup := make(chan os.Signal, 1)
+	if *daemon {
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+	}
+
+	s.logger().Info("collecting feeds", "interval", timeBetweenRequests, "concurrency", *concurrency, "daemon", *daemon)
+
+	summaryLevel := slog.LevelDebug
+	if *daemon {
+		summaryLevel = slog.LevelInfo
+	}
+
+	// Cycles run in their own goroutine so that a shutdown signal can be
+	// noticed while one is in flight; the in-flight cycle is then allowed
+	// to finish (drained) before we return.
+	var cycleDone chan struct{}
+	reloadPending := false
+	startCycle := func() {
+		cycleDone = make(chan struct{})
+		done := cycleDone
+		go func() {
+			defer close(done)
+			n := health.start()
+			start := time.Now()
+			summary, err := scrapeFeeds(context.WithoutCancel(ctx), s, *concurrency)
+			retryAlertDeliveries(context.WithoutCancel(ctx), s)
+			sendDueDigests(context.WithoutCancel(ctx), s)
+			health.finish(err)
+			if err != nil {
+				s.logger().Error("scrape failed", "cycle", n, "error", err)
+			}
+			s.logger().Log(ctx, summaryLevel, "cycle complete",
+				"cycle", n,
+				"duration", time.Since(start),
+				"feeds", summary.Feeds,
+				"inserted", summary.Inserted,
+				"duplicates", summary.Duplicates,
+				"failed", summary.Failed,
+			)
+		}()
+	}
+
+	ticker := time.NewTicker(timeBetweenRequests)
+	defer ticker.Stop()
+	startCycle()
+	for {
+		select {
+		case <-ctx.Done():
+			// Restore default signal handling so a second signal kills us.
+			stop()
+			if cycleDone != nil {
+				s.logger().Info("shutting down, waiting for in-flight cycle")
+				<-cycleDone
+			}
+			s.logger().Info("aggregator stopped")
+			return nil
+		case <-hup:
+			reloadPending = true
+		case <-cycleDone:
+			cycleDone = nil
+		case <-ticker.C:
+			if cycleDone != nil {
+				s.logger().Warn("previous cycle still running, skipping tick")
+				continue
+			}
+			startCycle()
+			continue
+		}
+
+		if reloadPending && cycleDone == nil {
+			reloadPending = false
+			if err := reloadConfig(s); err != nil {
+				s.logger().Error("config reload failed", "error", err)
+			} else {
+				s.logger().Info("config reloaded", "path", s.configPtr.Path())
+			}
+		}
+	}
+}
+
+// scrapeSummary counts what happened during one aggregation cycle.
+type scrapeSummary struct {
+	Feeds      int
+	Inserted   int
+	Duplicates int
+	Failed     int
+}
+
+func (s *scrapeSummary) add(r ingestResult) {
+	s.Inserted += r.Inserted
+	s.Duplicates += r.Duplicates
+	s.Failed += r.Failed
+}
+
+// scrapeFeeds fetches up to concurrency due feeds in parallel. Hosts are
+// protected from the parallelism by the limiter in the fetcher.
+func scrapeFeeds(ctx context.Context, s *state, concurrency int) (scrapeSummary, error) {
+	var (
+		summary scrapeSummary
+		errs    []error
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+	)
+	for range max(concurrency, 1) {
+		feed, err := claimNextFeed(ctx, s)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Nothing (more) is due yet.
+			break
+		}
+		if err != nil {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+			break
+		}
+
+		summary.Feeds++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ingested, err := scrapeFeed(ctx, s, feed)
+			mu.Lock()
+			defer mu.Unlock()
+			summary.add(ingested)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return summary, errors.Join(errs...)
+}
+
+// claimNextFeed returns the feed that is most overdue and pushes its next
+// fetch back, so that it isn't claimed again while it is being fetched.
+func claimNextFeed(ctx context.Context, s *state) (database.Feed, error) {
+	feed, err := s.db.GetNextFeedToFetch(ctx, time.Now())
+	if err != nil {
+		return database.Feed{}, err
+	}
+
+	// Until the fetch succeeds and we can do better, retry after the
+	// current interval so a failing feed isn't hammered every tick.
+	interval := feedInterval(feed)
+	now := time.Now()
+	nullableTimeSQL := sql.NullTime{
+		Time:  now,
+		Valid: true,
+	}
+
+	feedToMarkParams := database.MarkFeedFetchedParams{
+		ID:            feed.ID,
+		LastFetchedAt: nullableTimeSQL,
+		UpdatedAt:     nullableTimeSQL.Time,
+		NextFetchAt:   sql.NullTime{Time: now.Add(interval), Valid: true},
+	}
+
+	if err := s.db.MarkFeedFetched(ctx, feedToMarkParams); err != nil {
+		return database.Feed{}, err
+	}
+	return feed, nil
+}
+
+// scrapeFeed fetches and ingests one claimed feed and schedules its next
+// fetch.
+func scrapeFeed(ctx context.Context, s *state, feed database.Feed) (ingestResult, error) {
+	logger := s.logger().With("feed_id", feed.ID, "feed_url", feed.Url)
+	interval := feedInterval(feed)
+
+	start := time.Now()
+	fetchedFeed, result, err := fetchFeed(ctx, s.fetcher(), feed.Url)
+	s.metrics.observeFetch(result, time.Since(start), err)
+	if err != nil {
+		logger.Warn("fetch failed",
+			"status", result.StatusCode,
+			"duration", time.Since(start),
+			"error", err,
+		)
+		if result.StatusCode == http.StatusGone {
+			if err := markFeedGone(ctx, s, feed); err != nil {
+				logger.Error("couldn't mark feed as gone", "error", err)
+			} else {
+				logger.Warn("feed is gone; polling stopped")
+			}
+		}
+		deferHostFeeds(ctx, s, feed, result, err, logger)
+		return ingestResult{}, err
+	}
+
+	if err := trackRedirects(ctx, s, feed, result, logger); err != nil {
+		logger.Error("couldn't record feed redirect", "error", err)
+	}
+
+	ingested := ingestFeed(ctx, s, feed, fetchedFeed, logger)
+
+	hints := fetchedFeed.scheduleHints()
+	pushed, err := s.db.HasActiveWebSubSubscription(ctx, database.HasActiveWebSubSubscriptionParams{
+		FeedID: feed.ID,
+		Now:    time.Now(),
+	})
+	if err != nil {
+		logger.Warn("couldn't check websub subscription", "error", err)
+	}
+	switch {
+	case feed.FetchIntervalOverrideSeconds.Valid:
+	case pushed:
+		// The hub pushes new content to us; polling is only a safety net.
+		interval = scheduleBounds(s).Max
+	default:
+		interval = schedule.Interval(ingested.published, hints, scheduleBounds(s), interval)
+	}
+	nextFetch := schedule.Next(time.Now(), interval, hints)
+	err = s.db.ScheduleFeed(ctx, database.ScheduleFeedParams{
+		ID:                   feed.ID,
+		NextFetchAt:          sql.NullTime{Time: nextFetch, Valid: true},
+		FetchIntervalSeconds: int32(interval / time.Second),
+		UpdatedAt:            time.Now(),
+	})
+	if err != nil {
+		logger.Error("couldn't schedule feed", "error", err)
+	}
+
+	logger.Info("feed fetched",
+		"status", result.StatusCode,
+		"bytes", result.Bytes,
+		"duration", time.Since(start),
+		"items", len(fetchedFeed.Channel.Item),
+		"inserted", ingested.Inserted,
+		"duplicates", ingested.Duplicates,
+		"failed", ingested.Failed,
+		"interval", interval,
+		"next_fetch", nextFetch,
+	)
+
+	return ingested, nil
+}
+
+// deferHostFeeds postpones every feed on the host that answered a failed
+// fetch if the host asked us to back off, or was already deferred. Only
+// feeds on exactly that host are postponed, as the limiter defers only
+// that host: subdomains sharing a host_limits rule may be run by
+// different machines.
+func deferHostFeeds(ctx context.Context, s *state, feed database.Feed, result fetchResult, fetchErr error, logger *slog.Logger) {
+	answered := result.FinalURL
+	if answered == "" {
+		answered = feed.Url
+	}
+	u, err := url.Parse(answered)
+	if err != nil {
+		return
+	}
+	host := strings.ToLower(u.Hostname())
+
+	var deferral *ratelimit.DeferredError
+	if !errors.As(fetchErr, &deferral) {
+		deferral = s.limiter.Deferral(host)
+	}
+	if deferral == nil {
+		return
+	}
+
+	deferred, err := s.db.DeferFeedsOnHost(ctx, database.DeferFeedsOnHostParams{
+		Until: deferral.Until,
+		Now:   time.Now(),
+		Host:  host,
+	})
+	if err != nil {
+		logger.Error("couldn't defer feeds", "host", host, "error", err)
+		return
+	}
+	if deferred > 0 {
+		logger.Warn("host asked to back off; feeds deferred", "host", host, "until", deferral.Until, "feeds", deferred)
+	}
+}
+
+// feedInterval is the polling interval currently in effect for feed.
+func feedInterval(feed database.Feed) time.Duration {
+	if feed.FetchIntervalOverrideSeconds.Valid {
+		return time.Duration(feed.FetchIntervalOverrideSeconds.Int32) * time.Second
+	}
+	return time.Duration(feed.FetchIntervalSeconds) * time.Second
+}
+
+func scheduleBounds(s *state) schedule.Bounds {
+	return schedule.Bounds{
+		Min: time.Duration(s.configPtr.MinFetchInterval),
+		Max: time.Duration(s.configPtr.MaxFetchInterval),
+	}
+}
+
+// reloadConfig re-reads the config with the original command line
+// overrides and swaps in a logger built from it.
+func reloadConfig(s *state) error {
+	cfg, err := config.Load(s.configOpts)
+	if err != nil {
+		return err
+	}
+	logger, closer, err := logging.New(logOptions(&cfg))
+	if err != nil {
+		return err
+	}
+	client, err := newFetcher(&cfg, s.limiter)
+	if err != nil {
+		closer.Close()
+		return err
+	}
+	s.limiter.SetLimits(hostLimits(&cfg))
+
+	*s.configPtr = cfg
+	s.fetcherPtr.Store(client)
+	s.loggerPtr.Store(logger)
+	s.logCloser.Close()
+	s.logCloser = closer
+	return nil
+}
+
+// aggHealth tracks aggregation cycles for the health endpoints.
+type aggHealth struct {
+	interval time.Duration
+
+	mu          sync.Mutex
+	cycles      int
+	inFlight    bool
+	lastStart   time.Time
+	lastSuccess time.Time
+	lastError   string
+}
+
+func (h *aggHealth) start() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cycles++
+	h.inFlight = true
+	h.lastStart = time.Now()
+	return h.cycles
+}
+
+func (h *aggHealth) finish(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.inFlight = false
+	if err != nil {
+		h.lastError = err.Error()
+		return
+	}
+	h.lastSuccess = time.Now()
+	h.lastError = ""
+}
+
+type healthReport struct {
+	Status      string     `json:"status"`
+	Cycles      int        `json:"cycles"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	Database    string     `json:"database,omitempty"`
+}
+
+func (h *aggHealth) report() healthReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r := healthReport{Status: "ok", Cycles: h.cycles, LastError: h.lastError}
+	if !h.lastSuccess.IsZero() {
+		last := h.lastSuccess
+		r.LastSuccess = &last
+	}
+	return r
+}
+
+// handleHealthz reports liveness: it only fails when a cycle appears to
+// be stuck.
+func (h *aggHealth) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	report := h.report()
+	status := http.StatusOK
+
+	h.mu.Lock()
+	stuck := h.inFlight && time.Since(h.lastStart) > 10*h.interval+time.Minute
+	h.mu.Unlock()
+	if stuck {
+		report.Status = "cycle stuck"
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+// handleReadyz reports readiness: the database must be reachable and a
+// cycle must have succeeded recently.
+func (h *aggHealth) handleReadyz(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	report := h.report()
+	status := http.StatusOK
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	report.Database = "ok"
+	if err := db.PingContext(ctx); err != nil {
+		report.Database = err.Error()
+		report.Status = "database unreachable"
+		status = http.StatusServiceUnavailable
+	} else if report.LastSuccess == nil || time.Since(*report.LastSuccess) > 3*h.interval {
+		report.Status = "no recent successful cycle"
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func defaultPidFile() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "gator-agg.pid")
+}
+
+// writePidFile records our pid, refusing to start if the pidfile belongs
+// to another live process.
+func writePidFile(path string) error {
+	if data, err := os.ReadFile(path); err == nil {
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err == nil && pid != os.Getpid() && processAlive(pid) {
+			return fmt.Errorf("agg already running with pid %d (pidfile %s)", pid, path)
+		}
+	}
+	return os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644)
+}
+
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jjboykin/gator/internal/config"
+	"github.com/jjboykin/gator/internal/database"
+	"github.com/lib/pq"
+)
+
+// fakeDB stands in for Postgres in handler tests. Queries are told apart
+// by the name sqlc puts in their first line; a query without a result set
+// up returns no rows, and every query is recorded with its arguments.
+type fakeDB struct {
+	mu      sync.Mutex
+	results map[string]func(args []driver.Value) []any
+	calls   []fakeCall
+}
+
+type fakeCall struct {
+	name string
+	args []driver.Value
+}
+
+// newTestState returns a state whose database is db.
+func newTestState(t *testing.T, db *fakeDB) *state {
+	t.Helper()
+	conn := sql.OpenDB(db)
+	t.Cleanup(func() { conn.Close() })
+	s := &state{db: database.New(conn), configPtr: &config.Config{}, dbConn: conn}
+	s.loggerPtr.Store(slog.New(slog.DiscardHandler))
+	return s
+}
+
+// on makes the query name return rows, each a row struct or a single
+// value, whatever its arguments.
+func (db *fakeDB) on(name string, rows ...any) {
+	db.onArgs(name, func([]driver.Value) []any { return rows })
+}
+
+// onArgs makes the query name return the rows f picks for its arguments.
+// For an exec, the number of rows is the number of rows affected.
+func (db *fakeDB) onArgs(name string, f func(args []driver.Value) []any) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.results == nil {
+		db.results = make(map[string]func([]driver.Value) []any)
+	}
+	db.results[name] = f
+}
+
+// called returns the arguments of each run of the query name.
+func (db *fakeDB) called(name string) [][]driver.Value {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var runs [][]driver.Value
+	for _, call := range db.calls {
+		if call.name == name {
+			runs = append(runs, call.args)
+		}
+	}
+	return runs
+}
+
+func (db *fakeDB) run(query string, args []driver.NamedValue) ([][]driver.Value, error) {
+	first, _, _ := strings.Cut(query, "\n")
+	fields := strings.Fields(first)
+	if len(fields) < 3 || fields[1] != "name:" {
+		return nil, fmt.Errorf("fakedb: query without a name: %q", first)
+	}
+	name := fields[2]
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	db.mu.Lock()
+	db.calls = append(db.calls, fakeCall{name: name, args: values})
+	f := db.results[name]
+	db.mu.Unlock()
+	if f == nil {
+		return nil, nil
+	}
+	var rows [][]driver.Value
+	for _, row := range f(values) {
+		if err, ok := row.(error); ok {
+			return nil, err
+		}
+		values, err := rowValues(row)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, values)
+	}
+	return rows, nil
+}
+
+// rowValues turns a row struct of the database package into its columns,
+// in the order sqlc scans them, and any other value into a single column.
+func rowValues(row any) ([]driver.Value, error) {
+	v := reflect.ValueOf(row)
+	if v.Kind() != reflect.Struct || v.Type().PkgPath() != reflect.TypeFor[database.Queries]().PkgPath() {
+		value, err := columnValue(row)
+		return []driver.Value{value}, err
+	}
+	var values []driver.Value
+	for i := range v.NumField() {
+		value, err := columnValue(v.Field(i).Interface())
+		if err != nil {
+			return nil, fmt.Errorf("fakedb: %s.%s: %w", v.Type().Name(), v.Type().Field(i).Name, err)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func columnValue(v any) (driver.Value, error) {
+	if values, ok := v.([]string); ok {
+		return pq.Array(values).Value()
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
+// The driver side: fakeDB is its own connector, and every connection
+// runs queries on it.
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{db}, nil }
+func (db *fakeDB) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fakedb: use sql.OpenDB")
+}
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakedb: prepared statements aren't supported")
+}
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+func (c fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := c.db.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	columns := 0
+	if len(rows) > 0 {
+		columns = len(rows[0])
+	}
+	return &fakeRows{columns: columns, rows: rows}, nil
+}
+
+func (c fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	rows, err := c.db.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(len(rows)), nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	columns int
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	names := make([]string, r.columns)
+	for i := range names {
+		names[i] = fmt.Sprintf("column%d", i+1)
+	}
+	return names
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jjboykin/gator/internal/charset"
+	"github.com/jjboykin/gator/internal/fetcher"
+	"github.com/jjboykin/gator/internal/schedule"
+)
+
+type RSSFeed struct {
+	// Format and Encoding describe the document as fetched: "rss" or
+	// "atom", and the character encoding it was converted from.
+	Format   string `xml:"-"`
+	Encoding string `xml:"-"`
+	// Repairs lists what had to be fixed to parse a malformed document.
+	Repairs []feedRepair `xml:"-"`
+
+	Channel struct {
+		// Links must precede Link: encoding/xml hands an element to the
+		// first field whose name matches, and <atom:link> would otherwise
+		// land in Link.
+		Links []AtomLink `xml:"http://www.w3.org/2005/Atom link"`
+
+		Title       string    `xml:"title"`
+		Link        string    `xml:"link"`
+		Description string    `xml:"description"`
+		Item        []RSSItem `xml:"item"`
+
+		// Polling hints. Kept as strings so a malformed hint can't fail the whole feed.
+		TTL             string   `xml:"ttl"`
+		SkipHours       []string `xml:"skipHours>hour"`
+		SkipDays        []string `xml:"skipDays>day"`
+		UpdatePeriod    string   `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+		UpdateFrequency string   `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+	} `xml:"channel"`
+}
+
+// AtomLink is an <atom:link>, used by RSS feeds to advertise their own
+// URL and WebSub hubs.
+type AtomLink struct {
+	Rel    string `xml:"rel,attr"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type RSSItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	PubDate     string `xml:"pubDate"`
+	GUID        string `xml:"guid"`
+
+	// Content is the full article body, where Description is often only a teaser.
+	Content    string   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Creator    string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Author     string   `xml:"author"`
+	Categories []string `xml:"category"`
+	Comments   string   `xml:"comments"`
+
+	Enclosures []RSSEnclosure `xml:"enclosure"`
+
+	// iTunes podcast tags.
+	ITunesDuration string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	ITunesEpisode  string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd episode"`
+	ITunesSeason   string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd season"`
+	ITunesExplicit string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd explicit"`
+	ITunesImage    struct {
+		Href string `xml:"href,attr"`
+	} `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+}
+
+// RSSEnclosure is a media file attached to an item, e.g. a podcast episode.
+type RSSEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// fetchResult describes the HTTP side of a feed fetch, for logging and metrics.
+type fetchResult struct {
+	StatusCode int
+	Bytes      int64
+	Header     http.Header
+
+	// FinalURL is where the feed was fetched from after following
+	// Redirects.
+	FinalURL  string
+	Redirects []fetcher.Redirect
+}
+
+// errFeedParse marks fetchFeed errors caused by an unparseable response body.
+var errFeedParse = errors.New("couldn't parse feed")
+
+func isFeedParseError(err error) bool {
+	return errors.Is(err, errFeedParse)
+}
+
+// pubDateLayouts are the date formats seen in the wild, most common first.
+var pubDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006/01/02",
+	"02 Jan 2006",
+	"01/02/2006 15:04:05 MST",
+}
+
+// parsePubDate parses an item date, returning the zero time when no known
+// layout matches.
+func parsePubDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range pubDateLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed
+		}
+	}
+	return time.Time{}
+}
+
+// scheduleHints extracts the polling hints published by the feed.
+func (f *RSSFeed) scheduleHints() schedule.Hints {
+	var hints schedule.Hints
+	if minutes, err := strconv.Atoi(strings.TrimSpace(f.Channel.TTL)); err == nil && minutes > 0 {
+		hints.TTL = time.Duration(minutes) * time.Minute
+	}
+	for _, hour := range f.Channel.SkipHours {
+		if h, err := strconv.Atoi(strings.TrimSpace(hour)); err == nil && h >= 0 && h <= 24 {
+			hints.SkipHours = append(hints.SkipHours, h)
+		}
+	}
+	for _, day := range f.Channel.SkipDays {
+		if d, ok := schedule.ParseWeekday(day); ok {
+			hints.SkipDays = append(hints.SkipDays, d)
+		}
+	}
+	frequency, _ := strconv.Atoi(strings.TrimSpace(f.Channel.UpdateFrequency))
+	hints.UpdatePeriod = schedule.UpdatePeriod(f.Channel.UpdatePeriod, frequency)
+	return hints
+}
+
+func fetchFeed(ctx context.Context, client *fetcher.Client, feedURL string) (*RSSFeed, fetchResult, error) {
+	var result fetchResult
+
+	header := http.Header{}
+	header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, text/xml;q=0.9, */*;q=0.8")
+	resp, err := client.Get(ctx, feedURL, header)
+	if resp != nil {
+		result.StatusCode = resp.StatusCode
+		result.Bytes = int64(len(resp.Body))
+		result.Header = resp.Header
+		result.FinalURL = resp.URL
+		result.Redirects = resp.Redirects
+	}
+	if err != nil {
+		return nil, result, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, result, fmt.Errorf("unexpected HTTP status: %d", resp.StatusCode)
+	}
+
+	feed, err := parseFeed(resp.Body, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, result, fmt.Errorf("failed to fetch feed from %s: %w", feedURL, err)
+	}
+
+	return feed, result, nil
+}
+
+// parseFeed decodes an RSS or Atom feed document. Atom feeds are converted
+// to the RSS structures so the rest of gator only deals with one shape.
+// contentType is the HTTP Content-Type of the document, if known, which
+// takes part in detecting its character encoding. Errors wrap errFeedParse.
+func parseFeed(data []byte, contentType string) (*RSSFeed, error) {
+	encoding := charset.Detect(data, contentType)
+	converted, err := charset.ToUTF8(data, encoding)
+	if err != nil {
+		// Most likely a mislabelled UTF-8 feed; try it as such.
+		converted, _ = charset.ToUTF8(data, "utf-8")
+	}
+	data = converted
+
+	feed, err := decodeFeed(data, false)
+	if err != nil {
+		var repairs []feedRepair
+		feed, repairs, err = decodeFeedLenient(data, err)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errFeedParse, err)
+		}
+		feed.Repairs = repairs
+	}
+	feed.Encoding = encoding
+	feed.Format = "rss"
+	if isAtomFeed(data) {
+		feed.Format = "atom"
+	}
+
+	// Titles are plain text but often carry entities. Descriptions and
+	// content are HTML; they are parsed, not unescaped, by the sanitizer.
+	for i := range feed.Channel.Item {
+		feed.Channel.Item[i].Title = html.UnescapeString(feed.Channel.Item[i].Title)
+	}
+	feed.Channel.Title = html.UnescapeString(feed.Channel.Title)
+
+	return feed, nil
+}
+
+// decodeFeed decodes data as Atom or RSS, depending on its root element.
+func decodeFeed(data []byte, lenient bool) (*RSSFeed, error) {
+	if isAtomFeed(data) {
+		var atom atomFeed
+		if err := unmarshalXML(data, &atom, lenient); err != nil {
+			return nil, err
+		}
+		return atom.toRSS(), nil
+	}
+	feed := &RSSFeed{}
+	if err := unmarshalXML(data, feed, lenient); err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
+
+// isAtomFeed reports whether the document element of data is an Atom <feed>.
+func isAtomFeed(data []byte) bool {
+	root := rootElement(data)
+	return root.Space == atomNamespace && root.Local == "feed"
+}
+
+// rootElement returns the name of the document element of data.
+func rootElement(data []byte) xml.Name {
+	decoder := newXMLDecoder(data, true)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return xml.Name{}
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name
+		}
+	}
+}
+
+// unmarshalXML decodes a document that has already been converted to
+// UTF-8. In lenient mode the decoder accepts HTML entities, unquoted
+// attributes and mismatched tags.
+func unmarshalXML(data []byte, v any, lenient bool) error {
+	return newXMLDecoder(data, lenient).Decode(v)
+}
+
+// lenientAutoClose lists the HTML elements that are never closed, except
+// <link>, which is an RSS element with content.
+var lenientAutoClose = func() []string {
+	var names []string
+	for _, name := range xml.HTMLAutoClose {
+		if name != "link" {
+			names = append(names, name)
+		}
+	}
+	return names
+}()
+
+func newXMLDecoder(data []byte, lenient bool) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// The document is UTF-8 by now, whatever its declaration says.
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	if lenient {
+		decoder.Strict = false
+		decoder.AutoClose = lenientAutoClose
+		decoder.Entity = xml.HTMLEntity
+	}
+	return decoder
+}
+
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+type atomFeed struct {
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle"`
+	Links    []AtomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID         string     `xml:"id"`
+	Title      string     `xml:"title"`
+	Links      []AtomLink `xml:"link"`
+	Summary    atomText   `xml:"summary"`
+	Content    atomText   `xml:"content"`
+	Published  string     `xml:"published"`
+	Updated    string     `xml:"updated"`
+	Authors    []string   `xml:"author>name"`
+	Categories []struct {
+		Term string `xml:"term,attr"`
+	} `xml:"category"`
+}
+
+// atomText is an Atom text construct. XHTML content is wrapped in a <div>
+// whose markup is kept as is; text and html content are character data.
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+func (t atomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.Inner)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+func (a *atomFeed) toRSS() *RSSFeed {
+	feed := &RSSFeed{}
+	feed.Channel.Title = a.Title
+	feed.Channel.Description = a.Subtitle
+	feed.Channel.Links = a.Links
+	feed.Channel.Link = atomAlternate(a.Links)
+
+	for _, entry := range a.Entries {
+		item := RSSItem{
+			Title:       entry.Title,
+			Link:        atomAlternate(entry.Links),
+			Description: entry.Summary.String(),
+			Content:     entry.Content.String(),
+			PubDate:     entry.Published,
+			GUID:        entry.ID,
+			Author:      strings.Join(entry.Authors, ", "),
+		}
+		if item.PubDate == "" {
+			item.PubDate = entry.Updated
+		}
+		for _, category := range entry.Categories {
+			if category.Term != "" {
+				item.Categories = append(item.Categories, category.Term)
+			}
+		}
+		for _, link := range entry.Links {
+			switch link.Rel {
+			case "replies":
+				if item.Comments == "" {
+					item.Comments = link.Href
+				}
+			case "enclosure":
+				item.Enclosures = append(item.Enclosures, RSSEnclosure{URL: link.Href, Type: link.Type, Length: link.Length})
+			}
+		}
+		feed.Channel.Item = append(feed.Channel.Item, item)
+	}
+	return feed
+}
+
+// atomAlternate returns the first alternate link; a link without rel is an
+// alternate link.
+func atomAlternate(links []AtomLink) string {
+	for _, link := range links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+// author returns the author of item, preferring dc:creator, which
+// unlike <author> is not required to be an email address.
+func (item *RSSItem) author() string {
+	if item.Creator != "" {
+		return strings.TrimSpace(item.Creator)
+	}
+	return strings.TrimSpace(item.Author)
+}
+
+// categories returns the item's distinct, non-empty categories. The result
+// is never nil, as posts.categories is NOT NULL.
+func (item *RSSItem) categories() []string {
+	out := []string{}
+	seen := make(map[string]bool)
+	for _, category := range item.Categories {
+		category = strings.TrimSpace(html.UnescapeString(category))
+		if category == "" || seen[category] {
+			continue
+		}
+		seen[category] = true
+		out = append(out, category)
+	}
+	return out
+}
+
+// links returns the href of every channel <atom:link> with the given rel.
+func (f *RSSFeed) links(rel string) []string {
+	var hrefs []string
+	for _, link := range f.Channel.Links {
+		if strings.EqualFold(link.Rel, rel) && link.Href != "" {
+			hrefs = append(hrefs, link.Href)
+		}
+	}
+	return hrefs
+}
+
+// isPodcastEpisode reports whether the item carries any iTunes episode tags.
+func (item *RSSItem) isPodcastEpisode() bool {
+	return item.ITunesDuration != "" || item.ITunesEpisode != "" || item.ITunesSeason != "" ||
+		item.ITunesExplicit != "" || item.ITunesImage.Href != ""
+}
+
+// parseITunesDuration parses an itunes:duration, which is either a number
+// of seconds or [HH:]MM:SS.
+func parseITunesDuration(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	var total int
+	for _, part := range strings.Split(value, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		total = total*60 + n
+	}
+	return time.Duration(total) * time.Second, true
+}
+
+// parseITunesExplicit accepts the yes/no, true/false and explicit/clean
+// spellings used by podcast feeds.
+func parseITunesExplicit(value string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes", "true", "explicit":
+		return true, true
+	case "no", "false", "clean":
+		return false, true
+	}
+	return false, false
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jjboykin/gator/internal/database"
+)
+
+// permanentMoveThreshold is how many fetches in a row must be permanently
+// redirected to the same URL before the feed's stored URL is updated. A
+// single 301 can be a misconfiguration that is reverted the next day.
+const permanentMoveThreshold = 3
+
+// lookupFeed finds a feed by its current URL or by a URL it used to have.
+func lookupFeed(ctx context.Context, s *state, url string) (database.Feed, error) {
+	feed, err := s.db.GetFeedByURL(ctx, url)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s.db.GetFeedByAlias(ctx, url)
+	}
+	return feed, err
+}
+
+// trackRedirects records a successful fetch's redirect chain and moves the
+// feed to its new URL once it has been permanently redirected there
+// permanentMoveThreshold times in a row. The old URL is kept as an alias.
+func trackRedirects(ctx context.Context, s *state, feed database.Feed, result fetchResult, logger *slog.Logger) error {
+	permanent := len(result.Redirects) > 0
+	for _, hop := range result.Redirects {
+		permanent = permanent && hop.Permanent()
+	}
+
+	if !permanent || result.FinalURL == "" || result.FinalURL == feed.Url {
+		if feed.RedirectCount == 0 {
+			return nil
+		}
+		return s.db.RecordFeedRedirect(ctx, database.RecordFeedRedirectParams{
+			ID:        feed.ID,
+			UpdatedAt: time.Now(),
+		})
+	}
+
+	count := int32(1)
+	if feed.RedirectUrl.Valid && feed.RedirectUrl.String == result.FinalURL {
+		count = feed.RedirectCount + 1
+	}
+	if count < permanentMoveThreshold {
+		logger.Info("feed permanently redirected", "location", result.FinalURL, "count", count)
+		return s.db.RecordFeedRedirect(ctx, database.RecordFeedRedirectParams{
+			ID:            feed.ID,
+			RedirectUrl:   sql.NullString{String: result.FinalURL, Valid: true},
+			RedirectCount: count,
+			UpdatedAt:     time.Now(),
+		})
+	}
+
+	if other, err := s.db.GetFeedByURL(ctx, result.FinalURL); err == nil && other.ID != feed.ID {
+		logger.Warn("feed moved to the URL of another feed; not updating it", "location", result.FinalURL, "other_feed_id", other.ID)
+		return s.db.RecordFeedRedirect(ctx, database.RecordFeedRedirectParams{
+			ID:            feed.ID,
+			RedirectUrl:   sql.NullString{String: result.FinalURL, Valid: true},
+			RedirectCount: count,
+			UpdatedAt:     time.Now(),
+		})
+	}
+
+	tx, err := s.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := s.db.WithTx(tx)
+
+	err = qtx.CreateFeedAlias(ctx, database.CreateFeedAliasParams{
+		Url:       feed.Url,
+		CreatedAt: time.Now(),
+		FeedID:    feed.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't keep old feed URL: %w", err)
+	}
+	err = qtx.MoveFeed(ctx, database.MoveFeedParams{
+		ID:        feed.ID,
+		Url:       result.FinalURL,
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't update feed URL: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	logger.Info("feed moved", "old_url", feed.Url, "new_url", result.FinalURL)
+	return nil
+}
+
+// markFeedGone stops polling a feed whose server answered 410 Gone, and
+// tells everyone following it.
+func markFeedGone(ctx context.Context, s *state, feed database.Feed) error {
+	now := time.Now()
+	err := s.db.MarkFeedDead(ctx, database.MarkFeedDeadParams{
+		ID:     feed.ID,
+		DeadAt: sql.NullTime{Time: now, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	return s.db.CreateFeedNotifications(ctx, database.CreateFeedNotificationsParams{
+		CreatedAt: now,
+		Message:   fmt.Sprintf("%s (%s) is gone (HTTP 410) and is no longer fetched", feed.Name, feed.Url),
+		FeedID:    feed.ID,
+	})
+}
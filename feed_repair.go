@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+// feedRepair records one kind of fix applied to a malformed feed document.
+type feedRepair struct {
+	Kind  string
+	Count int
+}
+
+func (r feedRepair) String() string {
+	return fmt.Sprintf("%s (%d)", r.Kind, r.Count)
+}
+
+const (
+	repairControlChars  = "control_characters"
+	repairAmpersands    = "bare_ampersands"
+	repairHTMLEntities  = "html_entities"
+	repairMarkup        = "malformed_markup"
+	repairSalvagedItems = "salvaged_items"
+)
+
+var (
+	// entityRef matches named entity references, such as &nbsp;.
+	entityRef = regexp.MustCompile(`&([A-Za-z][A-Za-z0-9]*);`)
+	// itemElement matches one complete RSS item or Atom entry.
+	itemElement = regexp.MustCompile(`(?s)<(item|entry)[\s>].*?</(item|entry)\s*>`)
+	// rootStartTag captures the attributes, and so the namespace
+	// declarations, of the document element.
+	rootStartTag = regexp.MustCompile(`<(?:rss|feed|rdf:RDF)(\s[^>]*)?>`)
+)
+
+// decodeFeedLenient is the fallback for documents the strict decoder
+// rejected with strictErr. It strips characters XML forbids, escapes bare
+// ampersands, resolves HTML entities, tolerates broken markup and, as a
+// last resort, salvages the items that do parse on their own.
+func decodeFeedLenient(data []byte, strictErr error) (*RSSFeed, []feedRepair, error) {
+	var repairs []feedRepair
+	note := func(kind string, count int) {
+		if count > 0 {
+			repairs = append(repairs, feedRepair{Kind: kind, Count: count})
+		}
+	}
+
+	data, n := stripControlChars(data)
+	note(repairControlChars, n)
+	data, n = escapeBareAmpersands(data)
+	note(repairAmpersands, n)
+	note(repairHTMLEntities, countHTMLEntities(data))
+
+	feed, err := decodeFeed(data, true)
+	if err == nil {
+		if len(repairs) == 0 {
+			note(repairMarkup, 1)
+		}
+		return feed, repairs, nil
+	}
+
+	feed, salvaged := salvageItems(data)
+	if salvaged == 0 {
+		return nil, nil, strictErr
+	}
+	note(repairSalvagedItems, salvaged)
+	return feed, repairs, nil
+}
+
+// stripControlChars removes the characters XML 1.0 doesn't allow: C0
+// controls other than tab, newline and carriage return, and U+FFFE/U+FFFF.
+func stripControlChars(data []byte) ([]byte, int) {
+	removed := 0
+	out := data[:0:0]
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0xFFFE || r == 0xFFFF {
+			if removed == 0 {
+				out = append(out, data[:i]...)
+			}
+			removed++
+		} else if removed > 0 {
+			out = append(out, data[i:i+size]...)
+		}
+		i += size
+	}
+	if removed == 0 {
+		return data, 0
+	}
+	return out, removed
+}
+
+// escapeBareAmpersands replaces every & that doesn't start an entity or
+// character reference with &amp;. CDATA sections and comments are left
+// alone, since a literal & is legal there.
+func escapeBareAmpersands(data []byte) ([]byte, int) {
+	var out bytes.Buffer
+	escaped := 0
+	for i := 0; i < len(data); i++ {
+		switch {
+		case bytes.HasPrefix(data[i:], []byte("<![CDATA[")):
+			end := bytes.Index(data[i:], []byte("]]>"))
+			if end < 0 {
+				end = len(data) - i
+			} else {
+				end += 3
+			}
+			out.Write(data[i : i+end])
+			i += end - 1
+		case bytes.HasPrefix(data[i:], []byte("<!--")):
+			end := bytes.Index(data[i:], []byte("-->"))
+			if end < 0 {
+				end = len(data) - i
+			} else {
+				end += 3
+			}
+			out.Write(data[i : i+end])
+			i += end - 1
+		case data[i] == '&' && !isReference(data[i+1:]):
+			out.WriteString("&amp;")
+			escaped++
+		default:
+			out.WriteByte(data[i])
+		}
+	}
+	if escaped == 0 {
+		return data, 0
+	}
+	return out.Bytes(), escaped
+}
+
+// isReference reports whether b, which follows an &, completes an entity
+// or character reference.
+func isReference(b []byte) bool {
+	end := bytes.IndexByte(b, ';')
+	if end <= 0 || end > 32 {
+		return false
+	}
+	ref := b[:end]
+	if ref[0] == '#' {
+		digits := ref[1:]
+		hex := len(digits) > 0 && (digits[0] == 'x' || digits[0] == 'X')
+		if hex {
+			digits = digits[1:]
+		}
+		if len(digits) == 0 {
+			return false
+		}
+		for _, c := range digits {
+			isDigit := c >= '0' && c <= '9'
+			isHex := c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+			if !isDigit && !(hex && isHex) {
+				return false
+			}
+		}
+		return true
+	}
+	for i, c := range ref {
+		isLetter := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+		if !isLetter && !(i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// countHTMLEntities counts references to entities that HTML defines but
+// XML doesn't, which the lenient decoder resolves.
+func countHTMLEntities(data []byte) int {
+	count := 0
+	for _, m := range entityRef.FindAllSubmatch(data, -1) {
+		switch name := string(m[1]); name {
+		case "amp", "lt", "gt", "quot", "apos":
+		default:
+			if _, ok := xml.HTMLEntity[name]; ok {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// salvageItems parses every complete item or entry of a document that
+// can't be parsed as a whole, each on its own, so that one broken item
+// (or a truncated body) doesn't lose the rest. The channel metadata is
+// taken from whatever precedes the first item.
+func salvageItems(data []byte) (*RSSFeed, int) {
+	var attrs []byte
+	if m := rootStartTag.FindSubmatch(data); m != nil {
+		attrs = m[1]
+	}
+
+	var items []RSSItem
+	var entries []atomEntry
+	locations := itemElement.FindAllIndex(data, -1)
+	for _, loc := range locations {
+		var doc bytes.Buffer
+		doc.WriteString("<salvage")
+		doc.Write(attrs)
+		doc.WriteString(">")
+		doc.Write(data[loc[0]:loc[1]])
+		doc.WriteString("</salvage>")
+
+		var wrapper struct {
+			Items   []RSSItem   `xml:"item"`
+			Entries []atomEntry `xml:"entry"`
+		}
+		if err := unmarshalXML(doc.Bytes(), &wrapper, true); err != nil {
+			continue
+		}
+		items = append(items, wrapper.Items...)
+		entries = append(entries, wrapper.Entries...)
+	}
+
+	head := data
+	if len(locations) > 0 {
+		head = data[:locations[0][0]]
+	}
+	if isAtomFeed(data) {
+		// Decoding stops at the end of the truncated header, keeping the
+		// fields read so far.
+		var atom atomFeed
+		unmarshalXML(head, &atom, true)
+		atom.Entries = entries
+		return atom.toRSS(), len(entries)
+	}
+	feed := &RSSFeed{}
+	unmarshalXML(head, feed, true)
+	feed.Channel.Item = items
+	return feed, len(items)
+}
@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+// parseFeed converts documents to UTF-8 as the header or the document
+// says; the detection itself is tested in internal/charset.
+func TestParseFeedCharsets(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		data        string
+		contentType string
+		encoding    string
+		title       string
+		item        string
+	}{
+		{
+			"declared Latin-1",
+			"<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><rss version=\"2.0\"><channel><title>Caf\xe9 cr\xe8me</title><item><title>\xc0 la fran\xe7aise</title></item></channel></rss>",
+			"application/rss+xml", "windows-1252", "Café crème", "À la française",
+		},
+		{
+			"KOI8-R in the header",
+			"<rss version=\"2.0\"><channel><title>\xf0\xd2\xc9\xd7\xc5\xd4</title><item><title>\xcd\xc9\xd2</title></item></channel></rss>",
+			"text/xml; charset=KOI8-R", "koi8-r", "Привет", "мир",
+		},
+		{
+			// The XML declaration says ISO-8859-1, but the header wins.
+			"header over declaration",
+			"<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><rss version=\"2.0\"><channel><title>Na\xc3\xafve Z\xc3\xbcrich</title><item><title>Sm\xc3\xb6rg\xc3\xa5sbord</title></item></channel></rss>",
+			"application/rss+xml; charset=utf-8", "utf-8", "Naïve Zürich", "Smörgåsbord",
+		},
+		{
+			"UTF-8 BOM",
+			"\xef\xbb\xbf<rss version=\"2.0\"><channel><title>\xe2\x9c\x93</title><item><title>\xce\xb1</title></item></channel></rss>",
+			"application/rss+xml; charset=iso-8859-1", "utf-8", "✓", "α",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := parseFeed([]byte(tt.data), tt.contentType)
+			if err != nil {
+				t.Fatalf("parseFeed: %v", err)
+			}
+			if feed.Encoding != tt.encoding {
+				t.Errorf("Encoding = %q, want %q", feed.Encoding, tt.encoding)
+			}
+			if feed.Channel.Title != tt.title {
+				t.Errorf("channel title = %q, want %q", feed.Channel.Title, tt.title)
+			}
+			if len(feed.Channel.Item) != 1 {
+				t.Fatalf("got %d items, want 1", len(feed.Channel.Item))
+			}
+			if got := feed.Channel.Item[0].Title; got != tt.item {
+				t.Errorf("item title = %q, want %q", got, tt.item)
+			}
+		})
+	}
+}
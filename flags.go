@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"strings"
+)
+
+// parseCommandFlags parses cmd flags that may appear anywhere among the
+// positional arguments (e.g. `agg 1m --daemon`) and returns the positional
+// arguments in order. Everything after "--" is positional.
+func parseCommandFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		parsed := args[:len(args)-len(fs.Args())]
+		args = fs.Args()
+		if endsWithTerminator(fs, parsed) {
+			return append(positional, args...), nil
+		}
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// endsWithTerminator reports whether the flags fs parsed from args ended
+// at a "--" rather than at a positional argument. A "--" can also be the
+// value of a flag, so the flags are walked the way fs read them.
+func endsWithTerminator(fs *flag.FlagSet, args []string) bool {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return i == len(args)-1
+		}
+		name := strings.TrimLeft(arg, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		if f := fs.Lookup(name); f != nil {
+			if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() {
+				i++ // the flag's value
+			}
+		}
+	}
+	return false
+}
+
+// stringsFlag collects the values of a flag that may be repeated, e.g.
+// `--if title:webinar --if feed:vendor`.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
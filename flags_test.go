@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestParseCommandFlags(t *testing.T) {
+	for _, tt := range []struct {
+		args       []string
+		positional []string
+		daemon     bool
+		pidfile    string
+	}{
+		{[]string{"1m"}, []string{"1m"}, false, ""},
+		{[]string{"1m", "--daemon"}, []string{"1m"}, true, ""},
+		{[]string{"--daemon", "1m", "--pidfile", "/tmp/agg.pid", "extra"}, []string{"1m", "extra"}, true, "/tmp/agg.pid"},
+		{[]string{"1m", "--pidfile=/tmp/agg.pid"}, []string{"1m"}, false, "/tmp/agg.pid"},
+		{[]string{"-", "1m"}, []string{"-", "1m"}, false, ""},
+
+		// Everything after "--" is positional, even if it looks like a
+		// flag, including further "--".
+		{[]string{"--", "--daemon"}, []string{"--daemon"}, false, ""},
+		{[]string{"1m", "--", "--daemon", "--"}, []string{"1m", "--daemon", "--"}, false, ""},
+		{[]string{"--daemon", "--", "-1m"}, []string{"-1m"}, true, ""},
+		{[]string{"1m", "--daemon", "--"}, []string{"1m"}, true, ""},
+
+		// A "--" that is a flag's value doesn't end the flags.
+		{[]string{"--pidfile", "--", "--daemon"}, nil, true, "--"},
+		{[]string{"1m", "--pidfile", "--", "2m", "--daemon"}, []string{"1m", "2m"}, true, "--"},
+		{[]string{"--pidfile=x", "--", "--daemon"}, []string{"--daemon"}, false, "x"},
+	} {
+		fs := flag.NewFlagSet("agg", flag.ContinueOnError)
+		daemon := fs.Bool("daemon", false, "")
+		pidfile := fs.String("pidfile", "", "")
+		positional, err := parseCommandFlags(fs, tt.args)
+		if err != nil {
+			t.Errorf("parseCommandFlags(%q): %v", tt.args, err)
+			continue
+		}
+		if strings.Join(positional, " ") != strings.Join(tt.positional, " ") || len(positional) != len(tt.positional) {
+			t.Errorf("parseCommandFlags(%q) = %q, want %q", tt.args, positional, tt.positional)
+		}
+		if *daemon != tt.daemon || *pidfile != tt.pidfile {
+			t.Errorf("parseCommandFlags(%q) set daemon %v, pidfile %q, want %v, %q", tt.args, *daemon, *pidfile, tt.daemon, tt.pidfile)
+		}
+	}
+
+	fs := flag.NewFlagSet("agg", flag.ContinueOnError)
+	if _, err := parseCommandFlags(fs, []string{"1m", "--unknown"}); err == nil {
+		t.Error("parseCommandFlags accepted an unknown flag")
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jjboykin/gator/internal/charset"
+	"github.com/jjboykin/gator/internal/database"
+	"github.com/jjboykin/gator/internal/fetcher"
+	"github.com/jjboykin/gator/internal/plaintext"
+	"github.com/jjboykin/gator/internal/readability"
+	"github.com/jjboykin/gator/internal/sanitize"
+)
+
+// articleTimeout bounds fetching one article page for extraction.
+const articleTimeout = 30 * time.Second
+
+// extractionsPerScrape and extractionBudget bound the extraction one feed
+// document triggers, so a feed with many new posts or slow article pages
+// doesn't hold up the scrape. Posts left over can be extracted with the
+// extract command.
+const (
+	extractionsPerScrape = 5
+	extractionBudget     = time.Minute
+)
+
+// extractPost fetches the web page of post, extracts the article from it
+// and stores the sanitized result as the post's extracted content.
+func extractPost(ctx context.Context, s *state, post database.Post) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, articleTimeout)
+	defer cancel()
+
+	page, pageURL, err := fetchArticle(ctx, s.fetcher(), post.Url)
+	if err != nil {
+		return "", err
+	}
+	// Relative links in the page are relative to where it was found after
+	// redirects, not to the link in the feed.
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+	article, err := readability.Extract(page, base)
+	if err != nil {
+		return "", err
+	}
+
+	content := sanitize.HTML(article.Content, base)
+	err = s.db.SetPostExtractedContent(ctx, database.SetPostExtractedContentParams{
+		ID:               post.ID,
+		ExtractedContent: sql.NullString{String: content, Valid: true},
+		UpdatedAt:        time.Now(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("couldn't store extracted content: %w", err)
+	}
+	return content, nil
+}
+
+// extractNewPosts runs extraction for posts just stored from a feed with
+// fulltext set, within extractionsPerScrape and extractionBudget.
+func extractNewPosts(ctx context.Context, s *state, posts []database.Post, logger *slog.Logger) {
+	if len(posts) > extractionsPerScrape {
+		logger.Info("too many new posts to extract them all", "extracting", extractionsPerScrape, "skipped", len(posts)-extractionsPerScrape)
+		posts = posts[:extractionsPerScrape]
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, extractionBudget)
+	defer cancel()
+	for i, post := range posts {
+		if ctx.Err() != nil {
+			logger.Info("ran out of time for full-text extraction", "skipped", len(posts)-i)
+			return
+		}
+		if _, err := extractPost(ctx, s, post); err != nil {
+			logger.Warn("couldn't extract full text", "post_id", post.ID, "post_url", post.Url, "error", err)
+		}
+	}
+}
+
+// fetchArticle downloads an HTML page. It returns the page and its URL
+// after redirects.
+func fetchArticle(ctx context.Context, client *fetcher.Client, pageURL string) (string, string, error) {
+	header := http.Header{}
+	header.Set("Accept", "text/html,application/xhtml+xml")
+	resp, err := client.Get(ctx, pageURL, header)
+	if err != nil {
+		return "", "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("unexpected HTTP status: %d", resp.StatusCode)
+	}
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil &&
+		mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return "", "", fmt.Errorf("%s is %s, not an HTML page", pageURL, mediaType)
+	}
+	page, err := charset.ToUTF8(resp.Body, charset.Detect(resp.Body, resp.Header.Get("Content-Type")))
+	if err != nil {
+		page, _ = charset.ToUTF8(resp.Body, "utf-8")
+	}
+	if resp.URL != "" {
+		pageURL = resp.URL
+	}
+	return string(page), pageURL, nil
+}
+
+// handlerExtract runs full-text extraction for one post on demand,
+// regardless of its feed's fulltext setting, and prints the result.
+func handlerExtract(s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 1 {
+		return errors.New("usage: extract <post-id>")
+	}
+	postID, err := uuid.Parse(cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("invalid post id: %w", err)
+	}
+
+	ctx := context.Background()
+	post, err := s.db.GetPost(ctx, postID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("post %s not found", postID)
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't get post: %w", err)
+	}
+
+	content, err := extractPost(ctx, s, post)
+	if err != nil {
+		return fmt.Errorf("couldn't extract %s: %w", post.Url, err)
+	}
+	fmt.Printf("--- %s ---\n", post.Title)
+	fmt.Println(plaintext.Render(content, bodyWidth))
+	return nil
+}
+
+// postBody picks the most complete body available for a post: extracted
+// article text, then feed-provided content, then the description.
+func postBody(description, content, extracted sql.NullString) string {
+	switch {
+	case extracted.Valid && extracted.String != "":
+		return extracted.String
+	case content.Valid && content.String != "":
+		return content.String
+	default:
+		return description.String
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jjboykin/gator/internal/database"
+	"github.com/jjboykin/gator/internal/fetcher"
+)
+
+const articlePage = `<html><head><title>Article</title></head><body>
+<nav><a href="/">Home</a></nav>
+<article>
+<p>The first paragraph of the article is long enough, with commas, to be scored as the content of the page.</p>
+<p>It links to <a href="notes.html">the notes</a> next to it and shows <img src="figure.png" alt="a figure">.</p>
+<p>The last paragraph of the article is long enough, with commas, to be scored as the content of the page too.</p>
+</article>
+</body></html>`
+
+func newArticleServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var served atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/p/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/2024/05"+strings.TrimPrefix(r.URL.Path, "/p")+"/", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/2024/05/", func(w http.ResponseWriter, r *http.Request) {
+		served.Add(1)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(articlePage))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &served
+}
+
+func newArticleState(t *testing.T, db *fakeDB) *state {
+	t.Helper()
+	client, err := fetcher.New(fetcher.Options{AllowPrivate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestState(t, db)
+	s.fetcherPtr.Store(client)
+	return s
+}
+
+// Relative URLs in the article resolve against the page the link
+// redirected to.
+func TestExtractPostAfterRedirect(t *testing.T) {
+	server, _ := newArticleServer(t)
+	db := &fakeDB{}
+	s := newArticleState(t, db)
+
+	post := database.Post{ID: uuid.New(), Url: server.URL + "/p/story"}
+	content, err := extractPost(t.Context(), s, post)
+	if err != nil {
+		t.Fatalf("extractPost: %v", err)
+	}
+	for _, want := range []string{
+		`href="` + server.URL + `/2024/05/story/notes.html"`,
+		`src="` + server.URL + `/2024/05/story/figure.png"`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("extracted content doesn't have %s:\n%s", want, content)
+		}
+	}
+	if stored := db.called("SetPostExtractedContent"); len(stored) != 1 {
+		t.Errorf("content stored %d times, want once", len(stored))
+	}
+}
+
+// A scrape extracts at most extractionsPerScrape posts, after storing all
+// of them.
+func TestIngestFeedExtractionBound(t *testing.T) {
+	server, served := newArticleServer(t)
+	var items strings.Builder
+	for i := range extractionsPerScrape + 2 {
+		fmt.Fprintf(&items, "<item><title>Post %d</title><link>%s/p/%d</link></item>", i, server.URL, i)
+	}
+	fetched, err := decodeFeed([]byte(`<rss version="2.0"><channel><title>Example</title>`+items.String()+`</channel></rss>`), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := &fakeDB{}
+	db.onArgs("CreatePost", func(args []driver.Value) []any {
+		return []any{database.Post{ID: uuid.New(), Url: args[4].(string)}}
+	})
+	s := newArticleState(t, db)
+	s.metrics = newAggregatorMetrics()
+	feed := database.Feed{ID: uuid.New(), Url: server.URL + "/rss", Fulltext: true}
+
+	result := ingestFeed(t.Context(), s, feed, fetched, slog.New(slog.DiscardHandler))
+	if result.Inserted != extractionsPerScrape+2 {
+		t.Errorf("ingestFeed inserted %d posts, want %d", result.Inserted, extractionsPerScrape+2)
+	}
+	if got := served.Load(); got != extractionsPerScrape {
+		t.Errorf("fetched %d articles, want %d", got, extractionsPerScrape)
+	}
+	if stored := db.called("SetPostExtractedContent"); len(stored) != extractionsPerScrape {
+		t.Errorf("stored %d extracted articles, want %d", len(stored), extractionsPerScrape)
+	}
+}
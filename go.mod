@@ -3,6 +3,7 @@ module github.com/jjboykin/gator
 go 1.24.0
 
 require (
+	github.com/andybalholm/brotli v1.2.0
 	github.com/google/uuid v1.6.0
 	github.com/lib/pq v1.10.9
 )
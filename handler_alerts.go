@@ -0,0 +1,444 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jjboykin/gator/internal/database"
+	"github.com/jjboykin/gator/internal/notify"
+	"github.com/jjboykin/gator/internal/plaintext"
+	"github.com/jjboykin/gator/internal/rules"
+)
+
+// alertRetryDelays are the waits before each retry of a failed delivery.
+// A delivery that fails once more after the last one is given up on.
+var alertRetryDelays = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour}
+
+// alertTimeout bounds a single delivery attempt.
+const alertTimeout = 15 * time.Second
+
+// feedAlert is an alert with its patterns parsed.
+type feedAlert struct {
+	alert    database.Alert
+	patterns []rules.Pattern
+}
+
+func parseAlertPatterns(patterns []string) ([]rules.Pattern, error) {
+	out := make([]rules.Pattern, 0, len(patterns))
+	for _, s := range patterns {
+		p, err := rules.ParsePattern(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", s, err)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// loadFeedAlerts returns the alerts of every user following feedID that
+// cover it.
+func loadFeedAlerts(ctx context.Context, s *state, feedID uuid.UUID, logger *slog.Logger) ([]feedAlert, error) {
+	alerts, err := s.db.GetAlertsForFeed(ctx, feedID)
+	if err != nil {
+		return nil, err
+	}
+	var out []feedAlert
+	for _, alert := range alerts {
+		patterns, err := parseAlertPatterns(alert.Patterns)
+		if err != nil {
+			logger.Warn("skipping invalid alert", "user_id", alert.UserID, "alert", alert.Name, "error", err)
+			continue
+		}
+		out = append(out, feedAlert{alert: alert, patterns: patterns})
+	}
+	return out, nil
+}
+
+// match returns the first pattern found in the title or description.
+func (a feedAlert) match(title, description string) (string, bool) {
+	for _, p := range a.patterns {
+		if p.Match(title) || p.Match(description) {
+			return p.String(), true
+		}
+	}
+	return "", false
+}
+
+// triggerAlerts records a delivery for every alert that matches a newly
+// stored post and makes the first attempt right away; failed attempts are
+// retried by the aggregator.
+func triggerAlerts(ctx context.Context, s *state, feed database.Feed, post database.Post, alerts []feedAlert, logger *slog.Logger) {
+	description := plaintext.Render(post.Description.String, 0)
+	for _, a := range alerts {
+		match, ok := a.match(post.Title, description)
+		if !ok {
+			continue
+		}
+		delivery, err := s.db.CreateAlertDelivery(ctx, database.CreateAlertDeliveryParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			AlertID:   a.alert.ID,
+			PostID:    post.ID,
+			Match:     match,
+			// Keeps the retry loop off the delivery while it is attempted.
+			NextAttemptAt: time.Now().Add(alertTimeout),
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			logger.Error("couldn't record alert delivery", "alert_id", a.alert.ID, "post_id", post.ID, "error", err)
+			continue
+		}
+		msg := notify.Message{
+			Alert:       a.alert.Name,
+			Match:       match,
+			FeedName:    feed.Name,
+			FeedURL:     feed.Url,
+			PostID:      post.ID.String(),
+			Title:       post.Title,
+			URL:         post.Url,
+			PublishedAt: post.PublishedAt,
+			Summary:     summarize(description, 300),
+		}
+		deliverAlert(ctx, s, delivery.ID, delivery.Attempts, a.alert.TargetKind, a.alert.Target, a.alert.Secret.String, msg, logger)
+	}
+}
+
+// checkAlertTarget refuses command targets whose program isn't listed in
+// alert_commands. It is checked when an alert is added and again before
+// every delivery, so removing a program from the list takes effect for
+// existing alerts too.
+func checkAlertTarget(s *state, kind, target string) error {
+	if kind != notify.KindCommand || notify.CommandAllowed(target, s.configPtr.AlertCommands) {
+		return nil
+	}
+	program := target
+	if args := strings.Fields(target); len(args) > 0 {
+		program = args[0]
+	}
+	return fmt.Errorf("%w: %q isn't listed in alert_commands", notify.ErrNotAllowed, program)
+}
+
+// deliverAlert makes one delivery attempt and records its outcome.
+// attempts is the number of attempts made before this one.
+func deliverAlert(ctx context.Context, s *state, deliveryID uuid.UUID, attempts int32, kind, target, secret string, msg notify.Message, logger *slog.Logger) {
+	logger = logger.With("delivery_id", deliveryID, "alert", msg.Alert, "target_kind", kind)
+
+	var sender notify.Sender
+	err := checkAlertTarget(s, kind, target)
+	if err == nil {
+		sender, err = notify.New(kind, target, secret, s.fetcher().HTTPClient())
+	}
+	if err == nil {
+		sendCtx, cancel := context.WithTimeout(ctx, alertTimeout)
+		err = sender.Send(sendCtx, msg)
+		cancel()
+	}
+
+	if err == nil {
+		s.metrics.alertDeliveries.Inc("delivered")
+		logger.Info("alert delivered", "post_url", msg.URL)
+		err := s.db.MarkAlertDelivered(ctx, database.MarkAlertDeliveredParams{
+			ID:          deliveryID,
+			DeliveredAt: sql.NullTime{Time: time.Now(), Valid: true},
+		})
+		if err != nil {
+			logger.Error("couldn't record alert delivery", "error", err)
+		}
+		return
+	}
+
+	status := "pending"
+	next, retry := alertRetryAt(attempts, err, time.Now())
+	if retry {
+		s.metrics.alertDeliveries.Inc("retry")
+		logger.Warn("alert delivery failed; will retry", "attempt", attempts+1, "next_attempt", next, "error", err)
+	} else {
+		status = "failed"
+		next = time.Now()
+		s.metrics.alertDeliveries.Inc("failed")
+		logger.Error("alert delivery failed; giving up", "attempt", attempts+1, "error", err)
+	}
+	err = s.db.MarkAlertDeliveryFailed(ctx, database.MarkAlertDeliveryFailedParams{
+		ID:            deliveryID,
+		Status:        status,
+		LastError:     sql.NullString{String: err.Error(), Valid: true},
+		NextAttemptAt: next,
+		UpdatedAt:     time.Now(),
+	})
+	if err != nil {
+		logger.Error("couldn't record alert delivery", "error", err)
+	}
+}
+
+// alertRetryAt returns when to retry a delivery that failed with err,
+// given the number of attempts made before the failed one, or false when
+// it should be given up on.
+func alertRetryAt(attempts int32, err error, now time.Time) (time.Time, bool) {
+	if int(attempts) >= len(alertRetryDelays) || notify.IsPermanent(err) {
+		return time.Time{}, false
+	}
+	return now.Add(alertRetryDelays[attempts]), true
+}
+
+// retryAlertDeliveries makes the next attempt of every delivery that is
+// due for one.
+func retryAlertDeliveries(ctx context.Context, s *state) {
+	due, err := s.db.GetDueAlertDeliveries(ctx, database.GetDueAlertDeliveriesParams{
+		NextAttemptAt: time.Now(),
+		Limit:         100,
+	})
+	if err != nil {
+		s.logger().Error("couldn't get due alert deliveries", "error", err)
+		return
+	}
+	for _, d := range due {
+		description := plaintext.Render(d.PostDescription.String, 0)
+		msg := notify.Message{
+			Alert:       d.AlertName,
+			Match:       d.Match,
+			FeedName:    d.FeedName,
+			FeedURL:     d.FeedUrl,
+			PostID:      d.PostID.String(),
+			Title:       d.PostTitle,
+			URL:         d.PostUrl,
+			PublishedAt: d.PublishedAt,
+			Summary:     summarize(description, 300),
+		}
+		deliverAlert(ctx, s, d.ID, d.Attempts, d.TargetKind, d.Target, d.Secret.String, msg, s.logger())
+	}
+}
+
+func handlerAlerts(s *state, cmd command, user database.User) error {
+	if len(cmd.args) == 0 {
+		return errors.New("usage: alerts add <name> <keyword|/regexp/>... (--webhook <url> | --slack <url> | --command <cmd>) | alerts list | alerts remove <name> | alerts test <name> | alerts log")
+	}
+
+	switch cmd.args[0] {
+	case "add":
+		return handlerAlertsAdd(s, cmd.args[1:], user)
+	case "list":
+		return handlerAlertsList(s, cmd.args[1:], user)
+	case "remove":
+		return handlerAlertsRemove(s, cmd.args[1:], user)
+	case "test":
+		return handlerAlertsTest(s, cmd.args[1:], user)
+	case "log":
+		return handlerAlertsLog(s, cmd.args[1:], user)
+	default:
+		return fmt.Errorf("unknown alerts subcommand: %s", cmd.args[0])
+	}
+}
+
+func handlerAlertsAdd(s *state, args []string, user database.User) error {
+	fs := flag.NewFlagSet("alerts add", flag.ContinueOnError)
+	webhook := fs.String("webhook", "", "POST the alert as JSON to this URL")
+	slack := fs.String("slack", "", "post the alert to this Slack-compatible incoming webhook")
+	command := fs.String("command", "", "run this command with the alert as JSON on standard input")
+	secret := fs.String("secret", "", "sign webhook deliveries with this secret")
+	feedURL := fs.String("feed", "", "only alert on posts from this feed")
+	args, err := parseCommandFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	usage := errors.New("usage: alerts add <name> <keyword|/regexp/>... (--webhook <url> | --slack <url> | --command <cmd>) [--secret <secret>] [--feed <feed_url>]")
+	if len(args) < 2 {
+		return usage
+	}
+	name := strings.TrimSpace(args[0])
+	if name == "" {
+		return errors.New("alert name can't be empty")
+	}
+	if _, err := parseAlertPatterns(args[1:]); err != nil {
+		return err
+	}
+
+	var kind, target string
+	for _, t := range []struct{ kind, value string }{
+		{notify.KindWebhook, *webhook},
+		{notify.KindSlack, *slack},
+		{notify.KindCommand, *command},
+	} {
+		if t.value == "" {
+			continue
+		}
+		if kind != "" {
+			return errors.New("give only one of --webhook, --slack and --command")
+		}
+		kind, target = t.kind, t.value
+	}
+	switch kind {
+	case "":
+		return usage
+	case notify.KindWebhook, notify.KindSlack:
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid webhook URL %q", target)
+		}
+	}
+	if *secret != "" && kind != notify.KindWebhook {
+		return errors.New("--secret only applies to --webhook")
+	}
+	if err := checkAlertTarget(s, kind, target); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	feedID := uuid.NullUUID{}
+	if *feedURL != "" {
+		feed, err := lookupFeed(ctx, s, *feedURL)
+		if err != nil {
+			return fmt.Errorf("couldn't find feed %s: %w", *feedURL, err)
+		}
+		feedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	}
+
+	_, err = s.db.CreateAlert(ctx, database.CreateAlertParams{
+		ID:         uuid.New(),
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		UserID:     user.ID,
+		Name:       name,
+		Patterns:   args[1:],
+		FeedID:     feedID,
+		TargetKind: kind,
+		Target:     target,
+		Secret:     sql.NullString{String: *secret, Valid: *secret != ""},
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't add alert: %w", err)
+	}
+	fmt.Printf("Alert %s added; try it with `gator alerts test %s`\n", name, name)
+	return nil
+}
+
+func handlerAlertsList(s *state, args []string, user database.User) error {
+	if len(args) != 0 {
+		return errors.New("too many command args given")
+	}
+	alerts, err := s.db.GetAlertsForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't get alerts: %w", err)
+	}
+	if len(alerts) == 0 {
+		fmt.Println("No alerts yet; add one with `gator alerts add`")
+		return nil
+	}
+	for _, alert := range alerts {
+		scope := "all followed feeds"
+		if alert.FeedUrl.Valid {
+			scope = alert.FeedUrl.String
+		}
+		signed := ""
+		if alert.Secret.Valid {
+			signed = " (signed)"
+		}
+		fmt.Printf("%s\n", alert.Name)
+		fmt.Printf("    match: %s\n", strings.Join(alert.Patterns, " OR "))
+		fmt.Printf("    in:    %s\n", scope)
+		fmt.Printf("    to:    %s %s%s\n", alert.TargetKind, alert.Target, signed)
+	}
+	return nil
+}
+
+func handlerAlertsRemove(s *state, args []string, user database.User) error {
+	if len(args) != 1 {
+		return errors.New("usage: alerts remove <name>")
+	}
+	removed, err := s.db.DeleteAlert(context.Background(), database.DeleteAlertParams{UserID: user.ID, Name: args[0]})
+	if err != nil {
+		return fmt.Errorf("couldn't remove alert: %w", err)
+	}
+	if removed == 0 {
+		return fmt.Errorf("no such alert: %s", args[0])
+	}
+	fmt.Printf("Alert %s removed\n", args[0])
+	return nil
+}
+
+// handlerAlertsTest sends a sample message to an alert's target, once and
+// without recording it, to check that the target works.
+func handlerAlertsTest(s *state, args []string, user database.User) error {
+	if len(args) != 1 {
+		return errors.New("usage: alerts test <name>")
+	}
+	ctx := context.Background()
+	alert, err := s.db.GetAlertByName(ctx, database.GetAlertByNameParams{UserID: user.ID, Name: args[0]})
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no such alert: %s", args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't get alert: %w", err)
+	}
+	if err := checkAlertTarget(s, alert.TargetKind, alert.Target); err != nil {
+		return err
+	}
+	sender, err := notify.New(alert.TargetKind, alert.Target, alert.Secret.String, s.fetcher().HTTPClient())
+	if err != nil {
+		return err
+	}
+
+	msg := notify.Message{
+		Alert:       alert.Name,
+		Match:       alert.Patterns[0],
+		FeedName:    "gator",
+		FeedURL:     "https://example.com/feed.xml",
+		PostID:      uuid.Nil.String(),
+		Title:       "Test alert from gator",
+		URL:         "https://example.com/test-alert",
+		PublishedAt: time.Now().UTC().Truncate(time.Second),
+		Summary:     "This is a test delivery sent by `gator alerts test`.",
+	}
+	sendCtx, cancel := context.WithTimeout(ctx, alertTimeout)
+	defer cancel()
+	if err := sender.Send(sendCtx, msg); err != nil {
+		return fmt.Errorf("test delivery failed: %w", err)
+	}
+	fmt.Printf("Test alert delivered to %s %s\n", alert.TargetKind, alert.Target)
+	return nil
+}
+
+// handlerAlertsLog lists the most recent deliveries of the user's alerts.
+func handlerAlertsLog(s *state, args []string, user database.User) error {
+	fs := flag.NewFlagSet("alerts log", flag.ContinueOnError)
+	limit := fs.Int("limit", 20, "number of deliveries to list")
+	args, err := parseCommandFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errors.New("too many command args given")
+	}
+	deliveries, err := s.db.GetAlertDeliveriesForUser(context.Background(), database.GetAlertDeliveriesForUserParams{
+		UserID: user.ID,
+		Limit:  int32(*limit),
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't get alert deliveries: %w", err)
+	}
+	if len(deliveries) == 0 {
+		fmt.Println("No alerts delivered yet")
+		return nil
+	}
+	for _, d := range deliveries {
+		fmt.Printf("%s  %-9s %s: %s (matched %q, %d attempts)\n",
+			d.CreatedAt.Format("2006-01-02 15:04"), d.Status, d.AlertName, d.PostTitle, d.Match, d.Attempts)
+		switch {
+		case d.Status == "pending" && d.LastError.Valid:
+			fmt.Printf("    next attempt at %s; last error: %s\n", d.NextAttemptAt.Format("2006-01-02 15:04"), d.LastError.String)
+		case d.Status == "failed":
+			fmt.Printf("    error: %s\n", d.LastError.String)
+		}
+	}
+	return nil
+}
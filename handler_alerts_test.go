@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jjboykin/gator/internal/notify"
+)
+
+func TestAlertRetryAt(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	transient := errors.New("connection refused")
+
+	for _, tt := range []struct {
+		name     string
+		attempts int32
+		err      error
+		want     time.Duration
+		retry    bool
+	}{
+		{"first failure", 0, transient, time.Minute, true},
+		{"second failure", 1, transient, 5 * time.Minute, true},
+		{"third failure", 2, transient, 30 * time.Minute, true},
+		{"fourth failure", 3, transient, 2 * time.Hour, true},
+		{"fifth failure", 4, transient, 0, false},
+		{"rejected by the receiver", 0, &notify.StatusError{StatusCode: 404}, 0, false},
+		{"receiver unavailable", 0, &notify.StatusError{StatusCode: 503}, time.Minute, true},
+		{"command not allowed", 0, fmt.Errorf("%w: x", notify.ErrNotAllowed), 0, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			next, retry := alertRetryAt(tt.attempts, tt.err, now)
+			if retry != tt.retry {
+				t.Fatalf("alertRetryAt(%d, %v) retries = %v, want %v", tt.attempts, tt.err, retry, tt.retry)
+			}
+			if retry && next.Sub(now) != tt.want {
+				t.Errorf("alertRetryAt(%d, %v) = now+%s, want now+%s", tt.attempts, tt.err, next.Sub(now), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jjboykin/gator/internal/database"
+)
+
+// categorySeparator separates the levels of a category path, e.g. "Tech/Go".
+const categorySeparator = "/"
+
+// categoryTree holds a user's categories for resolving paths. Top-level
+// categories are the children of uuid.Nil.
+type categoryTree struct {
+	byID     map[uuid.UUID]database.Category
+	children map[uuid.UUID][]database.Category
+}
+
+func loadCategories(ctx context.Context, s *state, userID uuid.UUID) (*categoryTree, error) {
+	categories, err := s.db.GetCategoriesForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get categories: %w", err)
+	}
+	tree := &categoryTree{
+		byID:     make(map[uuid.UUID]database.Category),
+		children: make(map[uuid.UUID][]database.Category),
+	}
+	for _, category := range categories {
+		tree.add(category)
+	}
+	return tree, nil
+}
+
+func (t *categoryTree) add(category database.Category) {
+	t.byID[category.ID] = category
+	parent := category.ParentID.UUID
+	t.children[parent] = append(t.children[parent], category)
+	sort.Slice(t.children[parent], func(i, j int) bool {
+		return t.children[parent][i].Name < t.children[parent][j].Name
+	})
+}
+
+func (t *categoryTree) child(parent uuid.UUID, name string) (database.Category, bool) {
+	for _, category := range t.children[parent] {
+		if category.Name == name {
+			return category, true
+		}
+	}
+	return database.Category{}, false
+}
+
+// find resolves a category path.
+func (t *categoryTree) find(path string) (database.Category, error) {
+	names, err := splitCategoryPath(path)
+	if err != nil {
+		return database.Category{}, err
+	}
+	var category database.Category
+	parent := uuid.Nil
+	for _, name := range names {
+		var ok bool
+		category, ok = t.child(parent, name)
+		if !ok {
+			return database.Category{}, fmt.Errorf("no such category: %s", path)
+		}
+		parent = category.ID
+	}
+	return category, nil
+}
+
+// path returns the full path of the category with the given id.
+func (t *categoryTree) path(id uuid.UUID) string {
+	var names []string
+	for id != uuid.Nil {
+		category, ok := t.byID[id]
+		if !ok {
+			break
+		}
+		names = append([]string{category.Name}, names...)
+		id = category.ParentID.UUID
+	}
+	return strings.Join(names, categorySeparator)
+}
+
+// subtree returns the id of a category and of all categories below it.
+func (t *categoryTree) subtree(id uuid.UUID) []uuid.UUID {
+	ids := []uuid.UUID{id}
+	for _, child := range t.children[id] {
+		ids = append(ids, t.subtree(child.ID)...)
+	}
+	return ids
+}
+
+// ensure returns the category at the path given by names, creating it and
+// any missing parents.
+func (t *categoryTree) ensure(ctx context.Context, s *state, userID uuid.UUID, names []string) (database.Category, error) {
+	var category database.Category
+	parent := uuid.Nil
+	for _, name := range names {
+		existing, ok := t.child(parent, name)
+		if !ok {
+			var err error
+			existing, err = s.db.CreateCategory(ctx, database.CreateCategoryParams{
+				ID:        uuid.New(),
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+				UserID:    userID,
+				ParentID:  uuid.NullUUID{UUID: parent, Valid: parent != uuid.Nil},
+				Name:      name,
+			})
+			if err != nil {
+				return database.Category{}, fmt.Errorf("couldn't create category %s: %w", name, err)
+			}
+			t.add(existing)
+		}
+		category = existing
+		parent = existing.ID
+	}
+	return category, nil
+}
+
+// splitCategoryPath splits "Tech/Go" into its names, rejecting empty ones.
+func splitCategoryPath(path string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(path, categorySeparator) {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("invalid category path %q", path)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// resolveCategory turns a --category or move argument into a category id,
+// creating the category if needed. An empty path or "/" means none.
+func resolveCategory(ctx context.Context, s *state, userID uuid.UUID, path string) (uuid.NullUUID, error) {
+	if path == "" || path == categorySeparator {
+		return uuid.NullUUID{}, nil
+	}
+	names, err := splitCategoryPath(path)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	tree, err := loadCategories(ctx, s, userID)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	category, err := tree.ensure(ctx, s, userID, names)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	return uuid.NullUUID{UUID: category.ID, Valid: true}, nil
+}
+
+func handlerCategory(s *state, cmd command, user database.User) error {
+	if len(cmd.args) == 0 {
+		return errors.New("usage: category add <path> | category rename <path> <new_name> | category remove <path> | category list")
+	}
+
+	switch cmd.args[0] {
+	case "add":
+		return handlerCategoryAdd(s, cmd.args[1:], user)
+	case "rename":
+		return handlerCategoryRename(s, cmd.args[1:], user)
+	case "remove":
+		return handlerCategoryRemove(s, cmd.args[1:], user)
+	case "list":
+		return handlerCategoryList(s, cmd.args[1:], user)
+	default:
+		return fmt.Errorf("unknown category subcommand: %s", cmd.args[0])
+	}
+}
+
+// handlerCategoryAdd creates a category, and its parents if needed.
+func handlerCategoryAdd(s *state, args []string, user database.User) error {
+	if len(args) != 1 {
+		return errors.New("usage: category add <path>")
+	}
+	names, err := splitCategoryPath(args[0])
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	tree, err := loadCategories(ctx, s, user.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := tree.find(args[0]); err == nil {
+		return fmt.Errorf("category %s already exists", args[0])
+	}
+	category, err := tree.ensure(ctx, s, user.ID, names)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Category %s added\n", tree.path(category.ID))
+	return nil
+}
+
+// handlerCategoryRename renames the last level of a category path.
+func handlerCategoryRename(s *state, args []string, user database.User) error {
+	if len(args) != 2 {
+		return errors.New("usage: category rename <path> <new_name>")
+	}
+	name := strings.TrimSpace(args[1])
+	if name == "" || strings.Contains(name, categorySeparator) {
+		return fmt.Errorf("invalid category name %q", args[1])
+	}
+
+	ctx := context.Background()
+	tree, err := loadCategories(ctx, s, user.ID)
+	if err != nil {
+		return err
+	}
+	category, err := tree.find(args[0])
+	if err != nil {
+		return err
+	}
+	if _, exists := tree.child(category.ParentID.UUID, name); exists {
+		return fmt.Errorf("a category named %s already exists there", name)
+	}
+
+	err = s.db.RenameCategory(ctx, database.RenameCategoryParams{
+		ID:        category.ID,
+		Name:      name,
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't rename category: %w", err)
+	}
+	fmt.Printf("Category %s renamed to %s\n", args[0], name)
+	return nil
+}
+
+// handlerCategoryRemove deletes a category. Its feeds and subcategories
+// move up to its parent, so nothing is unfollowed.
+func handlerCategoryRemove(s *state, args []string, user database.User) error {
+	if len(args) != 1 {
+		return errors.New("usage: category remove <path>")
+	}
+
+	ctx := context.Background()
+	tree, err := loadCategories(ctx, s, user.ID)
+	if err != nil {
+		return err
+	}
+	category, err := tree.find(args[0])
+	if err != nil {
+		return err
+	}
+	for _, child := range tree.children[category.ID] {
+		if _, exists := tree.child(category.ParentID.UUID, child.Name); exists {
+			return fmt.Errorf("subcategory %s would clash with an existing category; rename it first", child.Name)
+		}
+	}
+
+	tx, err := s.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := s.db.WithTx(tx)
+
+	err = qtx.MoveFeedFollowsToCategory(ctx, database.MoveFeedFollowsToCategoryParams{
+		NewCategoryID: category.ParentID,
+		UpdatedAt:     time.Now(),
+		CategoryID:    category.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't move feeds out of category: %w", err)
+	}
+	err = qtx.ReparentCategories(ctx, database.ReparentCategoriesParams{
+		NewParentID: category.ParentID,
+		UpdatedAt:   time.Now(),
+		ParentID:    uuid.NullUUID{UUID: category.ID, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't move subcategories: %w", err)
+	}
+	if err := qtx.DeleteCategory(ctx, category.ID); err != nil {
+		return fmt.Errorf("couldn't remove category: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Category %s removed\n", args[0])
+	return nil
+}
+
+func handlerCategoryList(s *state, args []string, user database.User) error {
+	if len(args) != 0 {
+		return errors.New("too many command args given")
+	}
+	tree, err := loadCategories(context.Background(), s, user.ID)
+	if err != nil {
+		return err
+	}
+	var printLevel func(parent uuid.UUID, depth int)
+	printLevel = func(parent uuid.UUID, depth int) {
+		for _, category := range tree.children[parent] {
+			fmt.Printf("%s%s%s\n", strings.Repeat("    ", depth), category.Name, categorySeparator)
+			printLevel(category.ID, depth+1)
+		}
+	}
+	printLevel(uuid.Nil, 0)
+	return nil
+}
+
+// handlerMove puts a followed feed into a category, creating it if
+// needed; "/" takes it out of any category.
+func handlerMove(s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 2 {
+		return errors.New("usage: move <feed_url> <category>")
+	}
+
+	ctx := context.Background()
+	feed, err := lookupFeed(ctx, s, cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("couldn't find feed %s: %w", cmd.args[0], err)
+	}
+	follows, err := s.db.GetFeedFollowsForUser(ctx, user.Name)
+	if err != nil {
+		return fmt.Errorf("couldn't get followed feeds: %w", err)
+	}
+	following := false
+	for _, follow := range follows {
+		following = following || follow.FeedID == feed.ID
+	}
+	if !following {
+		return fmt.Errorf("you don't follow %s", cmd.args[0])
+	}
+
+	categoryID, err := resolveCategory(ctx, s, user.ID, cmd.args[1])
+	if err != nil {
+		return err
+	}
+	return s.db.SetFeedFollowCategory(ctx, database.SetFeedFollowCategoryParams{
+		UserID:     user.ID,
+		FeedID:     feed.ID,
+		CategoryID: categoryID,
+		UpdatedAt:  time.Now(),
+	})
+}
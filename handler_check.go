@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jjboykin/gator/internal/charset"
+)
+
+// checkReport is what `gator check` found out about a feed. It is printed
+// as is with --json, for attaching to bug reports.
+type checkReport struct {
+	URL        string            `json:"url"`
+	FinalURL   string            `json:"final_url,omitempty"`
+	StatusCode int               `json:"status_code,omitempty"`
+	Redirects  []checkRedirect   `json:"redirects,omitempty"`
+	Header     map[string]string `json:"headers,omitempty"`
+	Bytes      int64             `json:"bytes"`
+	Error      string            `json:"error,omitempty"`
+
+	Format   string      `json:"format,omitempty"`
+	Encoding string      `json:"encoding,omitempty"`
+	Title    string      `json:"title,omitempty"`
+	Warnings []string    `json:"warnings"`
+	Items    []checkItem `json:"items"`
+}
+
+type checkRedirect struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	StatusCode int    `json:"status_code"`
+}
+
+type checkItem struct {
+	Title    string     `json:"title"`
+	Link     string     `json:"link"`
+	GUID     string     `json:"guid,omitempty"`
+	Date     string     `json:"date"`
+	Parsed   *time.Time `json:"parsed_date,omitempty"`
+	Problems []string   `json:"problems,omitempty"`
+}
+
+// handlerCheck fetches and parses a feed the way the aggregator does,
+// without storing anything, and reports everything that could explain why
+// it doesn't work. A feed that can't be fetched or parsed still gets its
+// report, and then fails the command.
+func handlerCheck(s *state, cmd command) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	args, err := parseCommandFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errors.New("usage: check [--json] <feed_url>")
+	}
+
+	fetched, result, err := fetchFeed(context.Background(), s.fetcher(), args[0])
+	report := newCheckReport(args[0], fetched, result, err)
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printCheckReport(report)
+	}
+	if report.Error != "" {
+		return fmt.Errorf("couldn't check feed: %s", report.Error)
+	}
+	return nil
+}
+
+func newCheckReport(url string, fetched *RSSFeed, result fetchResult, fetchErr error) checkReport {
+	report := checkReport{
+		URL:        url,
+		StatusCode: result.StatusCode,
+		Bytes:      result.Bytes,
+		Warnings:   []string{},
+		Items:      []checkItem{},
+	}
+	if result.FinalURL != url {
+		report.FinalURL = result.FinalURL
+	}
+	for _, hop := range result.Redirects {
+		report.Redirects = append(report.Redirects, checkRedirect{From: hop.From, To: hop.To, StatusCode: hop.StatusCode})
+	}
+	if len(result.Header) > 0 {
+		report.Header = make(map[string]string, len(result.Header))
+		for name, values := range result.Header {
+			report.Header[name] = strings.Join(values, ", ")
+		}
+	}
+	if fetchErr != nil {
+		report.Error = fetchErr.Error()
+		return report
+	}
+
+	report.Format = fetched.Format
+	report.Encoding = fetched.Encoding
+	report.Title = fetched.Channel.Title
+	if _, err := charset.ToUTF8(nil, fetched.Encoding); err != nil {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("encoding %q is not supported; decoded as UTF-8", fetched.Encoding))
+	}
+	for _, repair := range fetched.Repairs {
+		report.Warnings = append(report.Warnings, "malformed document repaired: "+repair.String())
+	}
+
+	missing := []string{}
+	if fetched.Channel.Title == "" {
+		missing = append(missing, "title")
+	}
+	if fetched.Format == "rss" {
+		if fetched.Channel.Link == "" {
+			missing = append(missing, "link")
+		}
+		if fetched.Channel.Description == "" {
+			missing = append(missing, "description")
+		}
+	}
+	if len(missing) > 0 {
+		report.Warnings = append(report.Warnings, "feed is missing required "+strings.Join(missing, ", "))
+	}
+	if len(fetched.Channel.Item) == 0 {
+		report.Warnings = append(report.Warnings, "feed has no items")
+	}
+
+	guids := make(map[string]int)
+	links := make(map[string]int)
+	for i, item := range fetched.Channel.Item {
+		checked := checkItem{
+			Title: item.Title,
+			Link:  item.Link,
+			GUID:  item.GUID,
+			Date:  item.PubDate,
+		}
+		if published := parsePubDate(item.PubDate); !published.IsZero() {
+			checked.Parsed = &published
+		} else if strings.TrimSpace(item.PubDate) == "" {
+			checked.Problems = append(checked.Problems, "no date; it will be stored as published at the zero time")
+		} else {
+			checked.Problems = append(checked.Problems, "date in an unknown format")
+		}
+
+		switch fetched.Format {
+		case "atom":
+			if item.Title == "" {
+				checked.Problems = append(checked.Problems, "missing title")
+			}
+			if item.GUID == "" {
+				checked.Problems = append(checked.Problems, "missing id")
+			}
+		default:
+			if item.Title == "" && item.Description == "" {
+				checked.Problems = append(checked.Problems, "missing both title and description")
+			}
+		}
+		if item.Link == "" {
+			checked.Problems = append(checked.Problems, "missing link; posts are stored by URL, so it can't be saved")
+		}
+
+		if item.GUID != "" {
+			if first, ok := guids[item.GUID]; ok {
+				checked.Problems = append(checked.Problems, fmt.Sprintf("duplicate guid of item %d", first+1))
+			} else {
+				guids[item.GUID] = i
+			}
+		}
+		if item.Link != "" {
+			if first, ok := links[item.Link]; ok {
+				checked.Problems = append(checked.Problems, fmt.Sprintf("duplicate link of item %d; only the first is saved", first+1))
+			} else {
+				links[item.Link] = i
+			}
+		}
+		report.Items = append(report.Items, checked)
+	}
+	return report
+}
+
+func printCheckReport(report checkReport) {
+	fmt.Printf("URL:       %s\n", report.URL)
+	for _, hop := range report.Redirects {
+		fmt.Printf("Redirect:  %d %s -> %s\n", hop.StatusCode, hop.From, hop.To)
+	}
+	if report.FinalURL != "" {
+		fmt.Printf("Final URL: %s\n", report.FinalURL)
+	}
+	if report.StatusCode != 0 {
+		fmt.Printf("Status:    %d %s\n", report.StatusCode, http.StatusText(report.StatusCode))
+		fmt.Printf("Size:      %s\n", formatBytes(report.Bytes))
+	}
+	if len(report.Header) > 0 {
+		names := make([]string, 0, len(report.Header))
+		for name := range report.Header {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Println("Headers:")
+		for _, name := range names {
+			fmt.Printf("    %s: %s\n", name, report.Header[name])
+		}
+	}
+	if report.Error != "" {
+		fmt.Printf("Error:     %s\n", report.Error)
+		return
+	}
+
+	fmt.Printf("Format:    %s\n", report.Format)
+	fmt.Printf("Encoding:  %s\n", report.Encoding)
+	fmt.Printf("Title:     %s\n", report.Title)
+	fmt.Printf("Items:     %d\n", len(report.Items))
+	for _, warning := range report.Warnings {
+		fmt.Printf("Warning:   %s\n", warning)
+	}
+
+	for i, item := range report.Items {
+		fmt.Println("--------------------------------------------------")
+		fmt.Printf("%d. %s\n", i+1, item.Title)
+		fmt.Printf("    Link: %s\n", item.Link)
+		if item.GUID != "" {
+			fmt.Printf("    GUID: %s\n", item.GUID)
+		}
+		if item.Parsed != nil {
+			fmt.Printf("    Date: %q -> %s\n", item.Date, item.Parsed.Format(time.RFC3339))
+		} else {
+			fmt.Printf("    Date: %q\n", item.Date)
+		}
+		for _, problem := range item.Problems {
+			fmt.Printf("    Problem: %s\n", problem)
+		}
+	}
+}
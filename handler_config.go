@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func handlerConfig(s *state, cmd command) error {
+	if len(cmd.args) == 0 {
+		return errors.New("usage: config <show|init>")
+	}
+
+	switch cmd.args[0] {
+	case "show":
+		return handlerConfigShow(s, cmd.args[1:])
+	case "init":
+		return handlerConfigInit(s, cmd.args[1:])
+	default:
+		return fmt.Errorf("unknown config subcommand: %s", cmd.args[0])
+	}
+}
+
+func handlerConfigShow(s *state, args []string) error {
+	if len(args) != 0 {
+		return errors.New("too many command args given")
+	}
+
+	status := ""
+	if !s.configPtr.Exists() {
+		status = " (not found)"
+	}
+	fmt.Printf("Config file: %s%s\n", s.configPtr.Path(), status)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, field := range s.configPtr.Fields() {
+		value := field.Value
+		switch {
+		case field.Key == "db_url":
+			value = redactURL(value)
+		case field.Key == "smtp_password" && value != "":
+			value = "xxxxx"
+		}
+		fmt.Fprintf(w, "%s\t%s\t(%s)\n", field.Key, value, field.Source)
+	}
+	return w.Flush()
+}
+
+func handlerConfigInit(s *state, args []string) error {
+	fs := flag.NewFlagSet("config init", flag.ContinueOnError)
+	force := fs.Bool("force", false, "overwrite an existing config file")
+	rest, err := parseCommandFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return errors.New("too many command args given")
+	}
+
+	if s.configPtr.Exists() && !*force {
+		return fmt.Errorf("config file %s already exists (use --force to overwrite)", s.configPtr.Path())
+	}
+
+	fmt.Printf("Creating config file %s\n", s.configPtr.Path())
+	in := bufio.NewReader(os.Stdin)
+
+	dbURL, err := prompt(in, "Database URL", s.configPtr.DBUrl)
+	if err != nil {
+		return err
+	}
+	userName, err := prompt(in, "Current user name", s.configPtr.CurrentUserName)
+	if err != nil {
+		return err
+	}
+
+	s.configPtr.DBUrl = dbURL
+	s.configPtr.CurrentUserName = userName
+	if err := s.configPtr.Init(*force); err != nil {
+		return err
+	}
+
+	fmt.Printf("Config written to %s\n", s.configPtr.Path())
+	return nil
+}
+
+// prompt asks for a value on stdin, returning def when the answer is empty.
+func prompt(in *bufio.Reader, label, def string) (string, error) {
+	if def != "" {
+		fmt.Printf("%s [%s]: ", label, def)
+	} else {
+		fmt.Printf("%s: ", label)
+	}
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		if def != "" {
+			fmt.Println()
+			return def, nil
+		}
+		return "", fmt.Errorf("reading %s: %w", strings.ToLower(label), err)
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return def, nil
+	}
+	return line, nil
+}
+
+// redactURL hides the password in a connection URL.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return u.Redacted()
+}
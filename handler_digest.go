@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	htmltemplate "html/template"
+	"net/mail"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jjboykin/gator/internal/config"
+	"github.com/jjboykin/gator/internal/database"
+	mailer "github.com/jjboykin/gator/internal/mail"
+	"github.com/jjboykin/gator/internal/plaintext"
+)
+
+// maxDigestPosts caps the size of a digest; a user who follows very busy
+// feeds gets the first ones by feed name.
+const maxDigestPosts = 500
+
+// digest is the data the digest templates render.
+type digest struct {
+	User   string
+	Since  time.Time
+	Posts  int
+	Groups []digestGroup
+}
+
+// digestGroup holds the feeds of one category; Category is empty for
+// feeds that aren't in one.
+type digestGroup struct {
+	Category string
+	Feeds    []digestFeed
+}
+
+type digestFeed struct {
+	Name  string
+	Posts []digestPost
+}
+
+type digestPost struct {
+	Title       string
+	URL         string
+	Author      string
+	PublishedAt time.Time
+	Summary     string
+}
+
+// buildDigest collects the posts stored after since and up to until that
+// the user hasn't read or hidden, grouped by category and feed. Bounding
+// both ends keeps posts stored while a digest is sent out of it and in the
+// next one.
+func buildDigest(ctx context.Context, s *state, user database.User, since, until time.Time) (digest, error) {
+	posts, err := s.db.GetPostsForDigest(ctx, database.GetPostsForDigestParams{
+		UserID: user.ID,
+		Since:  since,
+		Until:  until,
+		Limit:  maxDigestPosts,
+	})
+	if err != nil {
+		return digest{}, fmt.Errorf("couldn't get posts for digest: %w", err)
+	}
+	tree, err := loadCategories(ctx, s, user.ID)
+	if err != nil {
+		return digest{}, err
+	}
+
+	d := digest{User: user.Name, Since: since, Posts: len(posts)}
+	groups := make(map[string]*digestGroup)
+	for _, post := range posts {
+		path := tree.path(post.CategoryID.UUID)
+		group, ok := groups[path]
+		if !ok {
+			group = &digestGroup{Category: path}
+			groups[path] = group
+		}
+		// Posts come sorted by feed name.
+		if n := len(group.Feeds); n == 0 || group.Feeds[n-1].Name != post.FeedName {
+			group.Feeds = append(group.Feeds, digestFeed{Name: post.FeedName})
+		}
+		feed := &group.Feeds[len(group.Feeds)-1]
+		feed.Posts = append(feed.Posts, digestPost{
+			Title:       post.Title,
+			URL:         post.Url,
+			Author:      post.Author.String,
+			PublishedAt: post.PublishedAt,
+			Summary:     summarize(plaintext.Render(post.Description.String, 0), 280),
+		})
+	}
+	for _, group := range groups {
+		d.Groups = append(d.Groups, *group)
+	}
+	// Uncategorized feeds ("") come first.
+	sort.Slice(d.Groups, func(i, j int) bool { return d.Groups[i].Category < d.Groups[j].Category })
+	return d, nil
+}
+
+func (d digest) subject() string {
+	if d.Posts == 1 {
+		return fmt.Sprintf("gator digest: 1 new post since %s", d.Since.Format("Mon Jan 2 15:04"))
+	}
+	return fmt.Sprintf("gator digest: %d new posts since %s", d.Posts, d.Since.Format("Mon Jan 2 15:04"))
+}
+
+var digestTextTemplate = template.Must(template.New("digest").Parse(
+	`{{.Posts}} new posts for {{.User}} since {{.Since.Format "Mon Jan 2 15:04"}}
+{{range .Groups}}
+{{if .Category}}== {{.Category}} =={{else}}== Uncategorized =={{end}}
+{{range .Feeds}}
+{{.Name}}
+{{range .Posts}}  * {{.Title}}{{if .Author}} ({{.Author}}){{end}}
+    {{.URL}}
+{{- if .Summary}}
+    {{.Summary}}
+{{- end}}
+{{end}}{{end}}{{end}}
+--
+Sent by gator. Change or stop this digest with ` + "`gator digest schedule`" + `.
+`))
+
+var digestHTMLTemplate = htmltemplate.Must(htmltemplate.New("digest").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>gator digest</title></head>
+<body style="font-family: sans-serif; max-width: 42em; margin: auto; color: #222;">
+<p>{{.Posts}} new posts for {{.User}} since {{.Since.Format "Mon Jan 2 15:04"}}</p>
+{{range .Groups}}
+<h2 style="border-bottom: 1px solid #ccc;">{{if .Category}}{{.Category}}{{else}}Uncategorized{{end}}</h2>
+{{range .Feeds}}
+<h3>{{.Name}}</h3>
+<ul>
+{{range .Posts}}<li style="margin-bottom: 0.8em;">
+<a href="{{.URL}}">{{.Title}}</a>{{if .Author}} <small>({{.Author}})</small>{{end}}
+{{if .Summary}}<br><span style="color: #555;">{{.Summary}}</span>{{end}}
+</li>
+{{end}}</ul>
+{{end}}{{end}}
+<p style="color: #888; font-size: small;">Sent by gator. Change or stop this digest with <code>gator digest schedule</code>.</p>
+</body>
+</html>
+`))
+
+func (d digest) render() (text, html string, err error) {
+	var t, h bytes.Buffer
+	if err := digestTextTemplate.Execute(&t, d); err != nil {
+		return "", "", err
+	}
+	if err := digestHTMLTemplate.Execute(&h, d); err != nil {
+		return "", "", err
+	}
+	return t.String(), h.String(), nil
+}
+
+func smtpConfig(cfg *config.Config) mailer.Config {
+	return mailer.Config{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		TLS:      cfg.SMTPTLS,
+	}
+}
+
+// sendDigest emails d to the given address.
+func sendDigest(ctx context.Context, s *state, d digest, to string) error {
+	if s.configPtr.SMTPFrom == "" {
+		return errors.New("smtp_from is not configured")
+	}
+	text, html, err := d.render()
+	if err != nil {
+		return err
+	}
+	return mailer.Send(ctx, smtpConfig(s.configPtr), &mailer.Message{
+		From:    s.configPtr.SMTPFrom,
+		To:      []string{to},
+		Subject: d.subject(),
+		Text:    text,
+		HTML:    html,
+	})
+}
+
+// nextDigestAt returns the first time at sendHour after last.
+func nextDigestAt(last time.Time, sendHour int) time.Time {
+	next := time.Date(last.Year(), last.Month(), last.Day(), sendHour, 0, 0, 0, last.Location())
+	if !next.After(last) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// sendDueDigests emails every scheduled digest whose time has come. A
+// digest covers the posts stored since the previous one, or the last day
+// for the first. Nothing is sent when there is nothing new.
+func sendDueDigests(ctx context.Context, s *state) {
+	schedules, err := s.db.GetDigestSchedules(ctx)
+	if err != nil {
+		s.logger().Error("couldn't get digest schedules", "error", err)
+		return
+	}
+	now := time.Now()
+	for _, schedule := range schedules {
+		last := schedule.CreatedAt
+		since := now.Add(-24 * time.Hour)
+		if schedule.LastSentAt.Valid {
+			last = schedule.LastSentAt.Time
+			since = schedule.LastSentAt.Time
+		}
+		if now.Before(nextDigestAt(last, int(schedule.SendHour))) {
+			continue
+		}
+
+		logger := s.logger().With("user", schedule.UserName, "email", schedule.Email)
+		user := database.User{ID: schedule.UserID, Name: schedule.UserName}
+		d, err := buildDigest(ctx, s, user, since, now)
+		if err != nil {
+			logger.Error("couldn't build digest", "error", err)
+			continue
+		}
+		if d.Posts > 0 {
+			if err := sendDigest(ctx, s, d, schedule.Email); err != nil {
+				// Left due, so it is tried again on the next cycle.
+				logger.Error("couldn't send digest", "error", err)
+				continue
+			}
+			logger.Info("digest sent", "posts", d.Posts)
+		}
+		err = s.db.MarkDigestSent(ctx, database.MarkDigestSentParams{
+			UserID:     schedule.UserID,
+			LastSentAt: sql.NullTime{Time: now, Valid: true},
+		})
+		if err != nil {
+			logger.Error("couldn't record digest", "error", err)
+		}
+	}
+}
+
+// handlerDigest prints the digest of recent posts, or emails it with
+// --send; `digest schedule` and `digest unschedule` manage the daily
+// email sent by the aggregator.
+func handlerDigest(s *state, cmd command, user database.User) error {
+	if len(cmd.args) > 0 {
+		switch cmd.args[0] {
+		case "schedule":
+			return handlerDigestSchedule(s, cmd.args[1:], user)
+		case "unschedule":
+			return handlerDigestUnschedule(s, cmd.args[1:], user)
+		}
+	}
+
+	fs := flag.NewFlagSet("digest", flag.ContinueOnError)
+	since := fs.Duration("since", 24*time.Hour, "include posts stored within this long")
+	asHTML := fs.Bool("html", false, "print the HTML version instead of plain text")
+	send := fs.Bool("send", false, "email the digest instead of printing it")
+	to := fs.String("to", "", "address to email the digest to (default: the scheduled digest's address)")
+	args, err := parseCommandFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errors.New("usage: digest [--since <duration>] [--html] [--send [--to <email>]] | digest schedule [<email> [--at <hour>]] | digest unschedule")
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	d, err := buildDigest(ctx, s, user, now.Add(-*since), now)
+	if err != nil {
+		return err
+	}
+
+	if !*send {
+		text, html, err := d.render()
+		if err != nil {
+			return err
+		}
+		if *asHTML {
+			fmt.Print(html)
+		} else {
+			fmt.Print(text)
+		}
+		return nil
+	}
+
+	address := *to
+	if address == "" {
+		schedule, err := s.db.GetDigestSchedule(ctx, user.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("no address to send to; give --to or set one with `gator digest schedule <email>`")
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't get digest schedule: %w", err)
+		}
+		address = schedule.Email
+	}
+	if d.Posts == 0 {
+		fmt.Println("No new posts; nothing sent")
+		return nil
+	}
+	if err := sendDigest(ctx, s, d, address); err != nil {
+		return fmt.Errorf("couldn't send digest: %w", err)
+	}
+	fmt.Printf("Digest of %d posts sent to %s\n", d.Posts, address)
+	return nil
+}
+
+// handlerDigestSchedule sets up the daily digest, or shows it when given
+// no address.
+func handlerDigestSchedule(s *state, args []string, user database.User) error {
+	fs := flag.NewFlagSet("digest schedule", flag.ContinueOnError)
+	at := fs.String("at", "7", "hour of the day to send the digest at, 0-23 or HH:00")
+	args, err := parseCommandFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	if len(args) == 0 {
+		schedule, err := s.db.GetDigestSchedule(ctx, user.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			fmt.Println("No digest scheduled; set one up with `gator digest schedule <email> [--at <hour>]`")
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't get digest schedule: %w", err)
+		}
+		fmt.Printf("Digest sent to %s daily at %02d:00\n", schedule.Email, schedule.SendHour)
+		if schedule.LastSentAt.Valid {
+			fmt.Printf("Last sent:  %s\n", schedule.LastSentAt.Time.Format("2006-01-02 15:04"))
+		}
+		return nil
+	}
+	if len(args) != 1 {
+		return errors.New("usage: digest schedule <email> [--at <hour>]")
+	}
+
+	address, err := mail.ParseAddress(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid email address %q: %w", args[0], err)
+	}
+	hour, err := strconv.Atoi(strings.TrimSuffix(*at, ":00"))
+	if err != nil || hour < 0 || hour > 23 {
+		return fmt.Errorf("invalid hour %q: want 0-23 or HH:00", *at)
+	}
+
+	_, err = s.db.SetDigestSchedule(ctx, database.SetDigestScheduleParams{
+		UserID:    user.ID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Email:     address.Address,
+		SendHour:  int32(hour),
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't schedule digest: %w", err)
+	}
+	fmt.Printf("Digest will be sent to %s daily at %02d:00 while `gator agg` runs\n", address.Address, hour)
+	return nil
+}
+
+func handlerDigestUnschedule(s *state, args []string, user database.User) error {
+	if len(args) != 0 {
+		return errors.New("too many command args given")
+	}
+	removed, err := s.db.DeleteDigestSchedule(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't remove digest schedule: %w", err)
+	}
+	if removed == 0 {
+		return errors.New("no digest is scheduled")
+	}
+	fmt.Println("Digest unscheduled")
+	return nil
+}
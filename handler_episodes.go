@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jjboykin/gator/internal/database"
+)
+
+func handlerEpisodes(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet("episodes", flag.ContinueOnError)
+	feedURL := fs.String("feed", "", "only list episodes of this feed URL")
+	limit := fs.Int("limit", 20, "maximum number of episodes to list")
+	args, err := parseCommandFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errors.New("too many command args given")
+	}
+
+	episodes, err := s.db.GetEpisodesForUser(context.Background(), database.GetEpisodesForUserParams{
+		UserID:  user.ID,
+		Limit:   int32(*limit),
+		FeedUrl: sql.NullString{String: *feedURL, Valid: *feedURL != ""},
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't get episodes: %w", err)
+	}
+
+	for _, episode := range episodes {
+		fmt.Printf("%s from %s\n", episode.PublishedAt.Format("2006-01-02"), episode.FeedName)
+		fmt.Printf("--- %s ---\n", episode.Title)
+
+		details := episodeDetails(episode.Season, episode.Episode, episode.DurationSeconds)
+		if episode.Length > 0 {
+			details = append(details, formatBytes(episode.Length))
+		}
+		if len(details) > 0 {
+			fmt.Printf("    %s\n", strings.Join(details, ", "))
+		}
+		fmt.Printf("Media: %s (%s)\n", episode.EnclosureUrl, episode.MimeType)
+		fmt.Printf("ID: %s\n", episode.PostID)
+		fmt.Println("==================================================")
+	}
+	return nil
+}
+
+// printEnclosures prints the media attachments of a post, for browse.
+func printEnclosures(s *state, userID, postID uuid.UUID) error {
+	enclosures, err := s.db.GetEnclosuresForPost(context.Background(), database.GetEnclosuresForPostParams{
+		PostID: postID,
+		UserID: userID,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't get enclosures: %w", err)
+	}
+	for _, enclosure := range enclosures {
+		size := ""
+		if enclosure.Length > 0 {
+			size = ", " + formatBytes(enclosure.Length)
+		}
+		fmt.Printf("Media: %s (%s%s)\n", enclosure.Url, enclosure.MimeType, size)
+	}
+
+	episode, err := s.db.GetPodcastEpisode(context.Background(), postID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't get podcast details: %w", err)
+	}
+	details := episodeDetails(episode.Season, episode.Episode, episode.DurationSeconds)
+	if episode.Explicit.Valid && episode.Explicit.Bool {
+		details = append(details, "explicit")
+	}
+	if len(details) > 0 {
+		fmt.Printf("Podcast: %s\n", strings.Join(details, ", "))
+	}
+	if episode.ImageUrl.Valid {
+		fmt.Printf("Image: %s\n", episode.ImageUrl.String)
+	}
+	return nil
+}
+
+func episodeDetails(season, episode, durationSeconds sql.NullInt32) []string {
+	var details []string
+	if season.Valid {
+		details = append(details, fmt.Sprintf("season %d", season.Int32))
+	}
+	if episode.Valid {
+		details = append(details, fmt.Sprintf("episode %d", episode.Int32))
+	}
+	if durationSeconds.Valid {
+		details = append(details, (time.Duration(durationSeconds.Int32) * time.Second).String())
+	}
+	return details
+}
+
+func handlerDownload(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet("download", flag.ContinueOnError)
+	dir := fs.String("dir", ".", "directory to save the media file in")
+	maxSizeMB := fs.Int64("max-size-mb", 1024, "refuse files larger than this many megabytes")
+	index := fs.Int("index", 1, "which enclosure to download when a post has several")
+	args, err := parseCommandFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errors.New("usage: download [--dir <dir>] [--max-size-mb <n>] <post-id>")
+	}
+
+	postID, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid post id: %w", err)
+	}
+	enclosures, err := s.db.GetEnclosuresForPost(context.Background(), database.GetEnclosuresForPostParams{
+		PostID: postID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't get enclosures: %w", err)
+	}
+	if len(enclosures) == 0 {
+		return errors.New("post has no media to download")
+	}
+	if *index < 1 || *index > len(enclosures) {
+		return fmt.Errorf("post has %d enclosures", len(enclosures))
+	}
+	enclosure := enclosures[*index-1]
+
+	maxSize := *maxSizeMB << 20
+	if enclosure.Length > maxSize {
+		return fmt.Errorf("%s is %s, over the %d MB limit", enclosure.Url, formatBytes(enclosure.Length), *maxSizeMB)
+	}
+
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		return err
+	}
+	target := filepath.Join(*dir, mediaFileName(enclosure.Url, postID))
+	if _, err := os.Stat(target); err == nil {
+		fmt.Printf("Already downloaded: %s\n", target)
+		return nil
+	}
+
+	n, err := downloadWithResume(context.Background(), s.fetcher().HTTPClient(), enclosure.Url, target, maxSize)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Saved %s (%s)\n", target, formatBytes(n))
+	return nil
+}
+
+// downloadWithResume downloads rawURL to target via target.part, resuming
+// a previous partial download with a Range request when the server
+// supports it.
+func downloadWithResume(ctx context.Context, client *http.Client, rawURL, target string, maxSize int64) (int64, error) {
+	partial := target + ".part"
+
+	var offset int64
+	if info, err := os.Stat(partial); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != offset {
+			if offset == 0 {
+				return 0, fmt.Errorf("server sent a range that wasn't asked for: %q", resp.Header.Get("Content-Range"))
+			}
+			// Appending a range that starts elsewhere would corrupt the
+			// file, so throw away what we have and start over.
+			fmt.Println("Server didn't resume where asked, restarting download")
+			resp.Body.Close()
+			if err := os.Remove(partial); err != nil {
+				return 0, err
+			}
+			return downloadWithResume(ctx, client, rawURL, target, maxSize)
+		}
+		flags |= os.O_APPEND
+		fmt.Printf("Resuming at %s\n", formatBytes(offset))
+	case http.StatusOK:
+		flags |= os.O_TRUNC
+		offset = 0
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The range starts at the end of the file when the partial file
+		// is already complete. Otherwise it is longer than the file or
+		// the server won't say, and neither can be trusted.
+		if total, ok := contentRangeTotal(resp.Header.Get("Content-Range")); ok && total == offset {
+			return offset, os.Rename(partial, target)
+		}
+		if offset == 0 {
+			return 0, fmt.Errorf("unexpected HTTP status: %d", resp.StatusCode)
+		}
+		fmt.Println("Server can't resume the partial download, restarting download")
+		resp.Body.Close()
+		if err := os.Remove(partial); err != nil {
+			return 0, err
+		}
+		return downloadWithResume(ctx, client, rawURL, target, maxSize)
+	default:
+		return 0, fmt.Errorf("unexpected HTTP status: %d", resp.StatusCode)
+	}
+
+	if resp.ContentLength > 0 && offset+resp.ContentLength > maxSize {
+		return 0, fmt.Errorf("file is %s, over the size limit", formatBytes(offset+resp.ContentLength))
+	}
+
+	f, err := os.OpenFile(partial, flags, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	// Read one byte past the limit so an oversized body is detected.
+	written, err := io.Copy(f, io.LimitReader(resp.Body, maxSize-offset+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	total := offset + written
+	if err != nil {
+		return total, fmt.Errorf("download interrupted at %s, run again to resume: %w", formatBytes(total), err)
+	}
+	if total > maxSize {
+		os.Remove(partial)
+		return total, errors.New("file exceeds the size limit")
+	}
+
+	return total, os.Rename(partial, target)
+}
+
+// contentRangeStart returns the first byte position of a Content-Range
+// header such as "bytes 100-199/200".
+func contentRangeStart(header string) (int64, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return 0, false
+	}
+	first, _, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, false
+	}
+	return start, true
+}
+
+// contentRangeTotal returns the complete length from a Content-Range
+// header such as "bytes */200", which is how a 416 response reports it.
+func contentRangeTotal(header string) (int64, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return 0, false
+	}
+	_, last, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, false
+	}
+	total, err := strconv.ParseInt(strings.TrimSpace(last), 10, 64)
+	if err != nil || total < 0 {
+		return 0, false
+	}
+	return total, true
+}
+
+// mediaFileName derives a safe local file name from a media URL.
+func mediaFileName(rawURL string, postID uuid.UUID) string {
+	name := ""
+	if u, err := url.Parse(rawURL); err == nil {
+		name = path.Base(u.Path)
+	}
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == "/" || name == ".." {
+		name = postID.String()
+	}
+	return name
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
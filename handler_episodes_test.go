@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDownloadWithResume(t *testing.T) {
+	const file = "0123456789abcdefghij"
+
+	for _, tt := range []struct {
+		name    string
+		partial string
+		// skew moves the start of the range the server answers with.
+		skew int
+		// unsatisfiable is the Content-Range of a 416 response.
+		unsatisfiable string
+	}{
+		{"fresh download", "", 0, ""},
+		{"resume", "0123456789", 0, ""},
+		{"server resumes elsewhere", "0123456789", -5, ""},
+		{"partial already complete", file, 0, "bytes */20"},
+		{"partial longer than the file", file + "XYZ", 0, "bytes */20"},
+		{"416 without the length", file, 0, ""},
+		{"416 with a different length", file, 0, "bytes */25"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				rangeHeader := r.Header.Get("Range")
+				if rangeHeader == "" {
+					w.Write([]byte(file))
+					return
+				}
+				start, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rangeHeader, "bytes="), "-"))
+				if start >= len(file) {
+					if tt.unsatisfiable != "" {
+						w.Header().Set("Content-Range", tt.unsatisfiable)
+					}
+					w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+					return
+				}
+				start += tt.skew
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(file)-1, len(file)))
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write([]byte(file[start:]))
+			}))
+			defer server.Close()
+
+			target := filepath.Join(t.TempDir(), "episode.mp3")
+			if tt.partial != "" {
+				if err := os.WriteFile(target+".part", []byte(tt.partial), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			n, err := downloadWithResume(context.Background(), server.Client(), server.URL, target, 1<<20)
+			if err != nil {
+				t.Fatalf("downloadWithResume: %v", err)
+			}
+			got, err := os.ReadFile(target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != file || n != int64(len(file)) {
+				t.Errorf("downloaded %d bytes %q, want %q", n, got, file)
+			}
+		})
+	}
+}
+
+func TestContentRangeStart(t *testing.T) {
+	for _, tt := range []struct {
+		header string
+		start  int64
+		ok     bool
+	}{
+		{"bytes 100-199/200", 100, true},
+		{"bytes 0-0/*", 0, true},
+		{"bytes */200", 0, false},
+		{"items 1-2/3", 0, false},
+		{"", 0, false},
+	} {
+		start, ok := contentRangeStart(tt.header)
+		if start != tt.start || ok != tt.ok {
+			t.Errorf("contentRangeStart(%q) = %d, %v, want %d, %v", tt.header, start, ok, tt.start, tt.ok)
+		}
+	}
+}
+
+func TestContentRangeTotal(t *testing.T) {
+	for _, tt := range []struct {
+		header string
+		total  int64
+		ok     bool
+	}{
+		{"bytes */200", 200, true},
+		{"bytes 100-199/200", 200, true},
+		{"bytes 0-0/*", 0, false},
+		{"bytes */-1", 0, false},
+		{"items */3", 0, false},
+		{"", 0, false},
+	} {
+		total, ok := contentRangeTotal(tt.header)
+		if total != tt.total || ok != tt.ok {
+			t.Errorf("contentRangeTotal(%q) = %d, %v, want %d, %v", tt.header, total, ok, tt.total, tt.ok)
+		}
+	}
+}
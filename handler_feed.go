@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jjboykin/gator/internal/database"
+)
+
+func handlerFeed(s *state, cmd command) error {
+	if len(cmd.args) == 0 {
+		return errors.New("usage: feed set-interval <feed_url> <duration|auto> | feed set-fulltext <feed_url> <on|off> | feed revive <feed_url>")
+	}
+
+	switch cmd.args[0] {
+	case "set-interval":
+		return handlerFeedSetInterval(s, cmd.args[1:])
+	case "set-fulltext":
+		return handlerFeedSetFulltext(s, cmd.args[1:])
+	case "revive":
+		return handlerFeedRevive(s, cmd.args[1:])
+	default:
+		return fmt.Errorf("unknown feed subcommand: %s", cmd.args[0])
+	}
+}
+
+// handlerFeedSetInterval pins a feed's polling interval, or hands it back
+// to the adaptive scheduler with "auto".
+func handlerFeedSetInterval(s *state, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: feed set-interval <feed_url> <duration|auto>")
+	}
+
+	feed, err := lookupFeed(context.Background(), s, args[0])
+	if err != nil {
+		return fmt.Errorf("couldn't find feed %s: %w", args[0], err)
+	}
+
+	override := sql.NullInt32{}
+	interval := time.Duration(feed.FetchIntervalSeconds) * time.Second
+	if args[1] != "auto" {
+		interval, err = time.ParseDuration(args[1])
+		if err != nil {
+			return err
+		}
+		if interval < time.Minute {
+			return errors.New("interval must be at least 1m")
+		}
+		override = sql.NullInt32{Int32: int32(interval / time.Second), Valid: true}
+	}
+
+	next := time.Now().Add(interval)
+	if feed.LastFetchedAt.Valid {
+		next = feed.LastFetchedAt.Time.Add(interval)
+	}
+
+	err = s.db.SetFeedIntervalOverride(context.Background(), database.SetFeedIntervalOverrideParams{
+		ID:                           feed.ID,
+		FetchIntervalOverrideSeconds: override,
+		NextFetchAt:                  sql.NullTime{Time: next, Valid: true},
+		UpdatedAt:                    time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if override.Valid {
+		fmt.Printf("%s will be fetched every %s, next at %s\n", feed.Name, interval, next.Format(time.RFC1123))
+	} else {
+		fmt.Printf("%s is back on the adaptive schedule, next fetch at %s\n", feed.Name, next.Format(time.RFC1123))
+	}
+	return nil
+}
+
+// handlerFeedSetFulltext turns full-text extraction of new posts on or off
+// for a feed.
+func handlerFeedSetFulltext(s *state, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: feed set-fulltext <feed_url> <on|off>")
+	}
+
+	var fulltext bool
+	switch args[1] {
+	case "on":
+		fulltext = true
+	case "off":
+	default:
+		return fmt.Errorf("expected on or off, got %q", args[1])
+	}
+
+	feed, err := lookupFeed(context.Background(), s, args[0])
+	if err != nil {
+		return fmt.Errorf("couldn't find feed %s: %w", args[0], err)
+	}
+
+	err = s.db.SetFeedFulltext(context.Background(), database.SetFeedFulltextParams{
+		ID:        feed.ID,
+		Fulltext:  fulltext,
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Full-text extraction for %s is %s\n", feed.Name, args[1])
+	return nil
+}
+
+// handlerFeedRevive resumes polling a feed that was marked dead after
+// answering 410 Gone.
+func handlerFeedRevive(s *state, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: feed revive <feed_url>")
+	}
+
+	feed, err := lookupFeed(context.Background(), s, args[0])
+	if err != nil {
+		return fmt.Errorf("couldn't find feed %s: %w", args[0], err)
+	}
+	if !feed.DeadAt.Valid {
+		return fmt.Errorf("%s is not dead", feed.Name)
+	}
+
+	err = s.db.ReviveFeed(context.Background(), database.ReviveFeedParams{
+		ID:          feed.ID,
+		NextFetchAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s will be fetched again\n", feed.Name)
+	return nil
+}
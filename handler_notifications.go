@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jjboykin/gator/internal/database"
+)
+
+// handlerNotifications lists the user's unread notifications, such as
+// feeds that have gone away, and marks them read.
+func handlerNotifications(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet("notifications", flag.ContinueOnError)
+	all := fs.Bool("all", false, "include notifications that were already read")
+	limit := fs.Int("limit", 50, "maximum number of notifications to list")
+	args, err := parseCommandFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errors.New("too many command args given")
+	}
+
+	ctx := context.Background()
+	notifications, err := s.db.GetNotificationsForUser(ctx, database.GetNotificationsForUserParams{
+		UserID:      user.ID,
+		Limit:       int32(*limit),
+		IncludeRead: *all,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't get notifications: %w", err)
+	}
+	if len(notifications) == 0 {
+		fmt.Println("No new notifications")
+		return nil
+	}
+
+	// Only what was shown is marked read; anything past the limit or
+	// arriving meanwhile stays unread.
+	var shown []uuid.UUID
+	for _, n := range notifications {
+		marker := "*"
+		if n.ReadAt.Valid {
+			marker = " "
+		} else {
+			shown = append(shown, n.ID)
+		}
+		fmt.Printf("%s %s  %s\n", marker, n.CreatedAt.Format("2006-01-02 15:04"), n.Message)
+	}
+	if len(shown) == 0 {
+		return nil
+	}
+
+	return s.db.MarkNotificationsRead(ctx, database.MarkNotificationsReadParams{
+		ReadAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UserID: user.ID,
+		Ids:    shown,
+	})
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jjboykin/gator/internal/database"
+	"github.com/jjboykin/gator/internal/opml"
+)
+
+func handlerOPML(s *state, cmd command, user database.User) error {
+	if len(cmd.args) == 0 {
+		return errors.New("usage: opml import <file> | opml export [--output <file>]")
+	}
+
+	switch cmd.args[0] {
+	case "import":
+		return handlerOPMLImport(s, cmd.args[1:], user)
+	case "export":
+		return handlerOPMLExport(s, cmd.args[1:], user)
+	default:
+		return fmt.Errorf("unknown opml subcommand: %s", cmd.args[0])
+	}
+}
+
+// handlerOPMLImport follows every feed of an OPML file, adding the feeds
+// gator doesn't know yet. Folders become categories; feeds that are
+// already followed are moved into the folder they are in.
+func handlerOPMLImport(s *state, args []string, user database.User) error {
+	if len(args) != 1 {
+		return errors.New("usage: opml import <file>")
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	doc, err := opml.Parse(data)
+	if err != nil {
+		return fmt.Errorf("couldn't parse %s: %w", args[0], err)
+	}
+
+	ctx := context.Background()
+	tree, err := loadCategories(ctx, s, user.ID)
+	if err != nil {
+		return err
+	}
+	follows, err := s.db.GetFeedFollowsForUser(ctx, user.Name)
+	if err != nil {
+		return fmt.Errorf("couldn't get followed feeds: %w", err)
+	}
+	following := make(map[uuid.UUID]bool)
+	for _, follow := range follows {
+		following[follow.FeedID] = true
+	}
+
+	var followed, moved, failed int
+	for _, entry := range doc.Feeds() {
+		alreadyFollowed, err := importOPMLFeed(ctx, s, user, tree, following, entry)
+		switch {
+		case err != nil:
+			fmt.Printf("Error: %s: %v\n", entry.XMLURL, err)
+			failed++
+		case alreadyFollowed:
+			moved++
+		default:
+			followed++
+		}
+	}
+
+	fmt.Printf("Followed %d feeds, updated %d already followed, %d failed\n", followed, moved, failed)
+	return nil
+}
+
+// importOPMLFeed follows one feed of an OPML file and reports whether it
+// was already followed.
+func importOPMLFeed(ctx context.Context, s *state, user database.User, tree *categoryTree, following map[uuid.UUID]bool, entry opml.Feed) (bool, error) {
+	categoryID := uuid.NullUUID{}
+	if len(entry.Folders) > 0 {
+		names := make([]string, len(entry.Folders))
+		for i, folder := range entry.Folders {
+			// A slash in a folder name would read as a subcategory.
+			names[i] = strings.TrimSpace(strings.ReplaceAll(folder, categorySeparator, "-"))
+			if names[i] == "" {
+				names[i] = "Untitled"
+			}
+		}
+		category, err := tree.ensure(ctx, s, user.ID, names)
+		if err != nil {
+			return false, err
+		}
+		categoryID = uuid.NullUUID{UUID: category.ID, Valid: true}
+	}
+
+	feed, err := lookupFeed(ctx, s, entry.XMLURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		name := entry.Title
+		if name == "" {
+			name = entry.XMLURL
+		}
+		feed, err = s.db.CreateFeed(ctx, database.CreateFeedParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			Name:      name,
+			Url:       entry.XMLURL,
+			UserID:    user.ID,
+		})
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if following[feed.ID] {
+		if !categoryID.Valid {
+			return true, nil
+		}
+		return true, s.db.SetFeedFollowCategory(ctx, database.SetFeedFollowCategoryParams{
+			UserID:     user.ID,
+			FeedID:     feed.ID,
+			CategoryID: categoryID,
+			UpdatedAt:  time.Now(),
+		})
+	}
+
+	_, err = s.db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
+		ID:         uuid.New(),
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		UserID:     user.ID,
+		FeedID:     feed.ID,
+		CategoryID: categoryID,
+	})
+	if err != nil {
+		return false, err
+	}
+	following[feed.ID] = true
+	return false, nil
+}
+
+// handlerOPMLExport writes the followed feeds as OPML, with categories as
+// nested folders.
+func handlerOPMLExport(s *state, args []string, user database.User) error {
+	fs := flag.NewFlagSet("opml export", flag.ContinueOnError)
+	output := fs.String("output", "", "write to this file instead of standard output")
+	args, err := parseCommandFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errors.New("too many command args given")
+	}
+
+	ctx := context.Background()
+	tree, err := loadCategories(ctx, s, user.ID)
+	if err != nil {
+		return err
+	}
+	follows, err := s.db.GetFeedFollowsForUser(ctx, user.Name)
+	if err != nil {
+		return fmt.Errorf("couldn't get followed feeds: %w", err)
+	}
+
+	byCategory := make(map[uuid.UUID][]opml.Outline)
+	for _, follow := range follows {
+		byCategory[follow.CategoryID.UUID] = append(byCategory[follow.CategoryID.UUID], opml.Outline{
+			Text:   follow.FeedName,
+			Title:  follow.FeedName,
+			Type:   "rss",
+			XMLURL: follow.FeedUrl,
+		})
+	}
+	var outlines func(id uuid.UUID) []opml.Outline
+	outlines = func(id uuid.UUID) []opml.Outline {
+		out := byCategory[id]
+		for _, child := range tree.children[id] {
+			out = append(out, opml.Outline{Text: child.Name, Title: child.Name, Outlines: outlines(child.ID)})
+		}
+		return out
+	}
+
+	doc := opml.Document{
+		Head: opml.Head{
+			Title:       fmt.Sprintf("gator subscriptions of %s", user.Name),
+			DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+		},
+		Body: opml.Body{Outlines: outlines(uuid.Nil)},
+	}
+
+	if *output == "" {
+		return doc.Write(os.Stdout)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := doc.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jjboykin/gator/internal/database"
+	"github.com/jjboykin/gator/internal/feedgen"
+)
+
+// defaultPublishedPosts and maxPublishedPosts bound how many posts a
+// published feed carries.
+const (
+	defaultPublishedPosts = 50
+	maxPublishedPosts     = 500
+)
+
+// publishFilter selects the posts of a published feed.
+type publishFilter struct {
+	Category string
+	Tag      string
+	Limit    int
+}
+
+// filterError is a publishFilter that doesn't make sense for the user,
+// as opposed to a failure to look it up.
+type filterError struct {
+	err error
+}
+
+func (e *filterError) Error() string { return e.err.Error() }
+func (e *filterError) Unwrap() error { return e.err }
+
+// query returns the parameters selecting the filtered posts.
+func (f publishFilter) query(ctx context.Context, s *state, user database.User) (database.GetPostsForUserParams, error) {
+	params := database.GetPostsForUserParams{ID: user.ID, Limit: defaultPublishedPosts}
+	if f.Limit != 0 {
+		if f.Limit < 0 || f.Limit > maxPublishedPosts {
+			return params, &filterError{fmt.Errorf("limit must be between 1 and %d", maxPublishedPosts)}
+		}
+		params.Limit = int32(f.Limit)
+	}
+	if f.Category != "" {
+		tree, err := loadCategories(ctx, s, user.ID)
+		if err != nil {
+			return params, err
+		}
+		found, err := tree.find(f.Category)
+		if err != nil {
+			return params, &filterError{err}
+		}
+		params.FilterCategories = true
+		params.CategoryIds = tree.subtree(found.ID)
+	}
+	if f.Tag != "" {
+		tag, err := normalizeTag(f.Tag)
+		if err != nil {
+			return params, &filterError{err}
+		}
+		params.Tag = sql.NullString{String: tag, Valid: true}
+	}
+	return params, nil
+}
+
+// title names the feed after the user and the filters.
+func (f publishFilter) title(user database.User) string {
+	title := fmt.Sprintf("%s's feeds on gator", user.Name)
+	if f.Category != "" {
+		title += " in " + f.Category
+	}
+	if f.Tag != "" {
+		title += " tagged " + f.Tag
+	}
+	return title
+}
+
+// values encodes the filter as the query string of a published feed URL.
+func (f publishFilter) values() url.Values {
+	v := url.Values{}
+	if f.Category != "" {
+		v.Set("category", f.Category)
+	}
+	if f.Tag != "" {
+		v.Set("tag", f.Tag)
+	}
+	if f.Limit != 0 && f.Limit != defaultPublishedPosts {
+		v.Set("limit", strconv.Itoa(f.Limit))
+	}
+	return v
+}
+
+// buildPublishedFeed collects the latest posts of the feeds user follows,
+// newest first, leaving out hidden ones. Items are identified by post ID,
+// which doesn't change when a post is edited or its feed moves.
+func buildPublishedFeed(ctx context.Context, s *state, user database.User, filter publishFilter, selfURL string) (*feedgen.Feed, error) {
+	params, err := filter.query(ctx, s, user)
+	if err != nil {
+		return nil, err
+	}
+	posts, err := s.db.GetPostsForUser(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get posts for user: %w", err)
+	}
+
+	feed := &feedgen.Feed{
+		Title:       filter.title(user),
+		Description: fmt.Sprintf("Posts from the feeds %s follows, combined by gator", user.Name),
+		SelfURL:     selfURL,
+		ID:          "urn:uuid:" + user.ID.String(),
+		Author:      user.Name,
+	}
+	for _, post := range posts {
+		item := feedgen.Item{
+			ID:         "urn:uuid:" + post.ID.String(),
+			Title:      post.Title,
+			Link:       post.Url,
+			Summary:    post.Description.String,
+			Content:    postBody(sql.NullString{}, post.Content, post.ExtractedContent),
+			Author:     post.Author.String,
+			Published:  post.PublishedAt,
+			Categories: post.Categories,
+			Source:     &feedgen.Source{Title: post.FeedName, URL: post.FeedUrl},
+		}
+		// updated_at only moves past created_at when the article is
+		// extracted later, which is a real change to the item. Posts
+		// without a date are stored as published at the zero time; the
+		// time they were stored stands in rather than 0001-01-01.
+		switch {
+		case post.UpdatedAt.After(post.CreatedAt):
+			item.Updated = post.UpdatedAt
+		case post.PublishedAt.IsZero():
+			item.Updated = post.CreatedAt
+		}
+		enclosures, err := s.db.GetEnclosuresForPost(ctx, database.GetEnclosuresForPostParams{
+			PostID: post.ID,
+			UserID: user.ID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get enclosures: %w", err)
+		}
+		for _, e := range enclosures {
+			item.Enclosures = append(item.Enclosures, feedgen.Enclosure{URL: e.Url, Type: e.MimeType, Length: e.Length})
+		}
+		feed.Items = append(feed.Items, item)
+
+		// The feed changes whenever a post is stored or updated, even one
+		// published long ago.
+		if post.UpdatedAt.After(feed.Updated) {
+			feed.Updated = post.UpdatedAt
+		}
+	}
+	return feed, nil
+}
+
+// publishedFeedURL returns the URL `gator serve` publishes a user's feed
+// at, or "" when public_url isn't set.
+func publishedFeedURL(s *state, token, format string, filter publishFilter) string {
+	if s.configPtr.PublicURL == "" {
+		return ""
+	}
+	u := strings.TrimRight(s.configPtr.PublicURL, "/") + "/u/" + token + "/feed." + format
+	if q := filter.values().Encode(); q != "" {
+		u += "?" + q
+	}
+	return u
+}
+
+func newFeedToken() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// handlerPublish writes the user's combined feed; `publish enable`,
+// `publish rotate` and `publish disable` manage the URL `gator serve`
+// publishes it at.
+func handlerPublish(s *state, cmd command, user database.User) error {
+	if len(cmd.args) > 0 {
+		switch cmd.args[0] {
+		case "enable":
+			return handlerPublishToken(s, cmd.args[1:], user, false)
+		case "rotate":
+			return handlerPublishToken(s, cmd.args[1:], user, true)
+		case "disable":
+			return handlerPublishDisable(s, cmd.args[1:], user)
+		}
+	}
+
+	fs := flag.NewFlagSet("publish", flag.ContinueOnError)
+	format := fs.String("format", feedgen.FormatRSS, "feed format: "+strings.Join(feedgen.Formats, ", "))
+	category := fs.String("category", "", "only publish posts from feeds in this category or below it")
+	tag := fs.String("tag", "", "only publish posts with this tag")
+	limit := fs.Int("limit", defaultPublishedPosts, "number of posts to publish")
+	self := fs.String("self", "", "URL the document will be served at (default: its serve URL, if enabled)")
+	output := fs.String("output", "", "write the feed to this file instead of standard output")
+	args, err := parseCommandFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errors.New("usage: publish [--format rss|atom|json] [--category <path>] [--tag <tag>] [--limit <n>] [--self <url>] [--output <file>] | publish enable | publish rotate | publish disable")
+	}
+	if feedgen.ContentType(*format) == "" {
+		return fmt.Errorf("unknown feed format %q; use %s", *format, strings.Join(feedgen.Formats, ", "))
+	}
+
+	ctx := context.Background()
+	filter := publishFilter{Category: *category, Tag: *tag, Limit: *limit}
+	selfURL := *self
+	if selfURL == "" {
+		token, err := s.db.GetFeedToken(ctx, user.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("couldn't get feed token: %w", err)
+		}
+		if err == nil {
+			selfURL = publishedFeedURL(s, token.Token, *format, filter)
+		}
+	}
+
+	feed, err := buildPublishedFeed(ctx, s, user, filter, selfURL)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := feedgen.Write(&buf, *format, feed); err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	if err := os.WriteFile(*output, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d posts to %s\n", len(feed.Items), *output)
+	return nil
+}
+
+// handlerPublishToken prints the URLs of the user's published feed,
+// creating its token if needed, or replacing it when rotate is set so the
+// old URLs stop working.
+func handlerPublishToken(s *state, args []string, user database.User, rotate bool) error {
+	if len(args) != 0 {
+		return errors.New("too many command args given")
+	}
+	ctx := context.Background()
+	token, err := s.db.GetFeedToken(ctx, user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("couldn't get feed token: %w", err)
+	}
+	if err != nil || rotate {
+		token, err = s.db.SetFeedToken(ctx, database.SetFeedTokenParams{
+			UserID:    user.ID,
+			CreatedAt: time.Now(),
+			Token:     newFeedToken(),
+		})
+		if err != nil {
+			return fmt.Errorf("couldn't save feed token: %w", err)
+		}
+	}
+
+	if s.configPtr.PublicURL == "" {
+		fmt.Printf("Published at /u/%s/feed.{%s} on `gator serve`\n", token.Token, strings.Join(feedgen.Formats, ","))
+		fmt.Println("Set public_url to print the full URLs")
+	} else {
+		for _, format := range feedgen.Formats {
+			fmt.Println(publishedFeedURL(s, token.Token, format, publishFilter{}))
+		}
+	}
+	fmt.Println("Narrow a feed down with ?category=<path>, ?tag=<tag> and ?limit=<n>. Anyone with the URL can read it; `gator publish rotate` replaces it")
+	return nil
+}
+
+func handlerPublishDisable(s *state, args []string, user database.User) error {
+	if len(args) != 0 {
+		return errors.New("too many command args given")
+	}
+	removed, err := s.db.DeleteFeedToken(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't remove feed token: %w", err)
+	}
+	if removed == 0 {
+		return errors.New("publishing isn't enabled")
+	}
+	fmt.Println("Published feed disabled")
+	return nil
+}
+
+func registerPublishRoutes(mux *http.ServeMux, s *state) {
+	mux.HandleFunc("GET /u/{token}/{file}", func(w http.ResponseWriter, r *http.Request) {
+		handlePublishedFeed(w, r, s)
+	})
+}
+
+// handlePublishedFeed serves /u/{token}/feed.{rss,atom,json}. Responses
+// carry an ETag and Last-Modified so pollers can ask for changes only.
+func handlePublishedFeed(w http.ResponseWriter, r *http.Request, s *state) {
+	format, ok := strings.CutPrefix(r.PathValue("file"), "feed.")
+	if !ok || feedgen.ContentType(format) == "" {
+		http.NotFound(w, r)
+		return
+	}
+	user, err := s.db.GetUserByFeedToken(r.Context(), r.PathValue("token"))
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		s.logger().Error("couldn't look up feed token", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := publishFilter{Category: query.Get("category"), Tag: query.Get("tag")}
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit == 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	feed, err := buildPublishedFeed(r.Context(), s, user, filter, requestURL(r, s))
+	var badFilter *filterError
+	if errors.As(err, &badFilter) {
+		http.Error(w, badFilter.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		s.logger().Error("couldn't build published feed", "user", user.Name, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if err := feedgen.Write(&buf, format, feed); err != nil {
+		s.logger().Error("couldn't write published feed", "user", user.Name, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	w.Header().Set("Content-Type", feedgen.ContentType(format))
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	http.ServeContent(w, r, "", feed.LastModified(), bytes.NewReader(buf.Bytes()))
+}
+
+// requestURL reconstructs the URL a request was made to, under
+// public_url when it is set since gator may sit behind a proxy.
+func requestURL(r *http.Request, s *state) string {
+	if s.configPtr.PublicURL != "" {
+		return strings.TrimRight(s.configPtr.PublicURL, "/") + r.URL.RequestURI()
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
@@ -0,0 +1,1036 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jjboykin/gator/internal/database"
+	"github.com/jjboykin/gator/internal/greader"
+	"github.com/jjboykin/gator/internal/password"
+)
+
+// Limits on how many items one Reader API request returns. Clients page
+// through longer streams with the continuation.
+const (
+	defaultReaderItems   = 20
+	maxReaderItemRefs    = 10000
+	maxReaderItemContent = 1000
+)
+
+// readerPrefix is where FreshRSS serves the API; clients set up for a
+// FreshRSS server append it to the server's URL.
+const readerPrefix = "/api/greader.php"
+
+// Purposes of the tokens signed with a user's password hash.
+const (
+	readerAuthToken = "auth"
+	readerEditToken = "edit"
+)
+
+// readerUser is the user a Reader API request is made for.
+type readerUser struct {
+	database.User
+	// passwordHash keys the user's tokens.
+	passwordHash string
+}
+
+type readerHandler func(w http.ResponseWriter, r *http.Request, s *state, user readerUser)
+
+// registerReaderRoutes serves the Google Reader API used by FreshRSS and
+// Miniflux, at the server root and under readerPrefix.
+func registerReaderRoutes(mux *http.ServeMux, s *state) {
+	api := http.NewServeMux()
+	api.HandleFunc("/accounts/ClientLogin", func(w http.ResponseWriter, r *http.Request) {
+		handleReaderLogin(w, r, s)
+	})
+	handle := func(pattern string, h readerHandler) {
+		api.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			user, ok := readerAuth(w, r, s)
+			if !ok {
+				return
+			}
+			if err := r.ParseForm(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if r.Method == http.MethodPost && !readerEditTokenValid(w, r, user) {
+				return
+			}
+			h(w, r, s, user)
+		})
+	}
+	handle("GET /reader/api/0/token", handleReaderToken)
+	handle("GET /reader/api/0/user-info", handleReaderUserInfo)
+	handle("GET /reader/api/0/tag/list", handleReaderTagList)
+	handle("GET /reader/api/0/subscription/list", handleReaderSubscriptionList)
+	handle("POST /reader/api/0/subscription/edit", handleReaderSubscriptionEdit)
+	handle("POST /reader/api/0/subscription/quickadd", handleReaderQuickAdd)
+	handle("GET /reader/api/0/unread-count", handleReaderUnreadCount)
+	handle("GET /reader/api/0/stream/items/ids", handleReaderItemIDs)
+	handle("/reader/api/0/stream/items/contents", handleReaderItemContents)
+	handle("GET /reader/api/0/stream/contents/{stream...}", handleReaderStreamContents)
+	handle("POST /reader/api/0/edit-tag", handleReaderEditTag)
+	handle("POST /reader/api/0/mark-all-as-read", handleReaderMarkAllAsRead)
+
+	mux.Handle("/accounts/ClientLogin", api)
+	mux.Handle("/reader/api/0/", api)
+	mux.Handle(readerPrefix+"/", http.StripPrefix(readerPrefix, api))
+}
+
+// handleReaderLogin checks a user's API password and answers with the
+// token the client sends with every other request.
+func handleReaderLogin(w http.ResponseWriter, r *http.Request, s *state) {
+	name, pass := r.FormValue("Email"), r.FormValue("Passwd")
+	user, err := s.db.GetUserByName(r.Context(), name)
+	var credentials database.ApiPassword
+	if err == nil {
+		credentials, err = s.db.GetAPIPassword(r.Context(), user.ID)
+	}
+	ok := false
+	if err == nil {
+		ok, err = password.Check(credentials.PasswordHash, pass)
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.logger().Error("reader login failed", "user", name, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		s.logger().Warn("reader login refused", "user", name, "remote_addr", r.RemoteAddr)
+		http.Error(w, "Error=BadAuthentication", http.StatusUnauthorized)
+		return
+	}
+
+	token := user.ID.String() + "/" + greader.Sign(credentials.PasswordHash, readerAuthToken, user.ID.String())
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "SID=%s\nLSID=%s\nAuth=%s\n", token, token, token)
+}
+
+// readerAuth resolves the user of a request from its auth token, or
+// answers 401.
+func readerAuth(w http.ResponseWriter, r *http.Request, s *state) (readerUser, bool) {
+	id, signature, _ := strings.Cut(greader.AuthToken(r), "/")
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		// No token, or not one we gave out.
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return readerUser{}, false
+	}
+	user, err := s.db.GetUser(r.Context(), userID)
+	if err == nil {
+		var credentials database.ApiPassword
+		credentials, err = s.db.GetAPIPassword(r.Context(), userID)
+		if err == nil && greader.Verify(signature, credentials.PasswordHash, readerAuthToken, id) {
+			return readerUser{User: user, passwordHash: credentials.PasswordHash}, true
+		}
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.logger().Error("reader authentication failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return readerUser{}, false
+	}
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	return readerUser{}, false
+}
+
+// readerEditTokenValid checks the T parameter of a write. Clients that
+// don't send one are let through, since the auth header can't be forged
+// by another site anyway.
+func readerEditTokenValid(w http.ResponseWriter, r *http.Request, user readerUser) bool {
+	token := r.FormValue("T")
+	if token == "" || greader.Verify(token, user.passwordHash, readerEditToken, user.ID.String()) {
+		return true
+	}
+	w.Header().Set("X-Reader-Google-Bad-Token", "true")
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	return false
+}
+
+func handleReaderToken(w http.ResponseWriter, r *http.Request, s *state, user readerUser) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, greader.Sign(user.passwordHash, readerEditToken, user.ID.String()))
+}
+
+func writeReaderOK(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, "OK")
+}
+
+func readerError(w http.ResponseWriter, r *http.Request, s *state, err error) {
+	s.logger().Error("reader request failed", "path", r.URL.Path, "error", err)
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+func handleReaderUserInfo(w http.ResponseWriter, r *http.Request, s *state, user readerUser) {
+	writeJSON(w, http.StatusOK, greader.UserInfo{
+		UserID:        user.ID.String(),
+		UserName:      user.Name,
+		UserProfileID: user.ID.String(),
+	})
+}
+
+// handleReaderTagList lists the starred state, categories as folders and
+// post tags as labels.
+func handleReaderTagList(w http.ResponseWriter, r *http.Request, s *state, user readerUser) {
+	tree, err := loadCategories(r.Context(), s, user.ID)
+	if err != nil {
+		readerError(w, r, s, err)
+		return
+	}
+	tags, err := s.db.GetTagCountsForUser(r.Context(), user.ID)
+	if err != nil {
+		readerError(w, r, s, err)
+		return
+	}
+
+	list := greader.TagList{Tags: []greader.Tag{{ID: greader.StateStarred}}}
+	folders := make(map[string]bool)
+	for id := range tree.byID {
+		path := tree.path(id)
+		folders[path] = true
+		list.Tags = append(list.Tags, greader.Tag{ID: greader.LabelID(path), Type: "folder"})
+	}
+	for _, tag := range tags {
+		if !folders[tag.Tag] {
+			list.Tags = append(list.Tags, greader.Tag{ID: greader.LabelID(tag.Tag), Type: "tag"})
+		}
+	}
+	labels := list.Tags[1:]
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].ID < labels[j].ID
+	})
+	writeJSON(w, http.StatusOK, list)
+}
+
+// siteURL guesses a feed's website from its URL; gator doesn't keep the
+// link a feed gives.
+func siteURL(feedURL string) string {
+	u, err := url.Parse(feedURL)
+	if err != nil || u.Host == "" {
+		return feedURL
+	}
+	return u.Scheme + "://" + u.Host + "/"
+}
+
+func handleReaderSubscriptionList(w http.ResponseWriter, r *http.Request, s *state, user readerUser) {
+	follows, err := s.db.GetFeedFollowsForUser(r.Context(), user.Name)
+	if err != nil {
+		readerError(w, r, s, err)
+		return
+	}
+	tree, err := loadCategories(r.Context(), s, user.ID)
+	if err != nil {
+		readerError(w, r, s, err)
+		return
+	}
+
+	list := greader.SubscriptionList{Subscriptions: []greader.Subscription{}}
+	for _, follow := range follows {
+		sub := greader.Subscription{
+			ID:         greader.FeedID(follow.FeedUrl),
+			Title:      follow.FeedName,
+			Categories: []greader.Category{},
+			URL:        follow.FeedUrl,
+			HTMLURL:    siteURL(follow.FeedUrl),
+		}
+		if follow.CategoryID.Valid {
+			path := tree.path(follow.CategoryID.UUID)
+			sub.Categories = append(sub.Categories, greader.Category{ID: greader.LabelID(path), Label: path})
+		}
+		list.Subscriptions = append(list.Subscriptions, sub)
+	}
+	sort.Slice(list.Subscriptions, func(i, j int) bool {
+		return strings.ToLower(list.Subscriptions[i].Title) < strings.ToLower(list.Subscriptions[j].Title)
+	})
+	writeJSON(w, http.StatusOK, list)
+}
+
+// readerFollow follows the feed at feedURL, creating the feed if gator
+// doesn't know it yet, and files it under category when one is given. It
+// returns the feed followed.
+func readerFollow(ctx context.Context, s *state, user readerUser, feedURL, title, category string) (database.Feed, error) {
+	if u, err := url.Parse(feedURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return database.Feed{}, fmt.Errorf("invalid feed url %q", feedURL)
+	}
+	feed, err := lookupFeed(ctx, s, feedURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		if title == "" {
+			title = feedURL
+		}
+		feed, err = s.db.CreateFeed(ctx, database.CreateFeedParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			Name:      title,
+			Url:       feedURL,
+			UserID:    user.ID,
+		})
+	}
+	if err != nil {
+		return database.Feed{}, err
+	}
+	categoryID, err := resolveCategory(ctx, s, user.ID, category)
+	if err != nil {
+		return database.Feed{}, err
+	}
+
+	follows, err := s.db.GetFeedFollowsForUser(ctx, user.Name)
+	if err != nil {
+		return database.Feed{}, err
+	}
+	for _, follow := range follows {
+		if follow.FeedID == feed.ID {
+			if !categoryID.Valid {
+				return feed, nil
+			}
+			return feed, s.db.SetFeedFollowCategory(ctx, database.SetFeedFollowCategoryParams{
+				UserID:     user.ID,
+				FeedID:     feed.ID,
+				CategoryID: categoryID,
+				UpdatedAt:  time.Now(),
+			})
+		}
+	}
+	_, err = s.db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
+		ID:         uuid.New(),
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		UserID:     user.ID,
+		FeedID:     feed.ID,
+		CategoryID: categoryID,
+	})
+	if err != nil {
+		return database.Feed{}, err
+	}
+
+	pushed, err := s.db.HasActiveWebSubSubscription(ctx, database.HasActiveWebSubSubscriptionParams{
+		FeedID: feed.ID,
+		Now:    time.Now(),
+	})
+	if err == nil && !pushed {
+		if err := subscribeToHub(ctx, s, feed); err != nil {
+			s.logger().Warn("websub subscription failed", "feed_url", feed.Url, "error", err)
+		}
+	}
+	return feed, nil
+}
+
+// labelName returns the name of a label stream ID, or "" for anything
+// else.
+func labelName(id string) string {
+	stream, err := greader.ParseStream(id)
+	if err != nil || stream.Kind != greader.KindLabel {
+		return ""
+	}
+	return stream.Value
+}
+
+// handleReaderSubscriptionEdit subscribes to, unsubscribes from or
+// refiles feeds. Feed names are shared by everyone who follows a feed, so
+// a new title is only used for feeds gator doesn't know yet.
+func handleReaderSubscriptionEdit(w http.ResponseWriter, r *http.Request, s *state, user readerUser) {
+	ctx := r.Context()
+	action := r.FormValue("ac")
+	add, remove := labelName(r.FormValue("a")), labelName(r.FormValue("r"))
+	streams := r.Form["s"]
+	if len(streams) == 0 {
+		http.Error(w, "missing stream", http.StatusBadRequest)
+		return
+	}
+
+	for _, id := range streams {
+		stream, err := greader.ParseStream(id)
+		if err != nil || stream.Kind != greader.KindFeed {
+			http.Error(w, fmt.Sprintf("not a feed stream: %q", id), http.StatusBadRequest)
+			return
+		}
+		switch action {
+		case "subscribe":
+			_, err = readerFollow(ctx, s, user, stream.Value, r.FormValue("t"), add)
+		case "unsubscribe":
+			var feed database.Feed
+			feed, err = lookupFeed(ctx, s, stream.Value)
+			if err == nil {
+				err = s.db.DeleteFeedFollow(ctx, database.DeleteFeedFollowParams{UserID: user.ID, FeedID: feed.ID})
+			}
+		case "edit":
+			err = readerRefile(ctx, s, user, stream.Value, add, remove)
+		default:
+			http.Error(w, fmt.Sprintf("unknown action %q", action), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, fmt.Sprintf("unknown feed %q", stream.Value), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			readerError(w, r, s, err)
+			return
+		}
+	}
+	writeReaderOK(w)
+}
+
+// readerRefile moves a followed feed into the category add, or out of the
+// category remove if that is where it is.
+func readerRefile(ctx context.Context, s *state, user readerUser, feedURL, add, remove string) error {
+	feed, err := lookupFeed(ctx, s, feedURL)
+	if err != nil {
+		return err
+	}
+	var categoryID uuid.NullUUID
+	switch {
+	case add != "":
+		categoryID, err = resolveCategory(ctx, s, user.ID, add)
+		if err != nil {
+			return err
+		}
+	case remove != "":
+		follows, err := s.db.GetFeedFollowsForUser(ctx, user.Name)
+		if err != nil {
+			return err
+		}
+		tree, err := loadCategories(ctx, s, user.ID)
+		if err != nil {
+			return err
+		}
+		for _, follow := range follows {
+			if follow.FeedID == feed.ID && follow.CategoryID.Valid && tree.path(follow.CategoryID.UUID) != remove {
+				return nil
+			}
+		}
+	default:
+		return nil
+	}
+	return s.db.SetFeedFollowCategory(ctx, database.SetFeedFollowCategoryParams{
+		UserID:     user.ID,
+		FeedID:     feed.ID,
+		CategoryID: categoryID,
+		UpdatedAt:  time.Now(),
+	})
+}
+
+func handleReaderQuickAdd(w http.ResponseWriter, r *http.Request, s *state, user readerUser) {
+	query := strings.TrimSpace(r.FormValue("quickadd"))
+	feedURL := strings.TrimPrefix(query, "feed/")
+	feed, err := readerFollow(r.Context(), s, user, feedURL, "", "")
+	if err != nil {
+		s.logger().Warn("reader quickadd failed", "query", query, "error", err)
+		writeJSON(w, http.StatusOK, greader.QuickAddResult{Query: query})
+		return
+	}
+	writeJSON(w, http.StatusOK, greader.QuickAddResult{
+		NumResults: 1,
+		Query:      query,
+		StreamID:   greader.FeedID(feed.Url),
+		StreamName: feed.Name,
+	})
+}
+
+// handleReaderUnreadCount counts unread posts per feed, per category (its
+// own feeds and those of the categories below it) and in total.
+func handleReaderUnreadCount(w http.ResponseWriter, r *http.Request, s *state, user readerUser) {
+	rows, err := s.db.GetUnreadCounts(r.Context(), user.ID)
+	if err != nil {
+		readerError(w, r, s, err)
+		return
+	}
+	tree, err := loadCategories(r.Context(), s, user.ID)
+	if err != nil {
+		readerError(w, r, s, err)
+		return
+	}
+
+	type unread struct {
+		count  int64
+		newest time.Time
+	}
+	counts := make(map[string]*unread)
+	var order []string
+	add := func(id string, count int64, newest time.Time) {
+		c, ok := counts[id]
+		if !ok {
+			c = &unread{}
+			counts[id] = c
+			order = append(order, id)
+		}
+		c.count += count
+		if newest.After(c.newest) {
+			c.newest = newest
+		}
+	}
+	for _, row := range rows {
+		add(greader.StateReadingList, row.Count, row.NewestPublishedAt)
+		add(greader.FeedID(row.FeedUrl), row.Count, row.NewestPublishedAt)
+		if !row.CategoryID.Valid {
+			continue
+		}
+		for id := row.CategoryID.UUID; id != uuid.Nil; {
+			category, ok := tree.byID[id]
+			if !ok {
+				break
+			}
+			add(greader.LabelID(tree.path(id)), row.Count, row.NewestPublishedAt)
+			id = category.ParentID.UUID
+		}
+	}
+
+	result := greader.UnreadCounts{Max: maxReaderItemRefs, UnreadCounts: []greader.UnreadCount{}}
+	for _, id := range order {
+		result.UnreadCounts = append(result.UnreadCounts, greader.UnreadCount{
+			ID:                      id,
+			Count:                   counts[id].count,
+			NewestItemTimestampUsec: greader.Usec(counts[id].newest),
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// readerStream is a stream ID resolved to the posts it selects.
+type readerStream struct {
+	FeedID           uuid.NullUUID
+	FilterCategories bool
+	CategoryIds      []uuid.UUID
+	Tag              sql.NullString
+	Starred          bool
+	Read             bool
+	Unread           bool
+}
+
+// resolveReaderStream turns a stream ID into a filter. A label is the
+// category at that path if there is one, and a post tag otherwise.
+func resolveReaderStream(ctx context.Context, s *state, user readerUser, id string) (readerStream, error) {
+	var filter readerStream
+	if id == "" {
+		id = greader.StateReadingList
+	}
+	stream, err := greader.ParseStream(id)
+	if err != nil {
+		return filter, err
+	}
+	switch stream.Kind {
+	case greader.KindReadingList:
+	case greader.KindRead:
+		filter.Read = true
+	case greader.KindKeptUnread:
+		filter.Unread = true
+	case greader.KindStarred:
+		filter.Starred = true
+	case greader.KindFeed:
+		feed, err := lookupFeed(ctx, s, stream.Value)
+		if errors.Is(err, sql.ErrNoRows) {
+			return filter, fmt.Errorf("unknown feed %q", stream.Value)
+		}
+		if err != nil {
+			return filter, err
+		}
+		filter.FeedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	case greader.KindLabel:
+		tree, err := loadCategories(ctx, s, user.ID)
+		if err != nil {
+			return filter, err
+		}
+		if category, err := tree.find(stream.Value); err == nil {
+			filter.FilterCategories = true
+			filter.CategoryIds = tree.subtree(category.ID)
+			break
+		}
+		tag, err := normalizeTag(stream.Value)
+		if err != nil {
+			return filter, err
+		}
+		filter.Tag = sql.NullString{String: tag, Valid: true}
+	}
+	return filter, nil
+}
+
+// badReaderRequest is a request whose parameters don't make sense.
+type badReaderRequest struct {
+	err error
+}
+
+func (e *badReaderRequest) Error() string { return e.err.Error() }
+
+// readerItemRefs runs the stream query described by the request's s (or
+// the stream in the path), xt, it, ot, nt, r, n and c parameters.
+func readerItemRefs(r *http.Request, s *state, user readerUser, streamID string, maxItems int) ([]database.GetReaderItemRefsRow, string, error) {
+	ctx := r.Context()
+	filter, err := resolveReaderStream(ctx, s, user, streamID)
+	if err != nil {
+		return nil, "", &badReaderRequest{err}
+	}
+	for _, id := range r.Form["xt"] {
+		if stream, err := greader.ParseStream(id); err == nil && stream.Kind == greader.KindRead {
+			filter.Unread = true
+		}
+	}
+	for _, id := range r.Form["it"] {
+		stream, err := greader.ParseStream(id)
+		if err != nil {
+			continue
+		}
+		switch stream.Kind {
+		case greader.KindRead:
+			filter.Read = true
+		case greader.KindStarred:
+			filter.Starred = true
+		}
+	}
+
+	params := database.GetReaderItemRefsParams{
+		UserID:           user.ID,
+		FeedID:           filter.FeedID,
+		FilterCategories: filter.FilterCategories,
+		CategoryIds:      filter.CategoryIds,
+		Tag:              filter.Tag,
+		Starred:          filter.Starred,
+		Read:             filter.Read,
+		Unread:           filter.Unread,
+		OldestFirst:      r.FormValue("r") == "o",
+		Limit:            defaultReaderItems,
+	}
+	if ot := r.FormValue("ot"); ot != "" {
+		t, err := greader.ParseTime(ot)
+		if err != nil {
+			return nil, "", &badReaderRequest{err}
+		}
+		params.PublishedAfter = sql.NullTime{Time: t, Valid: true}
+	}
+	if nt := r.FormValue("nt"); nt != "" {
+		t, err := greader.ParseTime(nt)
+		if err != nil {
+			return nil, "", &badReaderRequest{err}
+		}
+		params.PublishedBefore = sql.NullTime{Time: t, Valid: true}
+	}
+	if n := r.FormValue("n"); n != "" {
+		count, err := strconv.Atoi(n)
+		if err != nil || count <= 0 {
+			return nil, "", &badReaderRequest{fmt.Errorf("invalid count %q", n)}
+		}
+		params.Limit = int32(min(count, maxItems))
+	}
+	if c := r.FormValue("c"); c != "" {
+		offset, err := strconv.Atoi(c)
+		if err != nil || offset < 0 {
+			return nil, "", &badReaderRequest{fmt.Errorf("invalid continuation %q", c)}
+		}
+		params.Offset = int32(offset)
+	}
+
+	refs, err := s.db.GetReaderItemRefs(ctx, params)
+	if err != nil {
+		return nil, "", err
+	}
+	continuation := ""
+	if len(refs) == int(params.Limit) {
+		continuation = strconv.Itoa(int(params.Offset + params.Limit))
+	}
+	return refs, continuation, nil
+}
+
+func writeReaderStreamError(w http.ResponseWriter, r *http.Request, s *state, err error) {
+	var bad *badReaderRequest
+	if errors.As(err, &bad) {
+		http.Error(w, bad.Error(), http.StatusBadRequest)
+		return
+	}
+	readerError(w, r, s, err)
+}
+
+func handleReaderItemIDs(w http.ResponseWriter, r *http.Request, s *state, user readerUser) {
+	refs, continuation, err := readerItemRefs(r, s, user, r.FormValue("s"), maxReaderItemRefs)
+	if err != nil {
+		writeReaderStreamError(w, r, s, err)
+		return
+	}
+	result := greader.ItemRefs{ItemRefs: []greader.ItemRef{}, Continuation: continuation}
+	for _, ref := range refs {
+		result.ItemRefs = append(result.ItemRefs, greader.ItemRef{
+			ID:              strconv.FormatInt(ref.ItemNumber, 10),
+			DirectStreamIDs: []string{greader.FeedID(ref.FeedUrl)},
+			TimestampUsec:   greader.Usec(ref.PublishedAt),
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// readerItems loads the items with the given numbers, as far as they are
+// from feeds user follows, in stream order.
+func readerItems(ctx context.Context, s *state, user readerUser, numbers []int64) ([]greader.Item, error) {
+	if len(numbers) == 0 {
+		return []greader.Item{}, nil
+	}
+	posts, err := s.db.GetReaderItems(ctx, database.GetReaderItemsParams{UserID: user.ID, ItemNumbers: numbers})
+	if err != nil {
+		return nil, err
+	}
+	tree, err := loadCategories(ctx, s, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	byNumber := make(map[int64]greader.Item, len(posts))
+	for _, post := range posts {
+		item := greader.Item{
+			ID:            greader.ItemID(post.ItemNumber),
+			CrawlTimeMsec: strconv.FormatInt(post.CreatedAt.UnixMilli(), 10),
+			TimestampUsec: greader.Usec(post.PublishedAt),
+			Published:     post.PublishedAt.Unix(),
+			Updated:       post.UpdatedAt.Unix(),
+			Title:         post.Title,
+			Author:        post.Author.String,
+			Canonical:     []greader.Link{{Href: post.Url}},
+			Alternate:     []greader.Link{{Href: post.Url, Type: "text/html"}},
+			Categories:    []string{greader.StateReadingList},
+			Origin: greader.Origin{
+				StreamID: greader.FeedID(post.FeedUrl),
+				Title:    post.FeedName,
+				HTMLURL:  siteURL(post.FeedUrl),
+			},
+			Summary: greader.Content{
+				Direction: "ltr",
+				Content:   postBody(post.Description, post.Content, post.ExtractedContent),
+			},
+		}
+		if post.ReadAt.Valid {
+			item.Categories = append(item.Categories, greader.StateRead)
+		}
+		if post.StarredAt.Valid {
+			item.Categories = append(item.Categories, greader.StateStarred)
+		}
+		if post.CategoryID.Valid {
+			item.Categories = append(item.Categories, greader.LabelID(tree.path(post.CategoryID.UUID)))
+		}
+		for _, tag := range post.Tags {
+			item.Categories = append(item.Categories, greader.LabelID(tag))
+		}
+		enclosures, err := s.db.GetEnclosuresForPost(ctx, database.GetEnclosuresForPostParams{
+			PostID: post.ID,
+			UserID: user.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range enclosures {
+			item.Enclosure = append(item.Enclosure, greader.Link{Href: e.Url, Type: e.MimeType, Length: e.Length})
+		}
+		byNumber[post.ItemNumber] = item
+	}
+
+	items := []greader.Item{}
+	for _, n := range numbers {
+		if item, ok := byNumber[n]; ok {
+			items = append(items, item)
+			delete(byNumber, n)
+		}
+	}
+	return items, nil
+}
+
+// readerItemNumbers parses the i parameters of a request.
+func readerItemNumbers(r *http.Request) ([]int64, error) {
+	var numbers []int64
+	for _, id := range r.Form["i"] {
+		n, err := greader.ParseItemID(id)
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil
+}
+
+func handleReaderItemContents(w http.ResponseWriter, r *http.Request, s *state, user readerUser) {
+	numbers, err := readerItemNumbers(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(numbers) > maxReaderItemContent {
+		http.Error(w, fmt.Sprintf("at most %d items at a time", maxReaderItemContent), http.StatusBadRequest)
+		return
+	}
+	items, err := readerItems(r.Context(), s, user, numbers)
+	if err != nil {
+		readerError(w, r, s, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, greader.StreamContents{
+		ID:      greader.StateReadingList,
+		Updated: time.Now().Unix(),
+		Items:   items,
+	})
+}
+
+func handleReaderStreamContents(w http.ResponseWriter, r *http.Request, s *state, user readerUser) {
+	streamID := r.PathValue("stream")
+	if streamID == "" {
+		streamID = r.FormValue("s")
+	}
+	refs, continuation, err := readerItemRefs(r, s, user, streamID, maxReaderItemContent)
+	if err != nil {
+		writeReaderStreamError(w, r, s, err)
+		return
+	}
+	numbers := make([]int64, len(refs))
+	for i, ref := range refs {
+		numbers[i] = ref.ItemNumber
+	}
+	items, err := readerItems(r.Context(), s, user, numbers)
+	if err != nil {
+		readerError(w, r, s, err)
+		return
+	}
+	if streamID == "" {
+		streamID = greader.StateReadingList
+	}
+	writeJSON(w, http.StatusOK, greader.StreamContents{
+		ID:           streamID,
+		Updated:      time.Now().Unix(),
+		Items:        items,
+		Continuation: continuation,
+	})
+}
+
+// handleReaderEditTag marks items read or unread, stars or unstars them,
+// and adds or removes labels, which are post tags.
+func handleReaderEditTag(w http.ResponseWriter, r *http.Request, s *state, user readerUser) {
+	ctx := r.Context()
+	numbers, err := readerItemNumbers(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	posts, err := s.db.GetReaderItems(ctx, database.GetReaderItemsParams{UserID: user.ID, ItemNumbers: numbers})
+	if err != nil {
+		readerError(w, r, s, err)
+		return
+	}
+	postIDs := make([]uuid.UUID, len(posts))
+	for i, post := range posts {
+		postIDs[i] = post.ID
+	}
+	if len(postIDs) == 0 {
+		writeReaderOK(w)
+		return
+	}
+
+	now := time.Now()
+	setRead := func(read bool) error {
+		return s.db.SetPostsRead(ctx, database.SetPostsReadParams{
+			UserID:    user.ID,
+			PostIds:   postIDs,
+			UpdatedAt: now,
+			ReadAt:    sql.NullTime{Time: now, Valid: read},
+		})
+	}
+	setStarred := func(starred bool) error {
+		return s.db.SetPostsStarred(ctx, database.SetPostsStarredParams{
+			UserID:    user.ID,
+			PostIds:   postIDs,
+			UpdatedAt: now,
+			StarredAt: sql.NullTime{Time: now, Valid: starred},
+		})
+	}
+	setTag := func(label string, tagged bool) error {
+		tag, err := normalizeTag(label)
+		if err != nil {
+			return &badReaderRequest{err}
+		}
+		for _, postID := range postIDs {
+			if tagged {
+				err = s.db.AddPostTag(ctx, database.AddPostTagParams{UserID: user.ID, PostID: postID, Tag: tag, CreatedAt: now})
+			} else {
+				err = s.db.RemovePostTag(ctx, database.RemovePostTagParams{UserID: user.ID, PostID: postID, Tag: tag})
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	apply := func(ids []string, add bool) error {
+		for _, id := range ids {
+			stream, err := greader.ParseStream(id)
+			if err != nil {
+				// Clients send states gator doesn't track, such as
+				// broadcast or like.
+				continue
+			}
+			switch stream.Kind {
+			case greader.KindRead:
+				err = setRead(add)
+			case greader.KindKeptUnread:
+				if add {
+					err = setRead(false)
+				}
+			case greader.KindStarred:
+				err = setStarred(add)
+			case greader.KindLabel:
+				err = setTag(stream.Value, add)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err = apply(r.Form["a"], true)
+	if err == nil {
+		err = apply(r.Form["r"], false)
+	}
+	if err != nil {
+		writeReaderStreamError(w, r, s, err)
+		return
+	}
+	writeReaderOK(w)
+}
+
+// handleReaderMarkAllAsRead marks the posts of a stream read, up to ts
+// when given so items the client hasn't seen yet stay unread.
+func handleReaderMarkAllAsRead(w http.ResponseWriter, r *http.Request, s *state, user readerUser) {
+	filter, err := resolveReaderStream(r.Context(), s, user, r.FormValue("s"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	before := now
+	if ts := r.FormValue("ts"); ts != "" {
+		if before, err = greader.ParseTime(ts); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	marked, err := s.db.MarkStreamRead(r.Context(), database.MarkStreamReadParams{
+		ReadAt:           now,
+		UserID:           user.ID,
+		FeedID:           filter.FeedID,
+		FilterCategories: filter.FilterCategories,
+		CategoryIds:      filter.CategoryIds,
+		Tag:              filter.Tag,
+		Starred:          filter.Starred,
+		PublishedBefore:  before,
+	})
+	if err != nil {
+		readerError(w, r, s, err)
+		return
+	}
+	s.logger().Debug("reader stream marked read", "user", user.Name, "stream", r.FormValue("s"), "posts", marked)
+	writeReaderOK(w)
+}
+
+// newAPIPassword returns a random password that is easy to type into a
+// phone.
+func newAPIPassword() string {
+	b := make([]byte, 15)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// handlerReader shows whether the Reader API is enabled for the user;
+// `reader password` sets the password clients log in with and `reader
+// disable` removes it.
+func handlerReader(s *state, cmd command, user database.User) error {
+	if len(cmd.args) > 0 {
+		switch cmd.args[0] {
+		case "password":
+			return handlerReaderPassword(s, cmd.args[1:], user)
+		case "disable":
+			return handlerReaderDisable(s, cmd.args[1:], user)
+		}
+		return errors.New("usage: reader [password [--stdin] | disable]")
+	}
+
+	_, err := s.db.GetAPIPassword(context.Background(), user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		fmt.Println("Reader API disabled; enable it with `gator reader password`")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't get API password: %w", err)
+	}
+	printReaderLogin(s, user)
+	return nil
+}
+
+func printReaderLogin(s *state, user database.User) {
+	server := strings.TrimRight(s.configPtr.PublicURL, "/")
+	if server == "" {
+		server = "the URL of `gator serve`"
+	}
+	fmt.Printf("Reader API enabled. In a Google Reader or FreshRSS client, log in to %s as %s\n", server, user.Name)
+	fmt.Printf("(FreshRSS clients may want %s%s)\n", server, readerPrefix)
+}
+
+func handlerReaderPassword(s *state, args []string, user database.User) error {
+	fs := flag.NewFlagSet("reader password", flag.ContinueOnError)
+	stdin := fs.Bool("stdin", false, "read the password from standard input instead of generating one")
+	args, err := parseCommandFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errors.New("too many command args given")
+	}
+
+	pass := newAPIPassword()
+	if *stdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("couldn't read password: %w", err)
+		}
+		pass = strings.TrimRight(line, "\r\n")
+	}
+	hash, err := password.Hash(pass)
+	if err != nil {
+		return err
+	}
+	err = s.db.SetAPIPassword(context.Background(), database.SetAPIPasswordParams{
+		UserID:       user.ID,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+		PasswordHash: hash,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't save API password: %w", err)
+	}
+
+	if !*stdin {
+		fmt.Printf("API password: %s\n", pass)
+	}
+	printReaderLogin(s, user)
+	fmt.Println("Clients logged in with a previous password have to log in again")
+	return nil
+}
+
+func handlerReaderDisable(s *state, args []string, user database.User) error {
+	if len(args) != 0 {
+		return errors.New("too many command args given")
+	}
+	removed, err := s.db.DeleteAPIPassword(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't remove API password: %w", err)
+	}
+	if removed == 0 {
+		return errors.New("the Reader API isn't enabled")
+	}
+	fmt.Println("Reader API disabled; clients are logged out")
+	return nil
+}
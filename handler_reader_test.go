@@ -0,0 +1,578 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jjboykin/gator/internal/database"
+	"github.com/jjboykin/gator/internal/greader"
+	"github.com/jjboykin/gator/internal/password"
+)
+
+const readerTestPassword = "correct horse battery"
+
+// readerTest is a Reader API server for two users, alice and bob, who
+// have both enabled the API.
+type readerTest struct {
+	db     *fakeDB
+	server *httptest.Server
+	alice  database.User
+	bob    database.User
+
+	mu     sync.Mutex
+	hashes map[uuid.UUID]string
+}
+
+// readerTestHashes are the hashes of alice's and bob's API passwords,
+// made once since hashing is slow on purpose.
+var readerTestHashes = sync.OnceValues(func() ([2]string, error) {
+	alice, err := password.Hash(readerTestPassword)
+	if err != nil {
+		return [2]string{}, err
+	}
+	bob, err := password.Hash("bob's own password")
+	return [2]string{alice, bob}, err
+})
+
+func newReaderTest(t *testing.T) *readerTest {
+	t.Helper()
+	hashes, err := readerTestHashes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt := &readerTest{
+		db:    &fakeDB{},
+		alice: database.User{ID: uuid.New(), Name: "alice"},
+		bob:   database.User{ID: uuid.New(), Name: "bob"},
+	}
+	rt.hashes = map[uuid.UUID]string{rt.alice.ID: hashes[0], rt.bob.ID: hashes[1]}
+	users := []database.User{rt.alice, rt.bob}
+	rt.db.onArgs("GetUserByName", func(args []driver.Value) []any {
+		for _, user := range users {
+			if args[0] == user.Name {
+				return []any{user}
+			}
+		}
+		return nil
+	})
+	rt.db.onArgs("GetUser", func(args []driver.Value) []any {
+		for _, user := range users {
+			if args[0] == user.ID.String() {
+				return []any{user}
+			}
+		}
+		return nil
+	})
+	rt.db.onArgs("GetAPIPassword", func(args []driver.Value) []any {
+		rt.mu.Lock()
+		defer rt.mu.Unlock()
+		for id, hash := range rt.hashes {
+			if args[0] == id.String() {
+				return []any{database.ApiPassword{UserID: id, PasswordHash: hash}}
+			}
+		}
+		return nil
+	})
+
+	mux := http.NewServeMux()
+	registerReaderRoutes(mux, newTestState(t, rt.db))
+	rt.server = httptest.NewServer(mux)
+	t.Cleanup(rt.server.Close)
+	return rt
+}
+
+// token returns the auth token ClientLogin gives user.
+func (rt *readerTest) token(user database.User) string {
+	return user.ID.String() + "/" + greader.Sign(rt.hashes[user.ID], readerAuthToken, user.ID.String())
+}
+
+// do makes a request as alice and returns the response with its body.
+func (rt *readerTest) do(t *testing.T, method, path string, form url.Values) (*http.Response, string) {
+	t.Helper()
+	return rt.doWithToken(t, rt.token(rt.alice), method, path, form)
+}
+
+func (rt *readerTest) doWithToken(t *testing.T, token, method, path string, form url.Values) (*http.Response, string) {
+	t.Helper()
+	target := rt.server.URL + path
+	var body io.Reader
+	if method == http.MethodGet && form != nil {
+		target += "?" + form.Encode()
+	} else if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "GoogleLogin auth="+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+// decodeReaderJSON decodes a JSON answer into v.
+func decodeReaderJSON(t *testing.T, resp *http.Response, body string, v any) {
+	t.Helper()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("%s: %s %s", resp.Request.URL.Path, resp.Status, body)
+	}
+	if err := json.Unmarshal([]byte(body), v); err != nil {
+		t.Fatalf("%s: %v in %s", resp.Request.URL.Path, err, body)
+	}
+}
+
+func TestReaderLogin(t *testing.T) {
+	rt := newReaderTest(t)
+
+	for _, tt := range []struct {
+		name, email, passwd string
+	}{
+		{"wrong password", "alice", "incorrect horse"},
+		{"other user's password", "bob", readerTestPassword},
+		{"unknown user", "carol", readerTestPassword},
+		{"no password", "alice", ""},
+	} {
+		resp, body := rt.doWithToken(t, "", "POST", "/accounts/ClientLogin", url.Values{"Email": {tt.email}, "Passwd": {tt.passwd}})
+		if resp.StatusCode != http.StatusUnauthorized || !strings.Contains(body, "BadAuthentication") {
+			t.Errorf("login with %s = %s %q, want 401 BadAuthentication", tt.name, resp.Status, body)
+		}
+	}
+
+	for _, path := range []string{"/accounts/ClientLogin", readerPrefix + "/accounts/ClientLogin"} {
+		resp, body := rt.doWithToken(t, "", "POST", path, url.Values{"Email": {"alice"}, "Passwd": {readerTestPassword}})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("login at %s = %s %q", path, resp.Status, body)
+		}
+		var auth string
+		for _, line := range strings.Split(body, "\n") {
+			if value, ok := strings.CutPrefix(line, "Auth="); ok {
+				auth = value
+			}
+		}
+		if auth != rt.token(rt.alice) {
+			t.Errorf("login at %s gave Auth=%q, want %q", path, auth, rt.token(rt.alice))
+		}
+
+		var info greader.UserInfo
+		resp, body = rt.doWithToken(t, auth, "GET", "/reader/api/0/user-info", nil)
+		decodeReaderJSON(t, resp, body, &info)
+		if info.UserName != "alice" || info.UserID != rt.alice.ID.String() {
+			t.Errorf("user-info = %+v, want alice", info)
+		}
+	}
+}
+
+func TestReaderAuth(t *testing.T) {
+	rt := newReaderTest(t)
+	aliceID := rt.alice.ID.String()
+	bobSignature := greader.Sign(rt.hashes[rt.bob.ID], readerAuthToken, rt.bob.ID.String())
+
+	for _, tt := range []struct {
+		name  string
+		token string
+	}{
+		{"no token", ""},
+		{"forged signature", aliceID + "/" + strings.Repeat("0", 64)},
+		{"no signature", aliceID},
+		{"another user's signature", aliceID + "/" + bobSignature},
+		{"signature for another user", aliceID + "/" + greader.Sign(rt.hashes[rt.alice.ID], readerAuthToken, rt.bob.ID.String())},
+		{"edit token", aliceID + "/" + greader.Sign(rt.hashes[rt.alice.ID], readerEditToken, aliceID)},
+		{"unknown user", uuid.New().String() + "/" + bobSignature},
+		{"not a user ID", "alice/" + bobSignature},
+	} {
+		resp, body := rt.doWithToken(t, tt.token, "GET", "/reader/api/0/user-info", nil)
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: user-info = %s %q, want 401", tt.name, resp.Status, body)
+		}
+	}
+
+	// Changing the API password logs clients out, and so does disabling
+	// the API.
+	token := rt.token(rt.alice)
+	for _, change := range []struct {
+		name string
+		hash string
+	}{
+		{"new password", rt.hashes[rt.bob.ID]},
+		{"disabled API", ""},
+	} {
+		rt.mu.Lock()
+		if change.hash == "" {
+			delete(rt.hashes, rt.alice.ID)
+		} else {
+			rt.hashes[rt.alice.ID] = change.hash
+		}
+		rt.mu.Unlock()
+		resp, body := rt.doWithToken(t, token, "GET", "/reader/api/0/user-info", nil)
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("after a %s: user-info = %s %q, want 401", change.name, resp.Status, body)
+		}
+	}
+}
+
+func TestReaderEditToken(t *testing.T) {
+	rt := newReaderTest(t)
+	resp, editToken := rt.do(t, "GET", "/reader/api/0/token", nil)
+	if resp.StatusCode != http.StatusOK || editToken == "" {
+		t.Fatalf("token = %s %q", resp.Status, editToken)
+	}
+
+	bobToken := greader.Sign(rt.hashes[rt.bob.ID], readerEditToken, rt.bob.ID.String())
+	for _, tt := range []struct {
+		name  string
+		t     string
+		valid bool
+	}{
+		{"token from /token", editToken, true},
+		// Clients that don't send T are let through; the auth header
+		// already can't come from another site.
+		{"no token", "", true},
+		{"bad token", "nonsense", false},
+		{"bob's token", bobToken, false},
+		{"auth token", greader.Sign(rt.hashes[rt.alice.ID], readerAuthToken, rt.alice.ID.String()), false},
+	} {
+		form := url.Values{"i": {"1"}, "a": {greader.StateRead}}
+		if tt.t != "" {
+			form.Set("T", tt.t)
+		}
+		resp, body := rt.do(t, "POST", "/reader/api/0/edit-tag", form)
+		if tt.valid && resp.StatusCode != http.StatusOK {
+			t.Errorf("edit-tag with %s = %s %q, want 200", tt.name, resp.Status, body)
+		}
+		if !tt.valid && (resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("X-Reader-Google-Bad-Token") != "true") {
+			t.Errorf("edit-tag with %s = %s %q, want 401 with X-Reader-Google-Bad-Token", tt.name, resp.Status, body)
+		}
+	}
+}
+
+// addCategories gives alice the categories Tech and Tech/Go.
+func (rt *readerTest) addCategories() (tech, golang database.Category) {
+	tech = database.Category{ID: uuid.New(), UserID: rt.alice.ID, Name: "Tech"}
+	golang = database.Category{ID: uuid.New(), UserID: rt.alice.ID, Name: "Go", ParentID: uuid.NullUUID{UUID: tech.ID, Valid: true}}
+	rt.db.on("GetCategoriesForUser", golang, tech)
+	return tech, golang
+}
+
+func TestReaderSubscriptionList(t *testing.T) {
+	rt := newReaderTest(t)
+	_, golang := rt.addCategories()
+	rt.db.onArgs("GetFeedFollowsForUser", func(args []driver.Value) []any {
+		if args[0] != "alice" {
+			return nil
+		}
+		return []any{
+			database.GetFeedFollowsForUserRow{FeedName: "Go blog", FeedUrl: "https://go.dev/blog/feed.atom", CategoryID: uuid.NullUUID{UUID: golang.ID, Valid: true}},
+			database.GetFeedFollowsForUserRow{FeedName: "another feed", FeedUrl: "http://example.com/rss"},
+		}
+	})
+
+	var list greader.SubscriptionList
+	resp, body := rt.do(t, "GET", "/reader/api/0/subscription/list", url.Values{"output": {"json"}})
+	decodeReaderJSON(t, resp, body, &list)
+	want := []greader.Subscription{
+		{
+			ID:         "feed/http://example.com/rss",
+			Title:      "another feed",
+			Categories: []greader.Category{},
+			URL:        "http://example.com/rss",
+			HTMLURL:    "http://example.com/",
+		},
+		{
+			ID:         "feed/https://go.dev/blog/feed.atom",
+			Title:      "Go blog",
+			Categories: []greader.Category{{ID: "user/-/label/Tech/Go", Label: "Tech/Go"}},
+			URL:        "https://go.dev/blog/feed.atom",
+			HTMLURL:    "https://go.dev/",
+		},
+	}
+	if got, _ := json.Marshal(list.Subscriptions); string(got) != mustJSON(t, want) {
+		t.Errorf("subscriptions = %s, want %s", got, mustJSON(t, want))
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestReaderSubscriptionEdit(t *testing.T) {
+	rt := newReaderTest(t)
+	feed := database.Feed{ID: uuid.New(), Name: "Go blog", Url: "https://go.dev/blog/feed.atom"}
+	rt.db.onArgs("GetFeedByURL", func(args []driver.Value) []any {
+		if args[0] == feed.Url {
+			return []any{feed}
+		}
+		return nil
+	})
+	rt.db.on("CreateFeedFollow", database.CreateFeedFollowRow{FeedID: feed.ID, UserID: rt.alice.ID})
+	rt.db.on("HasActiveWebSubSubscription", true)
+
+	for _, tt := range []struct {
+		name   string
+		form   url.Values
+		status int
+	}{
+		{"subscribe", url.Values{"ac": {"subscribe"}, "s": {"feed/" + feed.Url}}, http.StatusOK},
+		{"unsubscribe", url.Values{"ac": {"unsubscribe"}, "s": {"feed/" + feed.Url}}, http.StatusOK},
+		{"unsubscribe from an unknown feed", url.Values{"ac": {"unsubscribe"}, "s": {"feed/https://example.com/rss"}}, http.StatusNotFound},
+		{"subscribe to a label", url.Values{"ac": {"subscribe"}, "s": {"user/-/label/Tech"}}, http.StatusBadRequest},
+		{"unknown action", url.Values{"ac": {"rename"}, "s": {"feed/" + feed.Url}}, http.StatusBadRequest},
+		{"no stream", url.Values{"ac": {"subscribe"}}, http.StatusBadRequest},
+	} {
+		resp, body := rt.do(t, "POST", "/reader/api/0/subscription/edit", tt.form)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: subscription/edit = %s %q, want %d", tt.name, resp.Status, body, tt.status)
+		}
+	}
+
+	follows := rt.db.called("CreateFeedFollow")
+	if len(follows) != 1 || follows[0][3] != rt.alice.ID.String() || follows[0][4] != feed.ID.String() {
+		t.Errorf("CreateFeedFollow runs = %v, want one for alice and %s", follows, feed.ID)
+	}
+	unfollows := rt.db.called("DeleteFeedFollow")
+	if len(unfollows) != 1 || unfollows[0][0] != rt.alice.ID.String() || unfollows[0][1] != feed.ID.String() {
+		t.Errorf("DeleteFeedFollow runs = %v, want one for alice and %s", unfollows, feed.ID)
+	}
+	if created := rt.db.called("CreateFeed"); len(created) != 0 {
+		t.Errorf("CreateFeed ran %d times for a known feed", len(created))
+	}
+}
+
+// addReaderPosts gives alice three posts, numbered 1 to 3. Post 2 is read
+// and starred and in Tech/Go; post 3 belongs to a feed only bob follows.
+func (rt *readerTest) addReaderPosts() []database.GetReaderItemsRow {
+	_, golang := rt.addCategories()
+	published := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	posts := []database.GetReaderItemsRow{
+		{
+			ID:          uuid.New(),
+			Title:       "First",
+			Url:         "https://example.com/1",
+			Description: sql.NullString{String: "<p>one</p>", Valid: true},
+			PublishedAt: published,
+			ItemNumber:  1,
+			FeedName:    "Example",
+			FeedUrl:     "https://example.com/rss",
+		},
+		{
+			ID:          uuid.New(),
+			Title:       "Second",
+			Url:         "https://go.dev/blog/2",
+			Content:     sql.NullString{String: "<p>two</p>", Valid: true},
+			PublishedAt: published.Add(time.Hour),
+			ItemNumber:  2,
+			FeedName:    "Go blog",
+			FeedUrl:     "https://go.dev/blog/feed.atom",
+			CategoryID:  uuid.NullUUID{UUID: golang.ID, Valid: true},
+			ReadAt:      sql.NullTime{Time: published, Valid: true},
+			StarredAt:   sql.NullTime{Time: published, Valid: true},
+			Tags:        []string{"later"},
+		},
+	}
+	rt.db.onArgs("GetReaderItems", func(args []driver.Value) []any {
+		var rows []any
+		for _, post := range posts {
+			for _, n := range strings.Split(strings.Trim(args[1].(string), "{}"), ",") {
+				if args[0] == rt.alice.ID.String() && n == strconv.FormatInt(post.ItemNumber, 10) {
+					rows = append(rows, post)
+				}
+			}
+		}
+		return rows
+	})
+	rt.db.onArgs("GetReaderItemRefs", func(args []driver.Value) []any {
+		if args[0] != rt.alice.ID.String() {
+			return nil
+		}
+		refs := []any{
+			database.GetReaderItemRefsRow{ItemNumber: 2, PublishedAt: posts[1].PublishedAt, FeedUrl: posts[1].FeedUrl},
+			database.GetReaderItemRefsRow{ItemNumber: 1, PublishedAt: posts[0].PublishedAt, FeedUrl: posts[0].FeedUrl},
+		}
+		return refs[:min(len(refs), int(args[11].(int64)))]
+	})
+	rt.db.onArgs("GetEnclosuresForPost", func(args []driver.Value) []any {
+		if args[0] != posts[0].ID.String() || args[1] != rt.alice.ID.String() {
+			return nil
+		}
+		return []any{database.PostEnclosure{PostID: posts[0].ID, Url: "https://example.com/one.mp3", MimeType: "audio/mpeg", Length: 1024}}
+	})
+	return posts
+}
+
+func TestReaderStreamContents(t *testing.T) {
+	rt := newReaderTest(t)
+	rt.addReaderPosts()
+
+	var contents greader.StreamContents
+	resp, body := rt.do(t, "GET", "/reader/api/0/stream/contents/user/-/state/com.google/reading-list", url.Values{"n": {"2"}})
+	decodeReaderJSON(t, resp, body, &contents)
+	if contents.ID != greader.StateReadingList || contents.Continuation != "2" || len(contents.Items) != 2 {
+		t.Fatalf("stream/contents = %s", body)
+	}
+	second, first := contents.Items[0], contents.Items[1]
+	if second.ID != greader.ItemID(2) || second.Title != "Second" || second.Summary.Content != "<p>two</p>" {
+		t.Errorf("first item = %+v, want post 2", second)
+	}
+	wantCategories := []string{greader.StateReadingList, greader.StateRead, greader.StateStarred, "user/-/label/Tech/Go", "user/-/label/later"}
+	if strings.Join(second.Categories, " ") != strings.Join(wantCategories, " ") {
+		t.Errorf("categories of post 2 = %v, want %v", second.Categories, wantCategories)
+	}
+	if first.ID != greader.ItemID(1) || first.Origin.StreamID != "feed/https://example.com/rss" || first.Summary.Content != "<p>one</p>" {
+		t.Errorf("second item = %+v, want post 1", first)
+	}
+	if strings.Join(first.Categories, " ") != greader.StateReadingList {
+		t.Errorf("categories of post 1 = %v, want only the reading list", first.Categories)
+	}
+	if len(first.Enclosure) != 1 || first.Enclosure[0].Href != "https://example.com/one.mp3" || len(second.Enclosure) != 0 {
+		t.Errorf("enclosures = %+v and %+v, want one.mp3 on post 1", first.Enclosure, second.Enclosure)
+	}
+
+	// A short page has no continuation, and the stream may come from s.
+	resp, body = rt.do(t, "GET", "/reader/api/0/stream/contents/", url.Values{"s": {greader.StateStarred}, "n": {"5"}, "c": {"2"}})
+	contents = greader.StreamContents{}
+	decodeReaderJSON(t, resp, body, &contents)
+	if contents.ID != greader.StateStarred || contents.Continuation != "" {
+		t.Errorf("stream/contents for s=starred = %s", body)
+	}
+	refs := rt.db.called("GetReaderItemRefs")
+	if last := refs[len(refs)-1]; last[5] != true || last[12] != int64(2) {
+		t.Errorf("GetReaderItemRefs for s=starred&c=2 ran with %v", last)
+	}
+
+	for _, query := range []url.Values{{"n": {"0"}}, {"c": {"-1"}}, {"ot": {"soon"}}, {"s": {"user/-/state/com.google/broadcast"}}} {
+		resp, body := rt.do(t, "GET", "/reader/api/0/stream/contents/", query)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("stream/contents?%s = %s %q, want 400", query.Encode(), resp.Status, body)
+		}
+	}
+
+	// Items of other users' feeds aren't returned by number.
+	resp, body = rt.do(t, "POST", "/reader/api/0/stream/items/contents", url.Values{"i": {"3", greader.ItemID(1)}})
+	contents = greader.StreamContents{}
+	decodeReaderJSON(t, resp, body, &contents)
+	if len(contents.Items) != 1 || contents.Items[0].ID != greader.ItemID(1) {
+		t.Errorf("stream/items/contents for 3 and 1 = %s, want only 1", body)
+	}
+
+	var ids greader.ItemRefs
+	resp, body = rt.do(t, "GET", "/reader/api/0/stream/items/ids", url.Values{"s": {greader.StateReadingList}, "n": {"1"}})
+	decodeReaderJSON(t, resp, body, &ids)
+	if len(ids.ItemRefs) != 1 || ids.ItemRefs[0].ID != "2" || ids.Continuation != "1" {
+		t.Errorf("stream/items/ids = %s", body)
+	}
+}
+
+func TestReaderUnreadCount(t *testing.T) {
+	rt := newReaderTest(t)
+	tech, golang := rt.addCategories()
+	newest := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	rt.db.on("GetUnreadCounts",
+		database.GetUnreadCountsRow{FeedUrl: "https://go.dev/blog/feed.atom", CategoryID: uuid.NullUUID{UUID: golang.ID, Valid: true}, Count: 3, NewestPublishedAt: newest},
+		database.GetUnreadCountsRow{FeedUrl: "https://example.com/tech.xml", CategoryID: uuid.NullUUID{UUID: tech.ID, Valid: true}, Count: 2, NewestPublishedAt: newest.Add(time.Hour)},
+		database.GetUnreadCountsRow{FeedUrl: "https://example.com/rss", Count: 1, NewestPublishedAt: newest.Add(-time.Hour)},
+	)
+
+	var result greader.UnreadCounts
+	resp, body := rt.do(t, "GET", "/reader/api/0/unread-count", nil)
+	decodeReaderJSON(t, resp, body, &result)
+	got := make(map[string]greader.UnreadCount)
+	for _, count := range result.UnreadCounts {
+		got[count.ID] = count
+	}
+	for _, want := range []greader.UnreadCount{
+		{ID: greader.StateReadingList, Count: 6, NewestItemTimestampUsec: greader.Usec(newest.Add(time.Hour))},
+		{ID: "feed/https://go.dev/blog/feed.atom", Count: 3, NewestItemTimestampUsec: greader.Usec(newest)},
+		{ID: "feed/https://example.com/rss", Count: 1, NewestItemTimestampUsec: greader.Usec(newest.Add(-time.Hour))},
+		{ID: "user/-/label/Tech/Go", Count: 3, NewestItemTimestampUsec: greader.Usec(newest)},
+		{ID: "user/-/label/Tech", Count: 5, NewestItemTimestampUsec: greader.Usec(newest.Add(time.Hour))},
+	} {
+		if got[want.ID] != want {
+			t.Errorf("unread count of %s = %+v, want %+v", want.ID, got[want.ID], want)
+		}
+	}
+	if len(got) != 6 {
+		t.Errorf("%d unread counts, want 6: %s", len(got), body)
+	}
+}
+
+func TestReaderEditTag(t *testing.T) {
+	rt := newReaderTest(t)
+	posts := rt.addReaderPosts()
+	postID := posts[0].ID.String()
+
+	for _, tt := range []struct {
+		form    url.Values
+		query   string
+		present bool
+	}{
+		{url.Values{"i": {"1"}, "a": {greader.StateRead}}, "SetPostsRead", true},
+		{url.Values{"i": {"1"}, "r": {greader.StateRead}}, "SetPostsRead", false},
+		{url.Values{"i": {"1"}, "a": {greader.StateKeptUnread}}, "SetPostsRead", false},
+		{url.Values{"i": {greader.ItemID(1)}, "a": {greader.StateStarred}}, "SetPostsStarred", true},
+		{url.Values{"i": {"1"}, "r": {greader.StateStarred}}, "SetPostsStarred", false},
+	} {
+		before := len(rt.db.called(tt.query))
+		resp, body := rt.do(t, "POST", "/reader/api/0/edit-tag", tt.form)
+		if resp.StatusCode != http.StatusOK || body != "OK" {
+			t.Fatalf("edit-tag %s = %s %q", tt.form.Encode(), resp.Status, body)
+		}
+		runs := rt.db.called(tt.query)
+		if len(runs) != before+1 {
+			t.Errorf("edit-tag %s ran %s %d times, want once", tt.form.Encode(), tt.query, len(runs)-before)
+			continue
+		}
+		run := runs[len(runs)-1]
+		if run[0] != rt.alice.ID.String() || !strings.Contains(run[1].(string), postID) || (run[3] != nil) != tt.present {
+			t.Errorf("edit-tag %s ran %s with %v, want a time set: %v", tt.form.Encode(), tt.query, run, tt.present)
+		}
+	}
+
+	resp, body := rt.do(t, "POST", "/reader/api/0/edit-tag", url.Values{"i": {"1"}, "a": {"user/-/label/later"}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("edit-tag with a label = %s %q", resp.Status, body)
+	}
+	if tags := rt.db.called("AddPostTag"); len(tags) != 1 || tags[0][1] != postID {
+		t.Errorf("AddPostTag runs = %v, want one for post 1", tags)
+	}
+
+	// Items of feeds alice doesn't follow are left alone.
+	writes := len(rt.db.called("SetPostsRead"))
+	resp, body = rt.do(t, "POST", "/reader/api/0/edit-tag", url.Values{"i": {"3"}, "a": {greader.StateRead}})
+	if resp.StatusCode != http.StatusOK || len(rt.db.called("SetPostsRead")) != writes {
+		t.Errorf("edit-tag on another user's item = %s %q and wrote its state", resp.Status, body)
+	}
+
+	resp, body = rt.do(t, "POST", "/reader/api/0/edit-tag", url.Values{"i": {"first"}, "a": {greader.StateRead}})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("edit-tag with a bad item ID = %s %q, want 400", resp.Status, body)
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jjboykin/gator/internal/database"
+	"github.com/jjboykin/gator/internal/plaintext"
+	"github.com/jjboykin/gator/internal/rules"
+)
+
+// userRule is a rule together with the user it belongs to.
+type userRule struct {
+	userID uuid.UUID
+	rule   rules.Rule
+}
+
+// loadRule parses a stored rule. Rules are checked when they are added, so
+// this only fails if the rule syntax changed since.
+func loadRule(stored database.Rule) (rules.Rule, error) {
+	rule, err := rules.Parse(stored.Name, stored.Conditions, stored.Actions)
+	if err != nil {
+		return rules.Rule{}, fmt.Errorf("rule %s: %w", stored.Name, err)
+	}
+	return rule, nil
+}
+
+// loadFeedRules returns the rules of every user following feedID.
+func loadFeedRules(ctx context.Context, s *state, feedID uuid.UUID, logger *slog.Logger) ([]userRule, error) {
+	stored, err := s.db.GetRulesForFeed(ctx, feedID)
+	if err != nil {
+		return nil, err
+	}
+	var out []userRule
+	for _, r := range stored {
+		rule, err := loadRule(r)
+		if err != nil {
+			logger.Warn("skipping invalid rule", "user_id", r.UserID, "error", err)
+			continue
+		}
+		out = append(out, userRule{userID: r.UserID, rule: rule})
+	}
+	return out, nil
+}
+
+// rulePost is what rules see of a post. The description is matched as
+// text, so that markup and entities don't get in the way of patterns.
+func rulePost(feedName, feedURL string, post database.Post) rules.Post {
+	return rules.Post{
+		FeedName:    feedName,
+		FeedURL:     feedURL,
+		Title:       post.Title,
+		Description: plaintext.Render(post.Description.String, 0),
+		Author:      post.Author.String,
+		Categories:  post.Categories,
+	}
+}
+
+// applyRules runs the rules against a newly stored post, applying the
+// actions of those that match.
+func applyRules(ctx context.Context, s *state, feed database.Feed, post database.Post, feedRules []userRule, logger *slog.Logger) {
+	p := rulePost(feed.Name, feed.Url, post)
+	for _, r := range feedRules {
+		if !r.rule.Match(p) {
+			continue
+		}
+		logger.Debug("rule matched", "user_id", r.userID, "rule", r.rule.Name, "post_id", post.ID)
+		if err := applyRuleActions(ctx, s, r.userID, r.rule, post, feed, true); err != nil {
+			logger.Error("couldn't apply rule", "user_id", r.userID, "rule", r.rule.Name, "post_id", post.ID, "error", err)
+		}
+	}
+}
+
+// applyRuleActions does what rule says to post on behalf of userID. The
+// notify action is left out when notify is false, so that applying a rule
+// to old posts doesn't flood the notification list.
+func applyRuleActions(ctx context.Context, s *state, userID uuid.UUID, rule rules.Rule, post database.Post, feed database.Feed, notify bool) error {
+	now := time.Now()
+	stateParams := database.SetPostStateParams{
+		UserID:    userID,
+		PostID:    post.ID,
+		UpdatedAt: now,
+	}
+	setState := false
+	for _, action := range rule.Actions {
+		switch action.Kind {
+		case rules.ActionRead:
+			stateParams.ReadAt = sql.NullTime{Time: now, Valid: true}
+			setState = true
+		case rules.ActionStar:
+			stateParams.StarredAt = sql.NullTime{Time: now, Valid: true}
+			setState = true
+		case rules.ActionHide:
+			stateParams.HiddenAt = sql.NullTime{Time: now, Valid: true}
+			setState = true
+		case rules.ActionTag:
+			tag, err := normalizeTag(action.Arg)
+			if err != nil {
+				return err
+			}
+			err = s.db.AddPostTag(ctx, database.AddPostTagParams{
+				UserID:    userID,
+				PostID:    post.ID,
+				Tag:       tag,
+				CreatedAt: now,
+			})
+			if err != nil {
+				return fmt.Errorf("couldn't tag post: %w", err)
+			}
+		case rules.ActionNotify:
+			if !notify {
+				continue
+			}
+			err := s.db.CreateNotification(ctx, database.CreateNotificationParams{
+				ID:        uuid.New(),
+				CreatedAt: now,
+				UserID:    userID,
+				FeedID:    uuid.NullUUID{UUID: feed.ID, Valid: true},
+				Message:   fmt.Sprintf("Rule %s: %s (%s)", rule.Name, post.Title, feed.Name),
+			})
+			if err != nil {
+				return fmt.Errorf("couldn't create notification: %w", err)
+			}
+		}
+		s.metrics.ruleActions.Inc(action.Kind)
+	}
+	if !setState {
+		return nil
+	}
+	if err := s.db.SetPostState(ctx, stateParams); err != nil {
+		return fmt.Errorf("couldn't update post state: %w", err)
+	}
+	return nil
+}
+
+// postStateMarkers describes what was done with a post, for listings.
+func postStateMarkers(readAt, starredAt sql.NullTime) string {
+	markers := ""
+	if starredAt.Valid {
+		markers += " [starred]"
+	}
+	if readAt.Valid {
+		markers += " [read]"
+	}
+	return markers
+}
+
+func handlerRules(s *state, cmd command, user database.User) error {
+	if len(cmd.args) == 0 {
+		return errors.New("usage: rules add <name> --if <condition>... --then <action>... | rules list | rules remove <name> | rules test <name> | rules apply-retroactively <name>")
+	}
+
+	switch cmd.args[0] {
+	case "add":
+		return handlerRulesAdd(s, cmd.args[1:], user)
+	case "list":
+		return handlerRulesList(s, cmd.args[1:], user)
+	case "remove":
+		return handlerRulesRemove(s, cmd.args[1:], user)
+	case "test":
+		return handlerRulesRun(s, cmd.args[1:], user, false)
+	case "apply-retroactively":
+		return handlerRulesRun(s, cmd.args[1:], user, true)
+	default:
+		return fmt.Errorf("unknown rules subcommand: %s", cmd.args[0])
+	}
+}
+
+func handlerRulesAdd(s *state, args []string, user database.User) error {
+	fs := flag.NewFlagSet("rules add", flag.ContinueOnError)
+	var conditions, actions stringsFlag
+	fs.Var(&conditions, "if", "condition that must hold, e.g. title:webinar or description:/CVE-\\d+/ (repeatable)")
+	fs.Var(&actions, "then", "action: read, star, hide, notify or tag:<name> (repeatable)")
+	args, err := parseCommandFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errors.New("usage: rules add <name> --if <condition>... --then <action>...")
+	}
+	name := strings.TrimSpace(args[0])
+	if name == "" {
+		return errors.New("rule name can't be empty")
+	}
+	rule, err := rules.Parse(name, conditions, actions)
+	if err != nil {
+		return err
+	}
+	for _, action := range rule.Actions {
+		if action.Kind == rules.ActionTag {
+			if _, err := normalizeTag(action.Arg); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = s.db.CreateRule(context.Background(), database.CreateRuleParams{
+		ID:         uuid.New(),
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		UserID:     user.ID,
+		Name:       name,
+		Conditions: rule.ConditionStrings(),
+		Actions:    rule.ActionStrings(),
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't add rule: %w", err)
+	}
+	fmt.Printf("Rule %s added; it applies to new posts. Preview it with `gator rules test %s`.\n", name, name)
+	return nil
+}
+
+func handlerRulesList(s *state, args []string, user database.User) error {
+	if len(args) != 0 {
+		return errors.New("too many command args given")
+	}
+	stored, err := s.db.GetRulesForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't get rules: %w", err)
+	}
+	if len(stored) == 0 {
+		fmt.Println("No rules yet; add one with `gator rules add`")
+		return nil
+	}
+	for _, r := range stored {
+		fmt.Printf("%s\n    if:   %s\n    then: %s\n", r.Name, strings.Join(r.Conditions, " AND "), strings.Join(r.Actions, ", "))
+	}
+	return nil
+}
+
+func handlerRulesRemove(s *state, args []string, user database.User) error {
+	if len(args) != 1 {
+		return errors.New("usage: rules remove <name>")
+	}
+	removed, err := s.db.DeleteRule(context.Background(), database.DeleteRuleParams{UserID: user.ID, Name: args[0]})
+	if err != nil {
+		return fmt.Errorf("couldn't remove rule: %w", err)
+	}
+	if removed == 0 {
+		return fmt.Errorf("no such rule: %s", args[0])
+	}
+	fmt.Printf("Rule %s removed\n", args[0])
+	return nil
+}
+
+// handlerRulesRun checks a rule against the user's recent posts, listing
+// those that match, and applies it to them when apply is set.
+func handlerRulesRun(s *state, args []string, user database.User, apply bool) error {
+	subcommand := "test"
+	if apply {
+		subcommand = "apply-retroactively"
+	}
+	fs := flag.NewFlagSet("rules "+subcommand, flag.ContinueOnError)
+	limit := fs.Int("limit", 1000, "number of recent posts to check")
+	args, err := parseCommandFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: rules %s <name> [--limit <n>]", subcommand)
+	}
+
+	ctx := context.Background()
+	stored, err := s.db.GetRuleByName(ctx, database.GetRuleByNameParams{UserID: user.ID, Name: args[0]})
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no such rule: %s", args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't get rule: %w", err)
+	}
+	rule, err := loadRule(stored)
+	if err != nil {
+		return err
+	}
+	posts, err := s.db.GetPostsForUser(ctx, database.GetPostsForUserParams{
+		ID:    user.ID,
+		Limit: int32(*limit),
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't get posts for user: %w", err)
+	}
+
+	matched := 0
+	for _, row := range posts {
+		post := database.Post{
+			ID:          row.ID,
+			Title:       row.Title,
+			Description: row.Description,
+			Author:      row.Author,
+			Categories:  row.Categories,
+		}
+		if !rule.Match(rulePost(row.FeedName, row.FeedUrl, post)) {
+			continue
+		}
+		matched++
+		fmt.Printf("%s  %s (%s)\n", row.ID, row.Title, row.FeedName)
+		if apply {
+			feed := database.Feed{ID: row.FeedID, Name: row.FeedName, Url: row.FeedUrl}
+			if err := applyRuleActions(ctx, s, user.ID, rule, post, feed, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	if apply {
+		fmt.Printf("Applied %s to %d of %d posts\n", rule.Name, matched, len(posts))
+		return nil
+	}
+	fmt.Printf("%d of %d posts match; %s would apply: %s\n", matched, len(posts), rule.Name, strings.Join(rule.ActionStrings(), ", "))
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"database/sql"
+	"log/slog"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jjboykin/gator/internal/database"
+	"github.com/jjboykin/gator/internal/rules"
+)
+
+func TestApplyRuleActions(t *testing.T) {
+	userID := uuid.New()
+	feed := database.Feed{ID: uuid.New(), Name: "Hacker News"}
+	post := database.Post{ID: uuid.New(), Title: "Free webinar"}
+
+	for _, tt := range []struct {
+		actions []string
+		notify  bool
+		// read, starred and hidden are whether SetPostState sets the
+		// time; state is whether it runs at all.
+		state                 bool
+		read, starred, hidden bool
+		tags                  []string
+		notifications         int
+	}{
+		{actions: []string{"read"}, state: true, read: true},
+		{actions: []string{"star"}, state: true, starred: true},
+		{actions: []string{"hide"}, state: true, hidden: true},
+		{actions: []string{"read", "bookmark", "hide"}, state: true, read: true, starred: true, hidden: true},
+		{actions: []string{"tag:Events"}, tags: []string{"events"}},
+		{actions: []string{"notify"}, notify: true, notifications: 1},
+		{actions: []string{"notify"}, notify: false},
+		{actions: []string{"tag:later", "star", "notify"}, notify: true, state: true, starred: true, tags: []string{"later"}, notifications: 1},
+	} {
+		db := &fakeDB{}
+		s := newTestState(t, db)
+		s.metrics = newAggregatorMetrics()
+		rule, err := rules.Parse("test", []string{"title:webinar"}, tt.actions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := applyRuleActions(t.Context(), s, userID, rule, post, feed, tt.notify); err != nil {
+			t.Errorf("%v: %v", tt.actions, err)
+			continue
+		}
+
+		states := db.called("SetPostState")
+		if (len(states) == 1) != tt.state || len(states) > 1 {
+			t.Errorf("%v ran SetPostState %d times", tt.actions, len(states))
+		}
+		if len(states) == 1 {
+			args := states[0]
+			if args[0] != userID.String() || args[1] != post.ID.String() {
+				t.Errorf("%v set the state of %v for %v", tt.actions, args[1], args[0])
+			}
+			for i, want := range []bool{tt.read, tt.starred, tt.hidden} {
+				if got := args[3+i] != nil; got != want {
+					t.Errorf("%v set %s = %v, want set: %v", tt.actions, []string{"read_at", "starred_at", "hidden_at"}[i], args[3+i], want)
+				}
+			}
+		}
+
+		var tags []string
+		for _, args := range db.called("AddPostTag") {
+			tags = append(tags, args[2].(string))
+		}
+		if len(tags) != len(tt.tags) || (len(tags) > 0 && tags[0] != tt.tags[0]) {
+			t.Errorf("%v added tags %v, want %v", tt.actions, tags, tt.tags)
+		}
+
+		notifications := db.called("CreateNotification")
+		if len(notifications) != tt.notifications {
+			t.Errorf("%v created %d notifications, want %d", tt.actions, len(notifications), tt.notifications)
+		}
+		for _, args := range notifications {
+			if args[2] != userID.String() || args[3] != feed.ID.String() || args[4] != "Rule test: Free webinar (Hacker News)" {
+				t.Errorf("%v notified with %v", tt.actions, args)
+			}
+		}
+	}
+}
+
+func TestApplyRules(t *testing.T) {
+	db := &fakeDB{}
+	s := newTestState(t, db)
+	s.metrics = newAggregatorMetrics()
+	feed := database.Feed{ID: uuid.New(), Name: "Security", Url: "https://example.com/security.xml"}
+	post := database.Post{
+		ID:          uuid.New(),
+		Title:       "Parser fix",
+		Description: sql.NullString{String: "<p>Fixes <b>CVE</b>&#8209;2024&#8209;1 &amp; more</p>", Valid: true},
+	}
+
+	var feedRules []userRule
+	for _, conditions := range [][]string{
+		{`description:/CVE.2024.1 & more/`},
+		{"title:parser", "feed:/^Security$/"},
+		{"title:webinar"},
+	} {
+		rule, err := rules.Parse("r", conditions, []string{"read"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		feedRules = append(feedRules, userRule{userID: uuid.New(), rule: rule})
+	}
+	applyRules(t.Context(), s, feed, post, feedRules, slog.New(slog.DiscardHandler))
+
+	// The description is matched as text, and only the first two rules
+	// match.
+	states := db.called("SetPostState")
+	if len(states) != 2 || states[0][0] != feedRules[0].userID.String() || states[1][0] != feedRules[1].userID.String() {
+		t.Errorf("SetPostState runs = %v, want one for each of the first two rules", states)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var systemdUnitTemplate = template.Must(template.New("unit").Parse(`[Unit]
+Description=gator RSS aggregator
+Wants=network-online.target
+After=network-online.target postgresql.service
+
+[Service]
+Type=simple
+ExecStart={{.ExecStart}}
+ExecReload=/bin/kill -HUP $MAINPID
+Restart=on-failure
+RestartSec=5s
+{{- if .User}}
+User={{.User}}
+{{- end}}
+
+[Install]
+WantedBy={{.WantedBy}}
+`))
+
+func handlerService(s *state, cmd command) error {
+	if len(cmd.args) == 0 || cmd.args[0] != "install" {
+		return errors.New("usage: service install [--interval <duration>] [--user-unit] [--output <path>]")
+	}
+
+	fs := flag.NewFlagSet("service install", flag.ContinueOnError)
+	interval := fs.Duration("interval", time.Minute, "time between requests for agg")
+	userUnit := fs.Bool("user-unit", false, "emit a systemd --user unit instead of a system unit")
+	output := fs.String("output", "", "write the unit to this file instead of stdout")
+	healthAddr := fs.String("health-addr", "127.0.0.1:8081", "passed to agg --health-addr")
+	args, err := parseCommandFlags(fs, cmd.args[1:])
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errors.New("too many command args given")
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locating gator binary: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	configPath, err := filepath.Abs(s.configPtr.Path())
+	if err != nil {
+		return err
+	}
+
+	// systemd tracks the main pid itself, so agg doesn't need a pidfile.
+	execArgs := []string{
+		exe, "--config", configPath,
+		"agg", "--daemon", "--pidfile=", "--health-addr", *healthAddr,
+		interval.String(),
+	}
+
+	data := struct {
+		ExecStart string
+		User      string
+		WantedBy  string
+	}{
+		ExecStart: systemdQuote(execArgs),
+		WantedBy:  "multi-user.target",
+	}
+	if *userUnit {
+		data.WantedBy = "default.target"
+	} else if u, err := user.Current(); err == nil {
+		data.User = u.Username
+	}
+
+	var b strings.Builder
+	if err := systemdUnitTemplate.Execute(&b, data); err != nil {
+		return err
+	}
+
+	if *output == "" {
+		fmt.Print(b.String())
+		return nil
+	}
+	if err := os.WriteFile(*output, []byte(b.String()), 0o644); err != nil {
+		return err
+	}
+
+	unit := filepath.Base(*output)
+	systemctl := "systemctl"
+	if *userUnit {
+		systemctl = "systemctl --user"
+	}
+	fmt.Printf("Unit written to %s\n", *output)
+	fmt.Printf("Enable it with: %s daemon-reload && %s enable --now %s\n", systemctl, systemctl, unit)
+	return nil
+}
+
+// systemdQuote joins args for ExecStart, quoting any that systemd would
+// otherwise split or expand.
+func systemdQuote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && !strings.ContainsAny(arg, " \t\"'\\$%") {
+			quoted[i] = arg
+			continue
+		}
+		arg = strings.ReplaceAll(arg, `\`, `\\`)
+		arg = strings.ReplaceAll(arg, `"`, `\"`)
+		arg = strings.ReplaceAll(arg, "$", "$$")
+		arg = strings.ReplaceAll(arg, "%", "%%")
+		quoted[i] = `"` + arg + `"`
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jjboykin/gator/internal/database"
+	"github.com/jjboykin/gator/internal/plaintext"
+)
+
+// handlerShow prints a single post with its full content, falling back to
+// the description for feeds that only publish a summary.
+func handlerShow(s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 1 {
+		return errors.New("usage: show <post-id>")
+	}
+	postID, err := uuid.Parse(cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("invalid post id: %w", err)
+	}
+
+	ctx := context.Background()
+	post, err := s.db.GetPost(ctx, postID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("post %s not found", postID)
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't get post: %w", err)
+	}
+	feed, err := s.db.GetFeed(ctx, post.FeedID)
+	if err != nil {
+		return fmt.Errorf("couldn't get feed: %w", err)
+	}
+
+	fmt.Printf("--- %s ---\n", post.Title)
+	fmt.Printf("Feed:       %s\n", feed.Name)
+	fmt.Printf("Published:  %s\n", post.PublishedAt)
+	if post.Author.Valid {
+		fmt.Printf("Author:     %s\n", post.Author.String)
+	}
+	if len(post.Categories) > 0 {
+		fmt.Printf("Categories: %s\n", strings.Join(post.Categories, ", "))
+	}
+	fmt.Printf("Link:       %s\n", post.Url)
+	if post.CommentsUrl.Valid {
+		fmt.Printf("Comments:   %s\n", post.CommentsUrl.String)
+	}
+	if err := printEnclosures(s, user.ID, post.ID); err != nil {
+		return err
+	}
+	if err := printPostTags(s, user.ID, post.ID); err != nil {
+		return err
+	}
+	fmt.Println()
+
+	body := postBody(post.Description, post.Content, post.ExtractedContent)
+	fmt.Println(plaintext.Render(body, bodyWidth))
+	return nil
+}
+
+// bodyWidth is the column at which post bodies are wrapped.
+const bodyWidth = 80
+
+// printIndented prints text with every non-empty line indented.
+func printIndented(text, indent string) {
+	for _, line := range strings.Split(text, "\n") {
+		if line != "" {
+			line = indent + line
+		}
+		fmt.Println(line)
+	}
+}
+
+// summarize collapses the whitespace of text and cuts it to at most n
+// characters, for one-paragraph previews.
+func summarize(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > n {
+		return string(runes[:n]) + "…"
+	}
+	return text
+}
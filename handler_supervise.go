@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// crashRecord is one line of the supervisor's crash history file.
+type crashRecord struct {
+	Time      time.Time     `json:"time"`
+	Command   []string      `json:"command"`
+	PID       int           `json:"pid"`
+	ExitCode  int           `json:"exit_code"`
+	Signal    string        `json:"signal,omitempty"`
+	Uptime    time.Duration `json:"uptime"`
+	RestartIn time.Duration `json:"restart_in,omitempty"`
+	GaveUp    bool          `json:"gave_up,omitempty"`
+}
+
+func handlerSupervise(s *state, cmd command) error {
+	fs := flag.NewFlagSet("supervise", flag.ContinueOnError)
+	maxRestarts := fs.Int("max-restarts", 5, "restart a crashed child at most this many times within --window")
+	window := fs.Duration("window", 10*time.Minute, "window for --max-restarts; a child that runs this long resets the backoff")
+	minBackoff := fs.Duration("min-backoff", time.Second, "delay before the first restart")
+	maxBackoff := fs.Duration("max-backoff", time.Minute, "maximum delay between restarts")
+	historyPath := fs.String("history", defaultCrashHistoryPath(), "file crash history is appended to")
+	// Flags must come before the supervised command so that its own flags
+	// (e.g. agg --daemon) are passed through untouched.
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(cmd.args); err != nil {
+		return err
+	}
+	args := fs.Args()
+
+	if len(args) == 0 {
+		return errors.New("usage: supervise [flags] agg <args...> | supervise history")
+	}
+	if args[0] == "history" {
+		return printCrashHistory(*historyPath)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locating gator binary: %w", err)
+	}
+	childArgs := append(append([]string{}, s.globalArgs...), args...)
+
+	signals := make(chan os.Signal, 4)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	backoff := *minBackoff
+	var crashes []time.Time
+	for {
+		child := exec.Command(exe, childArgs...)
+		child.Stdin = os.Stdin
+		child.Stdout = os.Stdout
+		child.Stderr = os.Stderr
+		child.SysProcAttr = childSysProcAttr()
+
+		started := time.Now()
+		if err := child.Start(); err != nil {
+			return fmt.Errorf("starting %s: %w", args[0], err)
+		}
+		s.logger().Info("started child", "pid", child.Process.Pid, "args", args)
+
+		exited := make(chan error, 1)
+		go func() { exited <- child.Wait() }()
+
+		stopping := false
+		var waitErr error
+	wait:
+		for {
+			select {
+			case sig := <-signals:
+				if sig != syscall.SIGHUP {
+					stopping = true
+				}
+				s.logger().Info("forwarding signal", "signal", sig, "pid", child.Process.Pid)
+				child.Process.Signal(sig)
+			case waitErr = <-exited:
+				break wait
+			}
+		}
+
+		uptime := time.Since(started)
+		if stopping {
+			s.logger().Info("child stopped", "pid", child.Process.Pid, "uptime", uptime)
+			return nil
+		}
+		if waitErr == nil {
+			s.logger().Info("child exited cleanly", "pid", child.Process.Pid, "uptime", uptime)
+			return nil
+		}
+
+		now := time.Now()
+		if uptime >= *window {
+			backoff = *minBackoff
+		}
+		crashes = recentCrashes(crashes, now, *window)
+
+		record := crashRecord{
+			Time:     now,
+			Command:  args,
+			PID:      child.Process.Pid,
+			ExitCode: child.ProcessState.ExitCode(),
+			Uptime:   uptime,
+		}
+		if status, ok := child.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			record.Signal = status.Signal().String()
+		}
+
+		if !mayRestart(crashes, *maxRestarts) {
+			record.GaveUp = true
+			appendCrashRecord(*historyPath, record)
+			return fmt.Errorf("%s crashed %d times within %s, giving up", args[0], len(crashes), *window)
+		}
+
+		record.RestartIn = backoff
+		if err := appendCrashRecord(*historyPath, record); err != nil {
+			s.logger().Warn("couldn't record crash", "error", err)
+		}
+		s.logger().Error("child crashed",
+			"pid", record.PID,
+			"exit_code", record.ExitCode,
+			"signal", record.Signal,
+			"uptime", uptime,
+			"restart_in", backoff,
+		)
+
+		select {
+		case <-time.After(backoff):
+		case sig := <-signals:
+			if sig != syscall.SIGHUP {
+				s.logger().Info("stopping before restart", "signal", sig)
+				return nil
+			}
+		}
+		backoff = min(backoff*2, *maxBackoff)
+	}
+}
+
+// recentCrashes adds a crash at now to crashes and drops the crashes that
+// are outside window.
+func recentCrashes(crashes []time.Time, now time.Time, window time.Duration) []time.Time {
+	recent := crashes[:0]
+	for _, t := range crashes {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	return append(recent, now)
+}
+
+// mayRestart reports whether the child may be restarted after the recent
+// crashes. Each crash but the first in the window follows a restart, so
+// --max-restarts N allows N restarts and gives up on crash N+1.
+func mayRestart(crashes []time.Time, maxRestarts int) bool {
+	return len(crashes) <= maxRestarts
+}
+
+func defaultCrashHistoryPath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "gator", "crashes.jsonl")
+}
+
+func appendCrashRecord(path string, record crashRecord) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(record)
+}
+
+func printCrashHistory(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Println("No crashes recorded")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record crashRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		reason := fmt.Sprintf("exit code %d", record.ExitCode)
+		if record.Signal != "" {
+			reason = "signal " + record.Signal
+		}
+		next := fmt.Sprintf("restarted after %s", record.RestartIn)
+		if record.GaveUp {
+			next = "gave up"
+		}
+		fmt.Printf("%s  pid %d  %s after %s, %s\n",
+			record.Time.Format(time.RFC3339), record.PID, reason, record.Uptime.Round(time.Second), next)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// --max-restarts N restarts the child N times within the window and gives
+// up when it crashes again.
+func TestMayRestart(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, maxRestarts := range []int{0, 1, 5} {
+		var crashes []time.Time
+		restarts := 0
+		for i := range maxRestarts + 1 {
+			crashes = recentCrashes(crashes, start.Add(time.Duration(i)*time.Second), time.Minute)
+			if !mayRestart(crashes, maxRestarts) {
+				break
+			}
+			restarts++
+		}
+		if restarts != maxRestarts {
+			t.Errorf("--max-restarts %d restarted %d times", maxRestarts, restarts)
+		}
+	}
+}
+
+func TestRecentCrashes(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var crashes []time.Time
+	for _, offset := range []time.Duration{0, 30 * time.Second, 50 * time.Second} {
+		crashes = recentCrashes(crashes, start.Add(offset), time.Minute)
+	}
+	if len(crashes) != 3 || !mayRestart(crashes, 3) || mayRestart(crashes, 2) {
+		t.Fatalf("after 3 crashes within a minute: %v", crashes)
+	}
+
+	// Crashes a window or more ago no longer count.
+	crashes = recentCrashes(crashes, start.Add(time.Minute), time.Minute)
+	if len(crashes) != 3 || !crashes[0].Equal(start.Add(30*time.Second)) {
+		t.Errorf("after a crash a minute later: %v", crashes)
+	}
+	crashes = recentCrashes(crashes, start.Add(time.Hour), time.Minute)
+	if len(crashes) != 1 {
+		t.Errorf("after a crash an hour later: %v", crashes)
+	}
+}
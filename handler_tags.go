@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/jjboykin/gator/internal/database"
+)
+
+// normalizeTag lowercases a tag so that "Security" and "security" are the
+// same label. Tags are single words so they can be typed on the command
+// line and completed by the shell.
+func normalizeTag(tag string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(tag))
+	if normalized == "" || strings.ContainsFunc(normalized, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ','
+	}) {
+		return "", fmt.Errorf("invalid tag %q", tag)
+	}
+	return normalized, nil
+}
+
+// lookupPostID parses a post id and checks that the post exists.
+func lookupPostID(ctx context.Context, s *state, arg string) (uuid.UUID, error) {
+	postID, err := uuid.Parse(arg)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid post id: %w", err)
+	}
+	if _, err := s.db.GetPost(ctx, postID); errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, fmt.Errorf("post %s not found", postID)
+	} else if err != nil {
+		return uuid.Nil, fmt.Errorf("couldn't get post: %w", err)
+	}
+	return postID, nil
+}
+
+func handlerTag(s *state, cmd command, user database.User) error {
+	if len(cmd.args) < 2 {
+		return errors.New("usage: tag <post-id> <tags...>")
+	}
+	tags := make([]string, 0, len(cmd.args)-1)
+	for _, arg := range cmd.args[1:] {
+		tag, err := normalizeTag(arg)
+		if err != nil {
+			return err
+		}
+		tags = append(tags, tag)
+	}
+
+	ctx := context.Background()
+	postID, err := lookupPostID(ctx, s, cmd.args[0])
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		err := s.db.AddPostTag(ctx, database.AddPostTagParams{
+			UserID:    user.ID,
+			PostID:    postID,
+			Tag:       tag,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("couldn't tag post: %w", err)
+		}
+	}
+	return printPostTags(s, user.ID, postID)
+}
+
+// handlerUntag removes the given tags from a post, or all of them when
+// none are given.
+func handlerUntag(s *state, cmd command, user database.User) error {
+	if len(cmd.args) < 1 {
+		return errors.New("usage: untag <post-id> [tags...]")
+	}
+	tags := make([]string, 0, len(cmd.args)-1)
+	for _, arg := range cmd.args[1:] {
+		tag, err := normalizeTag(arg)
+		if err != nil {
+			return err
+		}
+		tags = append(tags, tag)
+	}
+
+	ctx := context.Background()
+	postID, err := lookupPostID(ctx, s, cmd.args[0])
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		err := s.db.RemoveAllPostTags(ctx, database.RemoveAllPostTagsParams{UserID: user.ID, PostID: postID})
+		if err != nil {
+			return fmt.Errorf("couldn't untag post: %w", err)
+		}
+		fmt.Printf("Removed all tags from %s\n", postID)
+		return nil
+	}
+	for _, tag := range tags {
+		err := s.db.RemovePostTag(ctx, database.RemovePostTagParams{UserID: user.ID, PostID: postID, Tag: tag})
+		if err != nil {
+			return fmt.Errorf("couldn't untag post: %w", err)
+		}
+	}
+	return printPostTags(s, user.ID, postID)
+}
+
+// handlerTags lists the user's tags with the number of posts carrying each.
+func handlerTags(s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 0 {
+		return errors.New("too many command args given")
+	}
+	counts, err := s.db.GetTagCountsForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't get tags: %w", err)
+	}
+	if len(counts) == 0 {
+		fmt.Println("No tags yet; add some with `gator tag <post-id> <tags...>`")
+		return nil
+	}
+	for _, count := range counts {
+		fmt.Printf("%-24s %d\n", count.Tag, count.Posts)
+	}
+	return nil
+}
+
+// printPostTags prints the tags the user put on a post, if any.
+func printPostTags(s *state, userID, postID uuid.UUID) error {
+	tags, err := s.db.GetTagsForPost(context.Background(), database.GetTagsForPostParams{
+		UserID: userID,
+		PostID: postID,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't get tags: %w", err)
+	}
+	if len(tags) > 0 {
+		fmt.Printf("Tags: %s\n", strings.Join(tags, ", "))
+	}
+	return nil
+}
+
+// completionScript completes command names, and tag names after
+// `tag <post-id>`, `untag <post-id>` and `browse --tag`. It asks gator for
+// the tags each time, so they are always current.
+const completionScript = `# gator shell completion; load with: source <(gator completion %[1]s)
+%[2]s_gator() {
+    local cur prev cmd
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+    cmd="${COMP_WORDS[1]}"
+
+    if [ "$COMP_CWORD" -eq 1 ]; then
+        COMPREPLY=($(compgen -W "%[3]s" -- "$cur"))
+        return
+    fi
+    if { [ "$cmd" = tag ] || [ "$cmd" = untag ]; } && [ "$COMP_CWORD" -ge 3 ] ||
+        { [ "$cmd" = browse ] && [ "$prev" = --tag ]; }; then
+        COMPREPLY=($(compgen -W "$("${COMP_WORDS[0]}" completion tags 2>/dev/null)" -- "$cur"))
+    fi
+}
+complete -F _gator gator
+`
+
+// handlerCompletion prints a completion script for bash or zsh, or, for
+// the script's own use, the current user's tags one per line.
+func handlerCompletion(c *commands) func(*state, command) error {
+	return func(s *state, cmd command) error {
+		if len(cmd.args) != 1 {
+			return errors.New("usage: completion bash|zsh|tags")
+		}
+		switch cmd.args[0] {
+		case "bash":
+			fmt.Printf(completionScript, "bash", "", strings.Join(c.names(), " "))
+		case "zsh":
+			fmt.Printf(completionScript, "zsh", "autoload -U +X bashcompinit && bashcompinit\n", strings.Join(c.names(), " "))
+		case "tags":
+			// Completion must stay quiet, so a missing user or database
+			// just means nothing to complete.
+			user, err := s.db.GetUserByName(context.Background(), s.configPtr.CurrentUserName)
+			if err != nil {
+				return nil
+			}
+			counts, err := s.db.GetTagCountsForUser(context.Background(), user.ID)
+			if err != nil {
+				return nil
+			}
+			for _, count := range counts {
+				fmt.Println(count.Tag)
+			}
+		default:
+			return fmt.Errorf("unknown shell: %s", cmd.args[0])
+		}
+		return nil
+	}
+}
+
+// names returns the registered command names in alphabetical order.
+func (c *commands) names() []string {
+	names := make([]string, 0, len(c.commandHandler))
+	for name := range c.commandHandler {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jjboykin/gator/internal/database"
+	"github.com/jjboykin/gator/internal/sanitize"
+)
+
+// ingestResult counts what happened to the items of one feed document.
+type ingestResult struct {
+	Inserted   int
+	Duplicates int
+	Failed     int
+
+	// published holds the parsed date of every item, for scheduling.
+	published []time.Time
+}
+
+// ingestFeed stores the items of a feed document as posts of feed. It is
+// shared by polling (scrapeFeeds) and WebSub content pushes.
+func ingestFeed(ctx context.Context, s *state, feed database.Feed, fetched *RSSFeed, logger *slog.Logger) ingestResult {
+	var result ingestResult
+
+	if len(fetched.Repairs) > 0 {
+		var repairs []string
+		for _, repair := range fetched.Repairs {
+			repairs = append(repairs, repair.String())
+			s.metrics.feedRepairs.Inc(repair.Kind)
+		}
+		logger.Warn("feed document was malformed and repaired", "repairs", strings.Join(repairs, ", "))
+	}
+
+	// The rules and alerts of the feed's followers are only loaded once a
+	// post is actually new.
+	var feedRules []userRule
+	var feedAlerts []feedAlert
+	rulesLoaded := false
+
+	// Extraction waits until every item is stored.
+	var extract []database.Post
+
+	for _, item := range fetched.Channel.Item {
+
+		// Feed HTML is untrusted; relative URLs in it refer to the article.
+		base, err := url.Parse(item.Link)
+		if err != nil || !base.IsAbs() {
+			base = nil
+		}
+		content := sanitize.HTML(item.Content, base)
+
+		nullableStringDescription := sql.NullString{
+			String: sanitize.HTML(item.Description, base),
+			Valid:  true,
+		}
+
+		parsedPubDate := parsePubDate(item.PubDate)
+		result.published = append(result.published, parsedPubDate)
+
+		postParams := database.CreatePostParams{
+			ID:          uuid.New(),
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+			Title:       item.Title,
+			Url:         item.Link,
+			Description: nullableStringDescription,
+			PublishedAt: parsedPubDate,
+			FeedID:      feed.ID,
+			Content:     sql.NullString{String: content, Valid: content != ""},
+			Author:      sql.NullString{String: item.author(), Valid: item.author() != ""},
+			Categories:  item.categories(),
+			CommentsUrl: sql.NullString{String: item.Comments, Valid: item.Comments != ""},
+		}
+
+		post, err := s.db.CreatePost(ctx, postParams)
+		if errors.Is(err, sql.ErrNoRows) {
+			result.Duplicates++
+			s.metrics.postsDuplicated.Inc()
+			continue
+		}
+		if err != nil {
+			result.Failed++
+			logger.Error("couldn't create post", "post_url", item.Link, "error", err)
+			continue
+		}
+
+		result.Inserted++
+		s.metrics.postsInserted.Inc()
+		logger.Debug("post created", "post_id", post.ID, "title", post.Title)
+
+		if err := storeEnclosures(ctx, s, post, item); err != nil {
+			logger.Error("couldn't store enclosures", "post_id", post.ID, "error", err)
+		}
+
+		if !rulesLoaded {
+			feedRules, err = loadFeedRules(ctx, s, feed.ID, logger)
+			if err != nil {
+				logger.Error("couldn't load rules", "error", err)
+			}
+			feedAlerts, err = loadFeedAlerts(ctx, s, feed.ID, logger)
+			if err != nil {
+				logger.Error("couldn't load alerts", "error", err)
+			}
+			rulesLoaded = true
+		}
+		applyRules(ctx, s, feed, post, feedRules, logger)
+		triggerAlerts(ctx, s, feed, post, feedAlerts, logger)
+
+		if feed.Fulltext {
+			extract = append(extract, post)
+		}
+	}
+
+	if len(extract) > 0 {
+		extractNewPosts(ctx, s, extract, logger)
+	}
+	return result
+}
+
+// storeEnclosures records the media files and podcast metadata of item.
+func storeEnclosures(ctx context.Context, s *state, post database.Post, item RSSItem) error {
+	for _, enclosure := range item.Enclosures {
+		if enclosure.URL == "" {
+			continue
+		}
+		length, _ := strconv.ParseInt(strings.TrimSpace(enclosure.Length), 10, 64)
+		err := s.db.CreatePostEnclosure(ctx, database.CreatePostEnclosureParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			PostID:    post.ID,
+			Url:       enclosure.URL,
+			MimeType:  enclosure.Type,
+			Length:    max(length, 0),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if !item.isPodcastEpisode() {
+		return nil
+	}
+	params := database.CreatePodcastEpisodeParams{
+		PostID:    post.ID,
+		CreatedAt: time.Now(),
+		ImageUrl:  sql.NullString{String: item.ITunesImage.Href, Valid: item.ITunesImage.Href != ""},
+	}
+	if d, ok := parseITunesDuration(item.ITunesDuration); ok {
+		params.DurationSeconds = sql.NullInt32{Int32: int32(d / time.Second), Valid: true}
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(item.ITunesEpisode)); err == nil {
+		params.Episode = sql.NullInt32{Int32: int32(n), Valid: true}
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(item.ITunesSeason)); err == nil {
+		params.Season = sql.NullInt32{Int32: int32(n), Valid: true}
+	}
+	if explicit, ok := parseITunesExplicit(item.ITunesExplicit); ok {
+		params.Explicit = sql.NullBool{Bool: explicit, Valid: true}
+	}
+	return s.db.CreatePodcastEpisode(ctx, params)
+}
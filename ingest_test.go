@@ -0,0 +1,63 @@
+package main
+
+import (
+	"database/sql/driver"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jjboykin/gator/internal/database"
+)
+
+// CreatePost returns no row for a URL that is already stored, which
+// ingestFeed counts as a duplicate rather than a failure.
+func TestIngestFeedDuplicates(t *testing.T) {
+	fetched, err := decodeFeed([]byte(`<rss version="2.0"><channel><title>Example</title>
+<item><title>Stored</title><link>https://example.com/1</link></item>
+<item><title>New</title><link>https://example.com/2</link></item>
+<item><title>Also stored</title><link>https://example.com/3</link></item>
+<item><title>Broken</title><link>https://example.com/4</link></item>
+</channel></rss>`), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := &fakeDB{}
+	stored := map[string]bool{"https://example.com/1": true, "https://example.com/3": true}
+	db.onArgs("CreatePost", func(args []driver.Value) []any {
+		postURL := args[4].(string)
+		switch {
+		case stored[postURL]:
+			return nil
+		case postURL == "https://example.com/4":
+			return []any{errors.New("connection reset")}
+		}
+		return []any{database.Post{ID: uuid.New(), Url: postURL}}
+	})
+	s := newTestState(t, db)
+	s.metrics = newAggregatorMetrics()
+	feed := database.Feed{ID: uuid.New(), Url: "https://example.com/rss"}
+
+	result := ingestFeed(t.Context(), s, feed, fetched, slog.New(slog.DiscardHandler))
+	if result.Inserted != 1 || result.Duplicates != 2 || result.Failed != 1 {
+		t.Errorf("ingestFeed = %d inserted, %d duplicates, %d failed, want 1, 2, 1", result.Inserted, result.Duplicates, result.Failed)
+	}
+	// Rules only run on the new post.
+	if runs := db.called("GetRulesForFeed"); len(runs) != 1 {
+		t.Errorf("rules loaded %d times, want once", len(runs))
+	}
+
+	// A scrape that finds nothing new doesn't load rules at all.
+	db = &fakeDB{}
+	db.onArgs("CreatePost", func(args []driver.Value) []any { return nil })
+	s = newTestState(t, db)
+	s.metrics = newAggregatorMetrics()
+	result = ingestFeed(t.Context(), s, feed, fetched, slog.New(slog.DiscardHandler))
+	if result.Inserted != 0 || result.Duplicates != 4 || result.Failed != 0 {
+		t.Errorf("ingestFeed again = %d inserted, %d duplicates, %d failed, want 0, 4, 0", result.Inserted, result.Duplicates, result.Failed)
+	}
+	if runs := db.called("GetRulesForFeed"); len(runs) != 0 {
+		t.Errorf("rules loaded %d times for a scrape without new posts", len(runs))
+	}
+}
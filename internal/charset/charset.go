@@ -0,0 +1,194 @@
+// Package charset detects the character encoding of feed and web page
+// bodies and converts them to UTF-8. Only the encodings commonly seen in
+// feeds are supported: UTF-8, UTF-16, ISO-8859-1/Windows-1252,
+// ISO-8859-2, ISO-8859-15, Windows-1251, KOI8-R and Shift_JIS.
+package charset
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ErrUnsupported is returned by ToUTF8 for an encoding it doesn't know.
+var ErrUnsupported = errors.New("unsupported character encoding")
+
+var (
+	xmlDeclEncoding = regexp.MustCompile(`^<\?xml[^>]*\sencoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+	metaCharset     = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?([A-Za-z0-9._:-]+)`)
+)
+
+// Detect returns the encoding of data, checking in order a byte order
+// mark, the charset parameter of contentType, an XML declaration and an
+// HTML <meta> charset. It returns "utf-8" when nothing says otherwise.
+func Detect(data []byte, contentType string) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return "utf-8"
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return "utf-16le"
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return "utf-16be"
+	}
+
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["charset"] != "" {
+		return Normalize(params["charset"])
+	}
+
+	head := data[:min(len(data), 1024)]
+	if m := xmlDeclEncoding.FindSubmatch(bytes.TrimLeft(head, " \t\r\n")); m != nil {
+		return Normalize(string(m[1]))
+	}
+	if m := metaCharset.FindSubmatch(head); m != nil {
+		return Normalize(string(m[1]))
+	}
+	return "utf-8"
+}
+
+// Normalize maps the many labels an encoding goes by to a canonical name.
+func Normalize(label string) string {
+	label = strings.ToLower(strings.TrimSpace(label))
+	switch label {
+	case "utf8", "unicode-1-1-utf-8":
+		return "utf-8"
+	case "utf-16", "utf16", "ucs-2":
+		return "utf-16le"
+	// As in browsers, Latin-1 and ASCII labels mean Windows-1252, a
+	// superset that servers routinely mislabel as ISO-8859-1.
+	case "iso-8859-1", "iso8859-1", "iso_8859-1", "latin1", "l1", "cp1252",
+		"x-cp1252", "cp819", "ibm819", "us-ascii", "ascii", "ansi_x3.4-1968":
+		return "windows-1252"
+	case "iso8859-2", "iso_8859-2", "latin2", "l2":
+		return "iso-8859-2"
+	case "iso8859-15", "iso_8859-15", "latin9", "l9":
+		return "iso-8859-15"
+	case "cp1251", "x-cp1251":
+		return "windows-1251"
+	case "koi8r", "koi", "koi8", "cskoi8r":
+		return "koi8-r"
+	case "shift-jis", "sjis", "x-sjis", "ms_kanji", "csshiftjis", "windows-31j", "cp932", "ms932":
+		return "shift_jis"
+	}
+	return label
+}
+
+var singleByte = map[string]*[128]rune{
+	"windows-1252": &windows1252,
+	"windows-1251": &windows1251,
+	"koi8-r":       &koi8r,
+	"iso-8859-2":   &iso88592,
+	"iso-8859-15":  &iso885915,
+}
+
+// ToUTF8 converts data from the named encoding to UTF-8. Byte sequences
+// that are invalid in the source encoding become U+FFFD rather than
+// failing the conversion. A leading byte order mark is removed.
+func ToUTF8(data []byte, encoding string) ([]byte, error) {
+	encoding = Normalize(encoding)
+	switch encoding {
+	case "utf-8":
+		data = bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})
+		if utf8.Valid(data) {
+			return data, nil
+		}
+		return bytes.ToValidUTF8(data, []byte("\uFFFD")), nil
+	case "utf-16le", "utf-16be":
+		return decodeUTF16(data, encoding == "utf-16be"), nil
+	case "shift_jis":
+		return decodeShiftJIS(data), nil
+	}
+	if table, ok := singleByte[encoding]; ok {
+		return decodeSingleByte(data, table), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupported, encoding)
+}
+
+func decodeSingleByte(data []byte, table *[128]rune) []byte {
+	out := make([]byte, 0, len(data)+len(data)/4)
+	for _, b := range data {
+		if b < 0x80 {
+			out = append(out, b)
+			continue
+		}
+		out = utf8.AppendRune(out, table[b-0x80])
+	}
+	return out
+}
+
+func decodeUTF16(data []byte, bigEndian bool) []byte {
+	switch {
+	case bigEndian && bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		data = data[2:]
+	case !bigEndian && bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		data = data[2:]
+	}
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+		} else {
+			units = append(units, uint16(data[i+1])<<8|uint16(data[i]))
+		}
+	}
+	out := make([]byte, 0, len(units)*2)
+	for _, r := range utf16.Decode(units) {
+		out = utf8.AppendRune(out, r)
+	}
+	if len(data)%2 == 1 {
+		out = utf8.AppendRune(out, utf8.RuneError)
+	}
+	return out
+}
+
+func decodeShiftJIS(data []byte) []byte {
+	out := make([]byte, 0, len(data)*3/2)
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case b < 0x80:
+			out = append(out, b)
+		case b >= 0xA1 && b <= 0xDF:
+			// Half-width katakana.
+			out = utf8.AppendRune(out, rune(0xFF61+int(b)-0xA1))
+		case (b >= 0x81 && b <= 0x9F || b >= 0xE0 && b <= 0xFC) && i+1 < len(data):
+			r := sjisRune(b, data[i+1])
+			if r == 0 {
+				out = utf8.AppendRune(out, utf8.RuneError)
+				// An invalid trail byte may be ASCII; don't swallow it.
+				if data[i+1] >= 0x80 {
+					i++
+				}
+				continue
+			}
+			out = utf8.AppendRune(out, r)
+			i++
+		default:
+			out = utf8.AppendRune(out, utf8.RuneError)
+		}
+	}
+	return out
+}
+
+func sjisRune(lead, trail byte) rune {
+	var row int
+	if lead <= 0x9F {
+		row = int(lead) - 0x81
+	} else {
+		row = int(lead) - 0xE0 + 31
+	}
+	var col int
+	switch {
+	case trail >= 0x40 && trail <= 0x7E:
+		col = int(trail) - 0x40
+	case trail >= 0x80 && trail <= 0xFC:
+		col = int(trail) - 0x41
+	default:
+		return 0
+	}
+	return rune(sjisTable[row*188+col])
+}
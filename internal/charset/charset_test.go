@@ -0,0 +1,134 @@
+package charset
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		data        string
+		contentType string
+		want        string
+	}{
+		{"UTF-8 BOM over the header", "\xef\xbb\xbf<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>", "text/xml; charset=iso-8859-1", "utf-8"},
+		{"UTF-16LE BOM", "\xff\xfe<\x00?\x00", "text/xml; charset=utf-8", "utf-16le"},
+		{"UTF-16BE BOM", "\xfe\xff\x00<\x00?", "", "utf-16be"},
+		{"header over the XML declaration", `<?xml version="1.0" encoding="ISO-8859-1"?>`, "application/rss+xml; charset=KOI8-R", "koi8-r"},
+		{"header label", "<rss/>", `text/xml; charset="Shift-JIS"`, "shift_jis"},
+		{"header without charset", `<?xml version="1.0" encoding="windows-1251"?>`, "application/rss+xml", "windows-1251"},
+		{"malformed header", `<?xml version="1.0" encoding="windows-1251"?>`, "text/xml; charset", "windows-1251"},
+		{"XML declaration", `<?xml version='1.0' encoding='Shift_JIS'?><rss/>`, "", "shift_jis"},
+		{"XML declaration after whitespace", "\r\n  <?xml version=\"1.0\" encoding=\"latin1\"?>", "", "windows-1252"},
+		{"XML declaration without encoding", `<?xml version="1.0"?><rss/>`, "", "utf-8"},
+		{"encoding outside the declaration", `<rss encoding="koi8-r"/>`, "", "utf-8"},
+		{"meta charset", `<html><head><meta charset="windows-1251">`, "text/html", "windows-1251"},
+		{"meta http-equiv", `<html><head><META http-equiv="Content-Type" content="text/html; charset=ISO-8859-2">`, "", "iso-8859-2"},
+		{"meta too far in", "<html>" + strings.Repeat(" ", 1024) + `<meta charset="koi8-r">`, "", "utf-8"},
+		{"nothing", "<rss/>", "", "utf-8"},
+	} {
+		if got := Detect([]byte(tt.data), tt.contentType); got != tt.want {
+			t.Errorf("%s: Detect = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for _, tt := range []struct {
+		label, want string
+	}{
+		{"UTF8", "utf-8"},
+		{"utf-8", "utf-8"},
+		{"UTF-16", "utf-16le"},
+		{"ISO-8859-1", "windows-1252"},
+		{" Latin1 ", "windows-1252"},
+		{"US-ASCII", "windows-1252"},
+		{"cp1251", "windows-1251"},
+		{"ISO-8859-2", "iso-8859-2"},
+		{"latin9", "iso-8859-15"},
+		{"KOI8", "koi8-r"},
+		{"Windows-31J", "shift_jis"},
+		{"x-mac-roman", "x-mac-roman"},
+	} {
+		if got := Normalize(tt.label); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.label, got, tt.want)
+		}
+	}
+}
+
+func TestToUTF8(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		data     string
+		encoding string
+		want     string
+	}{
+		{"UTF-8", "caf\xc3\xa9", "utf-8", "café"},
+		{"UTF-8 BOM", "\xef\xbb\xbfcaf\xc3\xa9", "utf-8", "café"},
+		{"invalid UTF-8", "caf\xe9 \xc3", "utf-8", "caf� �"},
+		{"Windows-1252", "\x93caf\xe9\x94 \x80", "iso-8859-1", "“café” €"},
+		{"KOI8-R", "\xf0\xd2\xc9\xd7\xc5\xd4", "koi8-r", "Привет"},
+		{"Shift_JIS", "\x93\xfa\x96\x7b \x82\xa0 \xb1", "shift_jis", "日本 あ ｱ"},
+		{"Shift_JIS ASCII trail byte", "\x82<b>", "shift_jis", "�<b>"},
+		{"Shift_JIS DEL trail byte", "\x82\x7fx", "shift_jis", "�\x7fx"},
+		{"Shift_JIS invalid high trail byte", "\x82\xfdx", "shift_jis", "�x"},
+		{"Shift_JIS lead byte at the end", "ab\x82", "shift_jis", "ab�"},
+		{"Shift_JIS invalid byte", "a\x80b\xa0c", "shift_jis", "a�b�c"},
+		{"UTF-16LE", "\xff\xfeh\x00i\x00=\xd8\x00\xde", "utf-16le", "hi😀"},
+		{"UTF-16BE", "\xfe\xff\x00h\x00i", "utf-16be", "hi"},
+		{"UTF-16 odd length", "\xff\xfeh\x00i", "utf-16le", "h�"},
+		{"UTF-16BE odd length", "\x00h\x00", "utf-16be", "h�"},
+		{"UTF-16 unpaired surrogate", "=\xd8h\x00", "utf-16", "�h"},
+	} {
+		got, err := ToUTF8([]byte(tt.data), tt.encoding)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("%s: ToUTF8 = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+
+	if _, err := ToUTF8([]byte("x"), "ebcdic"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("ToUTF8 to ebcdic: %v, want ErrUnsupported", err)
+	}
+}
+
+// The documents in testdata declare their encoding the way feeds do.
+func TestTestdata(t *testing.T) {
+	for _, tt := range []struct {
+		file     string
+		encoding string
+		title    string
+	}{
+		{"iso-8859-1.xml", "windows-1252", "Café crème"},
+		{"windows-1252.xml", "windows-1252", "“Smart” quotes — €5"},
+		{"shift_jis.xml", "shift_jis", "日本語のニュース"},
+		{"koi8-r.xml", "koi8-r", "Новости дня"},
+		{"utf-8-bom.xml", "utf-8", "Ünïcödé ✓"},
+		// Declared as ISO-8859-1 but actually UTF-8, which only a charset
+		// in the Content-Type header can fix.
+		{"mismatch.xml", "windows-1252", "NaÃ¯ve"},
+	} {
+		data, err := os.ReadFile(filepath.Join("testdata", tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoding := Detect(data, "")
+		if encoding != tt.encoding {
+			t.Errorf("%s: Detect = %q, want %q", tt.file, encoding, tt.encoding)
+		}
+		converted, err := ToUTF8(data, encoding)
+		if err != nil {
+			t.Errorf("%s: ToUTF8: %v", tt.file, err)
+			continue
+		}
+		if !strings.Contains(string(converted), "<title>"+tt.title) {
+			t.Errorf("%s: title %q not in\n%s", tt.file, tt.title, converted)
+		}
+		if strings.HasPrefix(string(converted), "\uFEFF") {
+			t.Errorf("%s: BOM kept", tt.file)
+		}
+	}
+}
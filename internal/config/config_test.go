@@ -0,0 +1,201 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// isolate points the config lookup at an empty home directory and clears
+// the GATOR_* environment.
+func isolate(t *testing.T) (home string) {
+	t.Helper()
+	home = t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("GATOR_CONFIG", "")
+	typ := reflect.TypeFor[Config]()
+	for i := range typ.NumField() {
+		if name := typ.Field(i).Tag.Get("env"); name != "" {
+			t.Setenv(name, "")
+		}
+	}
+	return home
+}
+
+func writeConfig(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	home := isolate(t)
+	path := filepath.Join(home, configFileName)
+	writeConfig(t, path, `{
+		"db_url": "postgres://file",
+		"log_level": "debug",
+		"serve_addr": ":1111",
+		"smtp_port": 2525,
+		"min_fetch_interval": "5m",
+		"unknown_key": true
+	}`)
+	t.Setenv("GATOR_LOG_LEVEL", "warn")
+	t.Setenv("GATOR_SERVE_ADDR", ":2222")
+	t.Setenv("GATOR_LOG_FORMAT", "")
+
+	cfg, err := Load(Options{Overrides: map[string]string{"serve_addr": ":3333"}})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Path() != path || !cfg.Exists() {
+		t.Errorf("Path() = %q, Exists() = %v, want %q, true", cfg.Path(), cfg.Exists(), path)
+	}
+	for _, tt := range []struct {
+		key    string
+		got    any
+		want   any
+		source Source
+	}{
+		{"db_url", cfg.DBUrl, "postgres://file", SourceFile},
+		{"log_level", cfg.LogLevel, "warn", SourceEnv},
+		{"serve_addr", cfg.ServeAddr, ":3333", SourceFlag},
+		{"smtp_port", cfg.SMTPPort, 2525, SourceFile},
+		{"min_fetch_interval", cfg.MinFetchInterval, Duration(5 * time.Minute), SourceFile},
+		// An empty variable doesn't override anything.
+		{"log_format", cfg.LogFormat, "text", SourceDefault},
+		{"max_fetch_interval", cfg.MaxFetchInterval, Duration(24 * time.Hour), SourceDefault},
+	} {
+		if tt.got != tt.want || cfg.Source(tt.key) != tt.source {
+			t.Errorf("%s = %v from %s, want %v from %s", tt.key, tt.got, cfg.Source(tt.key), tt.want, tt.source)
+		}
+	}
+	if got := cfg.Source("unknown_key"); got != SourceDefault {
+		t.Errorf("Source(unknown_key) = %s", got)
+	}
+
+	var shown int
+	for _, field := range cfg.Fields() {
+		if field.Key == "serve_addr" {
+			shown++
+			if field.Value != ":3333" || field.Source != SourceFlag {
+				t.Errorf("Fields() has serve_addr = %+v", field)
+			}
+		}
+	}
+	if shown != 1 {
+		t.Errorf("Fields() lists serve_addr %d times", shown)
+	}
+}
+
+func TestLoadWithoutFile(t *testing.T) {
+	home := isolate(t)
+	cfg, err := Load(Options{})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Exists() || cfg.Path() != filepath.Join(home, configFileName) {
+		t.Errorf("Path() = %q, Exists() = %v, want the legacy path, false", cfg.Path(), cfg.Exists())
+	}
+	if cfg.DBUrl != defaultDBUrl || cfg.Source("db_url") != SourceDefault {
+		t.Errorf("db_url = %q from %s, want the default", cfg.DBUrl, cfg.Source("db_url"))
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		file      string
+		env       map[string]string
+		overrides map[string]string
+	}{
+		{name: "malformed file", file: `{"db_url": `},
+		{name: "wrong type in file", file: `{"smtp_port": "587"}`},
+		{name: "bad environment value", env: map[string]string{"GATOR_SMTP_PORT": "five"}},
+		{name: "bad duration in environment", env: map[string]string{"GATOR_MIN_FETCH_INTERVAL": "soon"}},
+		{name: "bad flag value", overrides: map[string]string{"allow_private_addresses": "maybe"}},
+		{name: "unknown flag key", overrides: map[string]string{"db": "postgres://flag"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			home := isolate(t)
+			if tt.file != "" {
+				writeConfig(t, filepath.Join(home, configFileName), tt.file)
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			if _, err := Load(Options{Overrides: tt.overrides}); err == nil {
+				t.Error("Load succeeded")
+			}
+		})
+	}
+}
+
+func TestConfigFileLookup(t *testing.T) {
+	home := isolate(t)
+	xdg := filepath.Join(home, "xdg")
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	legacy := filepath.Join(home, configFileName)
+	dotConfig := filepath.Join(home, ".config", "gator", "config.json")
+	xdgConfig := filepath.Join(xdg, "gator", "config.json")
+	envConfig := filepath.Join(home, "env.json")
+	flagConfig := filepath.Join(home, "flag.json")
+
+	// Each file, as it is created, takes over from the previous ones.
+	for _, path := range []string{legacy, dotConfig, xdgConfig} {
+		writeConfig(t, path, `{"db_url": "`+path+`"}`)
+		cfg, err := Load(Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Path() != path || cfg.DBUrl != path {
+			t.Errorf("with %s: read %s (db_url %s)", path, cfg.Path(), cfg.DBUrl)
+		}
+	}
+
+	// GATOR_CONFIG and --config name a file even if it doesn't exist yet.
+	t.Setenv("GATOR_CONFIG", envConfig)
+	if cfg, err := Load(Options{}); err != nil || cfg.Path() != envConfig || cfg.Exists() {
+		t.Errorf("with GATOR_CONFIG: Load = %q, exists %v, %v", cfg.Path(), cfg.Exists(), err)
+	}
+	if cfg, err := Load(Options{Path: flagConfig}); err != nil || cfg.Path() != flagConfig {
+		t.Errorf("with --config: Load = %q, %v", cfg.Path(), err)
+	}
+}
+
+func TestSetUserKeepsOtherKeys(t *testing.T) {
+	home := isolate(t)
+	path := filepath.Join(home, configFileName)
+	writeConfig(t, path, `{"db_url": "postgres://file", "unknown_key": [1, 2]}`)
+	t.Setenv("GATOR_LOG_LEVEL", "warn")
+
+	cfg, err := Load(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetUser("alice"); err != nil {
+		t.Fatalf("SetUser: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored map[string]any
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"db_url": "postgres://file", "unknown_key": []any{1.0, 2.0}, "current_user_name": "alice"}
+	if !reflect.DeepEqual(stored, want) {
+		t.Errorf("config file = %v, want %v; values from the environment stay out of it", stored, want)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0o600 {
+		t.Errorf("config file mode = %v, want 0600", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package config
+
+// lockFile is a no-op where flock(2) isn't available; writes are still
+// atomic thanks to the rename in writeFileAtomic.
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
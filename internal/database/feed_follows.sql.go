@@ -14,19 +14,20 @@ import (
 
 const createFeedFollow = `-- name: CreateFeedFollow :one
 WITH inserted_feed_follow AS (
-    INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
+    INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id, category_id)
     VALUES (
         $1,
         $2,
         $3,
         $4,
-        $5
+        $5,
+        $6
     )
-    RETURNING id, created_at, updated_at, user_id, feed_id
+    RETURNING id, created_at, updated_at, user_id, feed_id, category_id
 )
 
 SELECT
-    inserted_feed_follow.id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at, inserted_feed_follow.user_id, inserted_feed_follow.feed_id,
+    inserted_feed_follow.id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at, inserted_feed_follow.user_id, inserted_feed_follow.feed_id, inserted_feed_follow.category_id,
     feeds.name AS feed_name,
     users.name AS user_name
 FROM inserted_feed_follow
@@ -35,21 +36,23 @@ INNER JOIN feeds on feeds.id = inserted_feed_follow.feed_id
 `
 
 type CreateFeedFollowParams struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	UserID    uuid.UUID
-	FeedID    uuid.UUID
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	FeedID     uuid.UUID
+	CategoryID uuid.NullUUID
 }
 
 type CreateFeedFollowRow struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	UserID    uuid.UUID
-	FeedID    uuid.UUID
-	FeedName  string
-	UserName  string
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	FeedID     uuid.UUID
+	CategoryID uuid.NullUUID
+	FeedName   string
+	UserName   string
 }
 
 func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowParams) (CreateFeedFollowRow, error) {
@@ -59,6 +62,7 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 		arg.UpdatedAt,
 		arg.UserID,
 		arg.FeedID,
+		arg.CategoryID,
 	)
 	var i CreateFeedFollowRow
 	err := row.Scan(
@@ -67,6 +71,7 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.FeedID,
+		&i.CategoryID,
 		&i.FeedName,
 		&i.UserName,
 	)
@@ -93,8 +98,9 @@ func (q *Queries) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowPara
 
 const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
 SELECT 
-    feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id, feed_follows.feed_id,
+    feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id, feed_follows.feed_id, feed_follows.category_id,
     feeds.name AS feed_name,
+    feeds.url AS feed_url,
     users.name AS user_name
 FROM feed_follows
 INNER JOIN users on users.id = feed_follows.user_id
@@ -103,13 +109,15 @@ WHERE users.name = $1
 `
 
 type GetFeedFollowsForUserRow struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	UserID    uuid.UUID
-	FeedID    uuid.UUID
-	FeedName  string
-	UserName  string
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	FeedID     uuid.UUID
+	CategoryID uuid.NullUUID
+	FeedName   string
+	FeedUrl    string
+	UserName   string
 }
 
 func (q *Queries) GetFeedFollowsForUser(ctx context.Context, name string) ([]GetFeedFollowsForUserRow, error) {
@@ -127,7 +135,9 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, name string) ([]Get
 			&i.UpdatedAt,
 			&i.UserID,
 			&i.FeedID,
+			&i.CategoryID,
 			&i.FeedName,
+			&i.FeedUrl,
 			&i.UserName,
 		); err != nil {
 			return nil, err
@@ -142,3 +152,43 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, name string) ([]Get
 	}
 	return items, nil
 }
+
+const setFeedFollowCategory = `-- name: SetFeedFollowCategory :exec
+UPDATE feed_follows
+SET category_id = $3, updated_at = $4
+WHERE user_id = $1 AND feed_id = $2
+`
+
+type SetFeedFollowCategoryParams struct {
+	UserID     uuid.UUID
+	FeedID     uuid.UUID
+	CategoryID uuid.NullUUID
+	UpdatedAt  time.Time
+}
+
+func (q *Queries) SetFeedFollowCategory(ctx context.Context, arg SetFeedFollowCategoryParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedFollowCategory,
+		arg.UserID,
+		arg.FeedID,
+		arg.CategoryID,
+		arg.UpdatedAt,
+	)
+	return err
+}
+
+const moveFeedFollowsToCategory = `-- name: MoveFeedFollowsToCategory :exec
+UPDATE feed_follows
+SET category_id = $1, updated_at = $2
+WHERE category_id = $3
+`
+
+type MoveFeedFollowsToCategoryParams struct {
+	NewCategoryID uuid.NullUUID
+	UpdatedAt     time.Time
+	CategoryID    uuid.UUID
+}
+
+func (q *Queries) MoveFeedFollowsToCategory(ctx context.Context, arg MoveFeedFollowsToCategoryParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeedFollowsToCategory, arg.NewCategoryID, arg.UpdatedAt, arg.CategoryID)
+	return err
+}
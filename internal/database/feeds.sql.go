@@ -23,7 +23,7 @@ VALUES (
     $5,
     $6
 )
-RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id
+RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id, next_fetch_at, fetch_interval_seconds, fetch_interval_override_seconds, fulltext, redirect_url, redirect_count, dead_at
 `
 
 type CreateFeedParams struct {
@@ -53,6 +53,13 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.LastFetchedAt,
 		&i.UserID,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		&i.FetchIntervalOverrideSeconds,
+		&i.Fulltext,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.DeadAt,
 	)
 	return i, err
 }
@@ -67,7 +74,7 @@ func (q *Queries) DeleteFeeds(ctx context.Context) error {
 }
 
 const getFeed = `-- name: GetFeed :one
-SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id FROM feeds
+SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id, next_fetch_at, fetch_interval_seconds, fetch_interval_override_seconds, fulltext, redirect_url, redirect_count, dead_at FROM feeds
 WHERE id = $1
 `
 
@@ -82,12 +89,19 @@ func (q *Queries) GetFeed(ctx context.Context, id uuid.UUID) (Feed, error) {
 		&i.Url,
 		&i.LastFetchedAt,
 		&i.UserID,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		&i.FetchIntervalOverrideSeconds,
+		&i.Fulltext,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.DeadAt,
 	)
 	return i, err
 }
 
 const getFeedByURL = `-- name: GetFeedByURL :one
-SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id FROM feeds
+SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id, next_fetch_at, fetch_interval_seconds, fetch_interval_override_seconds, fulltext, redirect_url, redirect_count, dead_at FROM feeds
 WHERE url = $1
 `
 
@@ -102,12 +116,53 @@ func (q *Queries) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.LastFetchedAt,
 		&i.UserID,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		&i.FetchIntervalOverrideSeconds,
+		&i.Fulltext,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.DeadAt,
+	)
+	return i, err
+}
+
+const getFeedFetchStats = `-- name: GetFeedFetchStats :one
+SELECT
+    COUNT(*) AS total,
+    COUNT(*) FILTER (WHERE next_fetch_at IS NULL OR next_fetch_at <= $1::timestamp) AS due,
+    COUNT(*) FILTER (WHERE next_fetch_at < $2::timestamp) AS overdue,
+    COALESCE(EXTRACT(EPOCH FROM ($1::timestamp - MIN(next_fetch_at) FILTER (WHERE next_fetch_at <= $1::timestamp))), 0)::float8 AS queue_lag_seconds
+FROM feeds
+WHERE dead_at IS NULL
+`
+
+type GetFeedFetchStatsParams struct {
+	Now           time.Time
+	OverdueBefore time.Time
+}
+
+type GetFeedFetchStatsRow struct {
+	Total           int64
+	Due             int64
+	Overdue         int64
+	QueueLagSeconds float64
+}
+
+func (q *Queries) GetFeedFetchStats(ctx context.Context, arg GetFeedFetchStatsParams) (GetFeedFetchStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedFetchStats, arg.Now, arg.OverdueBefore)
+	var i GetFeedFetchStatsRow
+	err := row.Scan(
+		&i.Total,
+		&i.Due,
+		&i.Overdue,
+		&i.QueueLagSeconds,
 	)
 	return i, err
 }
 
 const getFeeds = `-- name: GetFeeds :many
-SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id FROM feeds
+SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id, next_fetch_at, fetch_interval_seconds, fetch_interval_override_seconds, fulltext, redirect_url, redirect_count, dead_at FROM feeds
 `
 
 func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
@@ -127,6 +182,13 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 			&i.Url,
 			&i.LastFetchedAt,
 			&i.UserID,
+			&i.NextFetchAt,
+			&i.FetchIntervalSeconds,
+			&i.FetchIntervalOverrideSeconds,
+			&i.Fulltext,
+			&i.RedirectUrl,
+			&i.RedirectCount,
+			&i.DeadAt,
 		); err != nil {
 			return nil, err
 		}
@@ -176,13 +238,15 @@ func (q *Queries) GetFeedsWithUserName(ctx context.Context) ([]GetFeedsWithUserN
 }
 
 const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST
+SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id, next_fetch_at, fetch_interval_seconds, fetch_interval_override_seconds, fulltext, redirect_url, redirect_count, dead_at FROM feeds
+WHERE dead_at IS NULL
+AND (next_fetch_at IS NULL OR next_fetch_at <= $1::timestamp)
+ORDER BY next_fetch_at ASC NULLS FIRST, last_fetched_at ASC NULLS FIRST
 LIMIT 1
 `
 
-func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getNextFeedToFetch)
+func (q *Queries) GetNextFeedToFetch(ctx context.Context, now time.Time) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getNextFeedToFetch, now)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -192,13 +256,20 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
 		&i.Url,
 		&i.LastFetchedAt,
 		&i.UserID,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		&i.FetchIntervalOverrideSeconds,
+		&i.Fulltext,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.DeadAt,
 	)
 	return i, err
 }
 
 const markFeedFetched = `-- name: MarkFeedFetched :exec
 UPDATE feeds
-SET last_fetched_at = $2, updated_at = $3
+SET last_fetched_at = $2, updated_at = $3, next_fetch_at = $4
 WHERE id = $1
 `
 
@@ -206,9 +277,221 @@ type MarkFeedFetchedParams struct {
 	ID            uuid.UUID
 	LastFetchedAt sql.NullTime
 	UpdatedAt     time.Time
+	NextFetchAt   sql.NullTime
 }
 
 func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams) error {
-	_, err := q.db.ExecContext(ctx, markFeedFetched, arg.ID, arg.LastFetchedAt, arg.UpdatedAt)
+	_, err := q.db.ExecContext(ctx, markFeedFetched,
+		arg.ID,
+		arg.LastFetchedAt,
+		arg.UpdatedAt,
+		arg.NextFetchAt,
+	)
+	return err
+}
+
+const scheduleFeed = `-- name: ScheduleFeed :exec
+UPDATE feeds
+SET next_fetch_at = $2, fetch_interval_seconds = $3, updated_at = $4
+WHERE id = $1
+`
+
+type ScheduleFeedParams struct {
+	ID                   uuid.UUID
+	NextFetchAt          sql.NullTime
+	FetchIntervalSeconds int32
+	UpdatedAt            time.Time
+}
+
+func (q *Queries) ScheduleFeed(ctx context.Context, arg ScheduleFeedParams) error {
+	_, err := q.db.ExecContext(ctx, scheduleFeed,
+		arg.ID,
+		arg.NextFetchAt,
+		arg.FetchIntervalSeconds,
+		arg.UpdatedAt,
+	)
+	return err
+}
+
+const setFeedIntervalOverride = `-- name: SetFeedIntervalOverride :exec
+UPDATE feeds
+SET fetch_interval_override_seconds = $2, next_fetch_at = $3, updated_at = $4
+WHERE id = $1
+`
+
+type SetFeedIntervalOverrideParams struct {
+	ID                           uuid.UUID
+	FetchIntervalOverrideSeconds sql.NullInt32
+	NextFetchAt                  sql.NullTime
+	UpdatedAt                    time.Time
+}
+
+func (q *Queries) SetFeedIntervalOverride(ctx context.Context, arg SetFeedIntervalOverrideParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedIntervalOverride,
+		arg.ID,
+		arg.FetchIntervalOverrideSeconds,
+		arg.NextFetchAt,
+		arg.UpdatedAt,
+	)
+	return err
+}
+
+const setFeedFulltext = `-- name: SetFeedFulltext :exec
+UPDATE feeds
+SET fulltext = $2, updated_at = $3
+WHERE id = $1
+`
+
+type SetFeedFulltextParams struct {
+	ID        uuid.UUID
+	Fulltext  bool
+	UpdatedAt time.Time
+}
+
+func (q *Queries) SetFeedFulltext(ctx context.Context, arg SetFeedFulltextParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedFulltext, arg.ID, arg.Fulltext, arg.UpdatedAt)
+	return err
+}
+
+const getFeedByAlias = `-- name: GetFeedByAlias :one
+SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.last_fetched_at, feeds.user_id, feeds.next_fetch_at, feeds.fetch_interval_seconds, feeds.fetch_interval_override_seconds, feeds.fulltext, feeds.redirect_url, feeds.redirect_count, feeds.dead_at FROM feeds
+INNER JOIN feed_aliases ON feed_aliases.feed_id = feeds.id
+WHERE feed_aliases.url = $1
+`
+
+func (q *Queries) GetFeedByAlias(ctx context.Context, url string) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByAlias, url)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.LastFetchedAt,
+		&i.UserID,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		&i.FetchIntervalOverrideSeconds,
+		&i.Fulltext,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.DeadAt,
+	)
+	return i, err
+}
+
+const createFeedAlias = `-- name: CreateFeedAlias :exec
+INSERT INTO feed_aliases (url, created_at, feed_id)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (url) DO UPDATE SET feed_id = EXCLUDED.feed_id
+`
+
+type CreateFeedAliasParams struct {
+	Url       string
+	CreatedAt time.Time
+	FeedID    uuid.UUID
+}
+
+func (q *Queries) CreateFeedAlias(ctx context.Context, arg CreateFeedAliasParams) error {
+	_, err := q.db.ExecContext(ctx, createFeedAlias, arg.Url, arg.CreatedAt, arg.FeedID)
 	return err
 }
+
+const recordFeedRedirect = `-- name: RecordFeedRedirect :exec
+UPDATE feeds
+SET redirect_url = $2, redirect_count = $3, updated_at = $4
+WHERE id = $1
+`
+
+type RecordFeedRedirectParams struct {
+	ID            uuid.UUID
+	RedirectUrl   sql.NullString
+	RedirectCount int32
+	UpdatedAt     time.Time
+}
+
+func (q *Queries) RecordFeedRedirect(ctx context.Context, arg RecordFeedRedirectParams) error {
+	_, err := q.db.ExecContext(ctx, recordFeedRedirect,
+		arg.ID,
+		arg.RedirectUrl,
+		arg.RedirectCount,
+		arg.UpdatedAt,
+	)
+	return err
+}
+
+const moveFeed = `-- name: MoveFeed :exec
+UPDATE feeds
+SET url = $2, redirect_url = NULL, redirect_count = 0, updated_at = $3
+WHERE id = $1
+`
+
+type MoveFeedParams struct {
+	ID        uuid.UUID
+	Url       string
+	UpdatedAt time.Time
+}
+
+func (q *Queries) MoveFeed(ctx context.Context, arg MoveFeedParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeed, arg.ID, arg.Url, arg.UpdatedAt)
+	return err
+}
+
+const markFeedDead = `-- name: MarkFeedDead :exec
+UPDATE feeds
+SET dead_at = $2, updated_at = $2
+WHERE id = $1
+`
+
+type MarkFeedDeadParams struct {
+	ID     uuid.UUID
+	DeadAt sql.NullTime
+}
+
+func (q *Queries) MarkFeedDead(ctx context.Context, arg MarkFeedDeadParams) error {
+	_, err := q.db.ExecContext(ctx, markFeedDead, arg.ID, arg.DeadAt)
+	return err
+}
+
+const reviveFeed = `-- name: ReviveFeed :exec
+UPDATE feeds
+SET dead_at = NULL, next_fetch_at = $2, updated_at = $2
+WHERE id = $1
+`
+
+type ReviveFeedParams struct {
+	ID          uuid.UUID
+	NextFetchAt sql.NullTime
+}
+
+func (q *Queries) ReviveFeed(ctx context.Context, arg ReviveFeedParams) error {
+	_, err := q.db.ExecContext(ctx, reviveFeed, arg.ID, arg.NextFetchAt)
+	return err
+}
+
+const deferFeedsOnHost = `-- name: DeferFeedsOnHost :execrows
+UPDATE feeds
+SET next_fetch_at = $1::timestamp, updated_at = $2::timestamp
+WHERE dead_at IS NULL
+AND (next_fetch_at IS NULL OR next_fetch_at < $1::timestamp)
+AND lower(substring(url from '^[^:]+://(?:[^@/]*@)?([^/:?#]+)')) = $3::text
+`
+
+type DeferFeedsOnHostParams struct {
+	Until time.Time
+	Now   time.Time
+	Host  string
+}
+
+func (q *Queries) DeferFeedsOnHost(ctx context.Context, arg DeferFeedsOnHostParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deferFeedsOnHost, arg.Until, arg.Now, arg.Host)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -11,33 +11,165 @@ import (
 	"github.com/google/uuid"
 )
 
-type Feed struct {
+type Alert struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	Patterns   []string
+	FeedID     uuid.NullUUID
+	TargetKind string
+	Target     string
+	Secret     sql.NullString
+}
+
+type AlertDelivery struct {
 	ID            uuid.UUID
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	LastFetchedAt sql.NullTime
-	UserID        uuid.UUID
+	AlertID       uuid.UUID
+	PostID        uuid.UUID
+	Match         string
+	Status        string
+	Attempts      int32
+	LastError     sql.NullString
+	NextAttemptAt time.Time
+	DeliveredAt   sql.NullTime
 }
 
-type FeedFollow struct {
+type ApiPassword struct {
+	UserID       uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	PasswordHash string
+}
+
+type Category struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	UserID    uuid.UUID
+	ParentID  uuid.NullUUID
+	Name      string
+}
+
+type DigestSchedule struct {
+	UserID     uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Email      string
+	SendHour   int32
+	LastSentAt sql.NullTime
+}
+
+type Feed struct {
+	ID                           uuid.UUID
+	CreatedAt                    time.Time
+	UpdatedAt                    time.Time
+	Name                         string
+	Url                          string
+	LastFetchedAt                sql.NullTime
+	UserID                       uuid.UUID
+	NextFetchAt                  sql.NullTime
+	FetchIntervalSeconds         int32
+	FetchIntervalOverrideSeconds sql.NullInt32
+	Fulltext                     bool
+	RedirectUrl                  sql.NullString
+	RedirectCount                int32
+	DeadAt                       sql.NullTime
+}
+
+type FeedAlias struct {
+	Url       string
+	CreatedAt time.Time
 	FeedID    uuid.UUID
 }
 
+type FeedFollow struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	FeedID     uuid.UUID
+	CategoryID uuid.NullUUID
+}
+
+type FeedToken struct {
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	Token     string
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	FeedID    uuid.NullUUID
+	Message   string
+	ReadAt    sql.NullTime
+}
+
+type PodcastEpisode struct {
+	PostID          uuid.UUID
+	CreatedAt       time.Time
+	DurationSeconds sql.NullInt32
+	Episode         sql.NullInt32
+	Season          sql.NullInt32
+	Explicit        sql.NullBool
+	ImageUrl        sql.NullString
+}
+
 type Post struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt time.Time
-	FeedID      uuid.UUID
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Title            string
+	Url              string
+	Description      sql.NullString
+	PublishedAt      time.Time
+	FeedID           uuid.UUID
+	Content          sql.NullString
+	Author           sql.NullString
+	Categories       []string
+	CommentsUrl      sql.NullString
+	ExtractedContent sql.NullString
+	ItemNumber       int64
+}
+
+type PostEnclosure struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	PostID    uuid.UUID
+	Url       string
+	MimeType  string
+	Length    int64
+}
+
+type PostState struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	UpdatedAt time.Time
+	ReadAt    sql.NullTime
+	StarredAt sql.NullTime
+	HiddenAt  sql.NullTime
+}
+
+type PostTag struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	Tag       string
+	CreatedAt time.Time
+}
+
+type Rule struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	Conditions []string
+	Actions    []string
 }
 
 type User struct {
@@ -46,3 +178,18 @@ type User struct {
 	UpdatedAt time.Time
 	Name      string
 }
+
+type WebsubSubscription struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	FeedID         uuid.UUID
+	HubUrl         string
+	TopicUrl       string
+	Secret         string
+	Status         string
+	LeaseExpiresAt sql.NullTime
+	LastAttemptAt  sql.NullTime
+	LastError      sql.NullString
+	PendingMode    sql.NullString
+}
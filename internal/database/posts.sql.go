@@ -11,10 +11,11 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createPost = `-- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, content, author, categories, comments_url)
 VALUES (
     $1,
     $2,
@@ -23,9 +24,14 @@ VALUES (
     $5,
     $6,
     $7,
-    $8
+    $8,
+    $9,
+    $10,
+    $11,
+    $12
 )
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+ON CONFLICT (url) DO NOTHING
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, content, author, categories, comments_url, extracted_content, item_number
 `
 
 type CreatePostParams struct {
@@ -37,8 +43,15 @@ type CreatePostParams struct {
 	Description sql.NullString
 	PublishedAt time.Time
 	FeedID      uuid.UUID
+	Content     sql.NullString
+	Author      sql.NullString
+	Categories  []string
+	CommentsUrl sql.NullString
 }
 
+// Posts are unique by URL, and every scrape sees the posts already stored
+// again. Those are skipped, so the query returns no row (sql.ErrNoRows)
+// for a duplicate instead of failing with a unique violation.
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
 	row := q.db.QueryRowContext(ctx, createPost,
 		arg.ID,
@@ -49,6 +62,10 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.Description,
 		arg.PublishedAt,
 		arg.FeedID,
+		arg.Content,
+		arg.Author,
+		pq.Array(arg.Categories),
+		arg.CommentsUrl,
 	)
 	var i Post
 	err := row.Scan(
@@ -60,43 +77,188 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Content,
+		&i.Author,
+		pq.Array(&i.Categories),
+		&i.CommentsUrl,
+		&i.ExtractedContent,
+		&i.ItemNumber,
 	)
 	return i, err
 }
 
+const getPost = `-- name: GetPost :one
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, content, author, categories, comments_url, extracted_content, item_number FROM posts
+WHERE id = $1
+`
+
+func (q *Queries) GetPost(ctx context.Context, id uuid.UUID) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPost, id)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Content,
+		&i.Author,
+		pq.Array(&i.Categories),
+		&i.CommentsUrl,
+		&i.ExtractedContent,
+		&i.ItemNumber,
+	)
+	return i, err
+}
+
+const getPostsForDigest = `-- name: GetPostsForDigest :many
+SELECT
+p.id,
+p.title,
+p.url,
+p.description,
+p.author,
+p.published_at,
+f.name AS feed_name,
+ff.category_id
+FROM posts p
+INNER JOIN feeds f ON f.id = p.feed_id
+INNER JOIN feed_follows ff ON ff.feed_id = f.id
+LEFT JOIN post_states ps ON ps.user_id = ff.user_id AND ps.post_id = p.id
+WHERE ff.user_id = $1
+AND p.created_at > $2::timestamp
+AND p.created_at <= $3::timestamp
+AND ps.hidden_at IS NULL
+AND ps.read_at IS NULL
+ORDER BY f.name, p.published_at DESC
+LIMIT $4
+`
+
+type GetPostsForDigestParams struct {
+	UserID uuid.UUID
+	Since  time.Time
+	Until  time.Time
+	Limit  int32
+}
+
+type GetPostsForDigestRow struct {
+	ID          uuid.UUID
+	Title       string
+	Url         string
+	Description sql.NullString
+	Author      sql.NullString
+	PublishedAt time.Time
+	FeedName    string
+	CategoryID  uuid.NullUUID
+}
+
+func (q *Queries) GetPostsForDigest(ctx context.Context, arg GetPostsForDigestParams) ([]GetPostsForDigestRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsForDigest,
+		arg.UserID,
+		arg.Since,
+		arg.Until,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPostsForDigestRow
+	for rows.Next() {
+		var i GetPostsForDigestRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.Author,
+			&i.PublishedAt,
+			&i.FeedName,
+			&i.CategoryID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getPostsForUser = `-- name: GetPostsForUser :many
 SELECT 
-p.id, p.created_at, p.updated_at, p.title, p.url, p.description, p.published_at, p.feed_id,
+p.id, p.created_at, p.updated_at, p.title, p.url, p.description, p.published_at, p.feed_id, p.content, p.author, p.categories, p.comments_url, p.extracted_content, p.item_number,
 f.name as feed_name,
-u.id as user_id
+f.url as feed_url,
+ff.user_id,
+ps.read_at,
+ps.starred_at
 FROM posts p 
 inner join feeds f on f.id = p.feed_id
-inner join users u on u.id = f.user_id
-WHERE u.id = $1
+inner join feed_follows ff on ff.feed_id = f.id
+left join post_states ps on ps.user_id = ff.user_id and ps.post_id = p.id
+WHERE ff.user_id = $1
+AND ps.hidden_at IS NULL
+AND (NOT $2::boolean OR ff.category_id = ANY($3::uuid[]))
+AND ($4::text IS NULL OR EXISTS (
+    SELECT 1 FROM post_tags pt
+    WHERE pt.user_id = ff.user_id AND pt.post_id = p.id AND pt.tag = $4
+))
+AND (NOT $5::boolean OR ps.starred_at IS NOT NULL)
+AND (NOT $6::boolean OR ps.read_at IS NULL)
 ORDER BY published_at DESC
-LIMIT $2
+LIMIT $7
 `
 
 type GetPostsForUserParams struct {
-	ID    uuid.UUID
-	Limit int32
+	ID               uuid.UUID
+	FilterCategories bool
+	CategoryIds      []uuid.UUID
+	Tag              sql.NullString
+	Starred          bool
+	Unread           bool
+	Limit            int32
 }
 
 type GetPostsForUserRow struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt time.Time
-	FeedID      uuid.UUID
-	FeedName    string
-	UserID      uuid.UUID
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Title            string
+	Url              string
+	Description      sql.NullString
+	PublishedAt      time.Time
+	FeedID           uuid.UUID
+	Content          sql.NullString
+	Author           sql.NullString
+	Categories       []string
+	CommentsUrl      sql.NullString
+	ExtractedContent sql.NullString
+	ItemNumber       int64
+	FeedName         string
+	FeedUrl          string
+	UserID           uuid.UUID
+	ReadAt           sql.NullTime
+	StarredAt        sql.NullTime
 }
 
 func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.ID, arg.Limit)
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.ID,
+		arg.FilterCategories,
+		pq.Array(arg.CategoryIds),
+		arg.Tag,
+		arg.Starred,
+		arg.Unread,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -113,8 +275,17 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Content,
+			&i.Author,
+			pq.Array(&i.Categories),
+			&i.CommentsUrl,
+			&i.ExtractedContent,
+			&i.ItemNumber,
 			&i.FeedName,
+			&i.FeedUrl,
 			&i.UserID,
+			&i.ReadAt,
+			&i.StarredAt,
 		); err != nil {
 			return nil, err
 		}
@@ -128,3 +299,258 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	}
 	return items, nil
 }
+
+const getReaderItemRefs = `-- name: GetReaderItemRefs :many
+SELECT
+p.item_number,
+p.published_at,
+f.url AS feed_url
+FROM posts p
+INNER JOIN feeds f ON f.id = p.feed_id
+INNER JOIN feed_follows ff ON ff.feed_id = f.id
+LEFT JOIN post_states ps ON ps.user_id = ff.user_id AND ps.post_id = p.id
+WHERE ff.user_id = $1
+AND ps.hidden_at IS NULL
+AND ($2::uuid IS NULL OR p.feed_id = $2)
+AND (NOT $3::boolean OR ff.category_id = ANY($4::uuid[]))
+AND ($5::text IS NULL OR EXISTS (
+    SELECT 1 FROM post_tags pt
+    WHERE pt.user_id = ff.user_id AND pt.post_id = p.id AND pt.tag = $5
+))
+AND (NOT $6::boolean OR ps.starred_at IS NOT NULL)
+AND (NOT $7::boolean OR ps.read_at IS NOT NULL)
+AND (NOT $8::boolean OR ps.read_at IS NULL)
+AND ($9::timestamp IS NULL OR p.published_at >= $9)
+AND ($10::timestamp IS NULL OR p.published_at <= $10)
+ORDER BY
+    CASE WHEN $11::boolean THEN p.published_at END ASC,
+    CASE WHEN $11::boolean THEN p.item_number END ASC,
+    p.published_at DESC,
+    p.item_number DESC
+LIMIT $12 OFFSET $13
+`
+
+type GetReaderItemRefsParams struct {
+	UserID           uuid.UUID
+	FeedID           uuid.NullUUID
+	FilterCategories bool
+	CategoryIds      []uuid.UUID
+	Tag              sql.NullString
+	Starred          bool
+	Read             bool
+	Unread           bool
+	PublishedAfter   sql.NullTime
+	PublishedBefore  sql.NullTime
+	OldestFirst      bool
+	Limit            int32
+	Offset           int32
+}
+
+type GetReaderItemRefsRow struct {
+	ItemNumber  int64
+	PublishedAt time.Time
+	FeedUrl     string
+}
+
+func (q *Queries) GetReaderItemRefs(ctx context.Context, arg GetReaderItemRefsParams) ([]GetReaderItemRefsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getReaderItemRefs,
+		arg.UserID,
+		arg.FeedID,
+		arg.FilterCategories,
+		pq.Array(arg.CategoryIds),
+		arg.Tag,
+		arg.Starred,
+		arg.Read,
+		arg.Unread,
+		arg.PublishedAfter,
+		arg.PublishedBefore,
+		arg.OldestFirst,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetReaderItemRefsRow
+	for rows.Next() {
+		var i GetReaderItemRefsRow
+		if err := rows.Scan(
+			&i.ItemNumber,
+			&i.PublishedAt,
+			&i.FeedUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getReaderItems = `-- name: GetReaderItems :many
+SELECT
+p.id, p.created_at, p.updated_at, p.title, p.url, p.description, p.published_at, p.feed_id, p.content, p.author, p.categories, p.comments_url, p.extracted_content, p.item_number,
+f.name AS feed_name,
+f.url AS feed_url,
+ff.category_id,
+ps.read_at,
+ps.starred_at,
+ARRAY(
+    SELECT pt.tag FROM post_tags pt
+    WHERE pt.user_id = ff.user_id AND pt.post_id = p.id
+    ORDER BY pt.tag
+)::text[] AS tags
+FROM posts p
+INNER JOIN feeds f ON f.id = p.feed_id
+INNER JOIN feed_follows ff ON ff.feed_id = f.id
+LEFT JOIN post_states ps ON ps.user_id = ff.user_id AND ps.post_id = p.id
+WHERE ff.user_id = $1
+AND p.item_number = ANY($2::bigint[])
+ORDER BY p.published_at DESC, p.item_number DESC
+`
+
+type GetReaderItemsParams struct {
+	UserID      uuid.UUID
+	ItemNumbers []int64
+}
+
+type GetReaderItemsRow struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Title            string
+	Url              string
+	Description      sql.NullString
+	PublishedAt      time.Time
+	FeedID           uuid.UUID
+	Content          sql.NullString
+	Author           sql.NullString
+	Categories       []string
+	CommentsUrl      sql.NullString
+	ExtractedContent sql.NullString
+	ItemNumber       int64
+	FeedName         string
+	FeedUrl          string
+	CategoryID       uuid.NullUUID
+	ReadAt           sql.NullTime
+	StarredAt        sql.NullTime
+	Tags             []string
+}
+
+func (q *Queries) GetReaderItems(ctx context.Context, arg GetReaderItemsParams) ([]GetReaderItemsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getReaderItems, arg.UserID, pq.Array(arg.ItemNumbers))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetReaderItemsRow
+	for rows.Next() {
+		var i GetReaderItemsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Content,
+			&i.Author,
+			pq.Array(&i.Categories),
+			&i.CommentsUrl,
+			&i.ExtractedContent,
+			&i.ItemNumber,
+			&i.FeedName,
+			&i.FeedUrl,
+			&i.CategoryID,
+			&i.ReadAt,
+			&i.StarredAt,
+			pq.Array(&i.Tags),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUnreadCounts = `-- name: GetUnreadCounts :many
+SELECT
+f.url AS feed_url,
+ff.category_id,
+COUNT(*) AS count,
+MAX(p.published_at)::timestamp AS newest_published_at
+FROM posts p
+INNER JOIN feeds f ON f.id = p.feed_id
+INNER JOIN feed_follows ff ON ff.feed_id = f.id
+LEFT JOIN post_states ps ON ps.user_id = ff.user_id AND ps.post_id = p.id
+WHERE ff.user_id = $1
+AND ps.hidden_at IS NULL
+AND ps.read_at IS NULL
+GROUP BY f.url, ff.category_id
+`
+
+type GetUnreadCountsRow struct {
+	FeedUrl           string
+	CategoryID        uuid.NullUUID
+	Count             int64
+	NewestPublishedAt time.Time
+}
+
+func (q *Queries) GetUnreadCounts(ctx context.Context, userID uuid.UUID) ([]GetUnreadCountsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUnreadCounts, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUnreadCountsRow
+	for rows.Next() {
+		var i GetUnreadCountsRow
+		if err := rows.Scan(
+			&i.FeedUrl,
+			&i.CategoryID,
+			&i.Count,
+			&i.NewestPublishedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setPostExtractedContent = `-- name: SetPostExtractedContent :exec
+UPDATE posts
+SET extracted_content = $2, updated_at = $3
+WHERE id = $1
+`
+
+type SetPostExtractedContentParams struct {
+	ID               uuid.UUID
+	ExtractedContent sql.NullString
+	UpdatedAt        time.Time
+}
+
+func (q *Queries) SetPostExtractedContent(ctx context.Context, arg SetPostExtractedContentParams) error {
+	_, err := q.db.ExecContext(ctx, setPostExtractedContent, arg.ID, arg.ExtractedContent, arg.UpdatedAt)
+	return err
+}
@@ -0,0 +1,453 @@
+// Package feedgen writes feeds: RSS 2.0, Atom 1.0 and JSON Feed 1.1
+// documents built from the same description, so one list of posts can be
+// republished in whichever format a consumer prefers.
+package feedgen
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jjboykin/gator/internal/plaintext"
+)
+
+// Formats.
+const (
+	FormatRSS  = "rss"
+	FormatAtom = "atom"
+	FormatJSON = "json"
+)
+
+// Formats lists the formats in the order they are offered.
+var Formats = []string{FormatRSS, FormatAtom, FormatJSON}
+
+// ContentType returns the media type of a format, or "" for an unknown one.
+func ContentType(format string) string {
+	switch format {
+	case FormatRSS:
+		return "application/rss+xml; charset=utf-8"
+	case FormatAtom:
+		return "application/atom+xml; charset=utf-8"
+	case FormatJSON:
+		return "application/feed+json; charset=utf-8"
+	default:
+		return ""
+	}
+}
+
+const generator = "gator"
+
+// Feed describes the document to write.
+type Feed struct {
+	Title       string
+	Description string
+	// Link is the web page the feed belongs to, if any.
+	Link string
+	// SelfURL is where the document itself can be fetched. Atom requires
+	// an ID, so the self URL doubles as it; ID is used when there is none.
+	SelfURL string
+	ID      string
+	Author  string
+	// Updated defaults to the latest item's update time.
+	Updated time.Time
+	Items   []Item
+}
+
+// Item is one entry of a feed.
+type Item struct {
+	// ID must stay the same for as long as the item exists; readers use it
+	// to tell new items from ones they've already seen.
+	ID    string
+	Title string
+	Link  string
+	// Summary and Content are HTML.
+	Summary    string
+	Content    string
+	Author     string
+	Published  time.Time
+	Updated    time.Time
+	Categories []string
+	// Source is the feed the item was originally published in.
+	Source     *Source
+	Enclosures []Enclosure
+}
+
+// Source identifies the feed an item came from.
+type Source struct {
+	Title string
+	URL   string
+}
+
+// Enclosure is a media file attached to an item.
+type Enclosure struct {
+	URL    string
+	Type   string
+	Length int64
+}
+
+func (it Item) updated() time.Time {
+	if it.Updated.After(it.Published) {
+		return it.Updated
+	}
+	return it.Published
+}
+
+// LastModified returns the time the feed last changed: Updated if set,
+// the latest item's update time otherwise, or now for an empty feed.
+func (f *Feed) LastModified() time.Time {
+	if !f.Updated.IsZero() {
+		return f.Updated
+	}
+	var latest time.Time
+	for _, it := range f.Items {
+		if u := it.updated(); u.After(latest) {
+			latest = u
+		}
+	}
+	if latest.IsZero() {
+		return time.Now()
+	}
+	return latest
+}
+
+// Write encodes f in the given format.
+func Write(w io.Writer, format string, f *Feed) error {
+	switch format {
+	case FormatRSS:
+		return WriteRSS(w, f)
+	case FormatAtom:
+		return WriteAtom(w, f)
+	case FormatJSON:
+		return WriteJSON(w, f)
+	default:
+		return fmt.Errorf("unknown feed format %q; use %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+type rssDocument struct {
+	XMLName      xml.Name   `xml:"rss"`
+	Version      string     `xml:"version,attr"`
+	AtomNS       string     `xml:"xmlns:atom,attr"`
+	ContentNS    string     `xml:"xmlns:content,attr"`
+	DublinCoreNS string     `xml:"xmlns:dc,attr"`
+	Channel      rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	SelfLink      *atomLink `xml:"atom:link,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Generator     string    `xml:"generator"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string         `xml:"title"`
+	Link        string         `xml:"link,omitempty"`
+	Description string         `xml:"description,omitempty"`
+	Content     string         `xml:"content:encoded,omitempty"`
+	Creator     string         `xml:"dc:creator,omitempty"`
+	Categories  []string       `xml:"category"`
+	GUID        rssGUID        `xml:"guid"`
+	PubDate     string         `xml:"pubDate,omitempty"`
+	Source      *rssSource     `xml:"source,omitempty"`
+	Enclosures  []rssEnclosure `xml:"enclosure"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssSource struct {
+	URL   string `xml:"url,attr"`
+	Title string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length int64  `xml:"length,attr"`
+}
+
+// WriteRSS encodes f as RSS 2.0. Item IDs become guids that aren't
+// permalinks, and authors go in dc:creator since RSS wants an email
+// address in author.
+func WriteRSS(w io.Writer, f *Feed) error {
+	doc := rssDocument{
+		Version:      "2.0",
+		AtomNS:       "http://www.w3.org/2005/Atom",
+		ContentNS:    "http://purl.org/rss/1.0/modules/content/",
+		DublinCoreNS: "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          firstNonEmpty(f.Link, f.SelfURL),
+			Description:   firstNonEmpty(f.Description, f.Title),
+			LastBuildDate: f.LastModified().UTC().Format(time.RFC1123Z),
+			Generator:     generator,
+		},
+	}
+	if f.SelfURL != "" {
+		doc.Channel.SelfLink = &atomLink{Href: f.SelfURL, Rel: "self", Type: "application/rss+xml"}
+	}
+	for _, it := range f.Items {
+		item := rssItem{
+			Title:       it.Title,
+			Link:        it.Link,
+			Description: firstNonEmpty(it.Summary, it.Content),
+			Creator:     it.Author,
+			Categories:  it.Categories,
+			GUID:        rssGUID{Value: it.ID},
+		}
+		if it.Content != "" && it.Content != item.Description {
+			item.Content = it.Content
+		}
+		// RSS has a single date, so an item that was never given a
+		// publication date goes out with its update time.
+		if date := firstNonZero(it.Published, it.Updated); !date.IsZero() {
+			item.PubDate = date.UTC().Format(time.RFC1123Z)
+		}
+		if it.Source != nil && it.Source.URL != "" {
+			item.Source = &rssSource{URL: it.Source.URL, Title: it.Source.Title}
+		}
+		for _, e := range it.Enclosures {
+			item.Enclosures = append(item.Enclosures, rssEnclosure{URL: e.URL, Type: e.Type, Length: e.Length})
+		}
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+	return writeXML(w, doc)
+}
+
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Subtitle  string      `xml:"subtitle,omitempty"`
+	Updated   string      `xml:"updated"`
+	Links     []atomLink  `xml:"link"`
+	Author    *atomPerson `xml:"author,omitempty"`
+	Generator string      `xml:"generator"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Title  string `xml:"title,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Links      []atomLink     `xml:"link"`
+	Author     *atomPerson    `xml:"author,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+	Source     *atomSource    `xml:"source,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomSource struct {
+	ID    string     `xml:"id"`
+	Title string     `xml:"title"`
+	Links []atomLink `xml:"link"`
+}
+
+// WriteAtom encodes f as Atom 1.0. Entries without an author fall back to
+// the feed's, which Atom requires one of.
+func WriteAtom(w io.Writer, f *Feed) error {
+	doc := atomFeed{
+		ID:        firstNonEmpty(f.SelfURL, f.ID),
+		Title:     f.Title,
+		Subtitle:  f.Description,
+		Updated:   f.LastModified().UTC().Format(time.RFC3339),
+		Author:    &atomPerson{Name: firstNonEmpty(f.Author, generator)},
+		Generator: generator,
+	}
+	if f.SelfURL != "" {
+		doc.Links = append(doc.Links, atomLink{Href: f.SelfURL, Rel: "self", Type: "application/atom+xml"})
+	}
+	if f.Link != "" {
+		doc.Links = append(doc.Links, atomLink{Href: f.Link, Rel: "alternate", Type: "text/html"})
+	}
+	for _, it := range f.Items {
+		entry := atomEntry{
+			ID:      it.ID,
+			Title:   it.Title,
+			Updated: it.updated().UTC().Format(time.RFC3339),
+		}
+		if !it.Published.IsZero() {
+			entry.Published = it.Published.UTC().Format(time.RFC3339)
+		}
+		if it.Link != "" {
+			entry.Links = append(entry.Links, atomLink{Href: it.Link, Rel: "alternate", Type: "text/html"})
+		}
+		for _, e := range it.Enclosures {
+			entry.Links = append(entry.Links, atomLink{Href: e.URL, Rel: "enclosure", Type: e.Type, Length: e.Length})
+		}
+		if it.Author != "" {
+			entry.Author = &atomPerson{Name: it.Author}
+		}
+		for _, c := range it.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: c})
+		}
+		if it.Summary != "" {
+			entry.Summary = &atomText{Type: "html", Value: it.Summary}
+		}
+		if it.Content != "" {
+			entry.Content = &atomText{Type: "html", Value: it.Content}
+		}
+		if it.Source != nil && it.Source.URL != "" {
+			entry.Source = &atomSource{
+				ID:    it.Source.URL,
+				Title: it.Source.Title,
+				Links: []atomLink{{Href: it.Source.URL, Rel: "self"}},
+			}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, doc any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type jsonFeed struct {
+	Version     string       `json:"version"`
+	Title       string       `json:"title"`
+	HomePageURL string       `json:"home_page_url,omitempty"`
+	FeedURL     string       `json:"feed_url,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Authors     []jsonAuthor `json:"authors,omitempty"`
+	Items       []jsonItem   `json:"items"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url,omitempty"`
+	Title         string           `json:"title,omitempty"`
+	ContentHTML   string           `json:"content_html"`
+	Summary       string           `json:"summary,omitempty"`
+	DatePublished string           `json:"date_published,omitempty"`
+	DateModified  string           `json:"date_modified,omitempty"`
+	Authors       []jsonAuthor     `json:"authors,omitempty"`
+	Tags          []string         `json:"tags,omitempty"`
+	Attachments   []jsonAttachment `json:"attachments,omitempty"`
+	Source        *jsonSource      `json:"_source,omitempty"`
+}
+
+type jsonAttachment struct {
+	URL         string `json:"url"`
+	MimeType    string `json:"mime_type"`
+	SizeInBytes int64  `json:"size_in_bytes,omitempty"`
+}
+
+// jsonSource is an extension; JSON Feed reserves keys starting with an
+// underscore for them.
+type jsonSource struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// WriteJSON encodes f as JSON Feed 1.1. Every item carries content_html,
+// which the spec requires unless content_text is given; the summary is
+// plain text there.
+func WriteJSON(w io.Writer, f *Feed) error {
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.Link,
+		FeedURL:     f.SelfURL,
+		Description: f.Description,
+		Items:       []jsonItem{},
+	}
+	if f.Author != "" {
+		doc.Authors = []jsonAuthor{{Name: f.Author}}
+	}
+	for _, it := range f.Items {
+		item := jsonItem{
+			ID:          it.ID,
+			URL:         it.Link,
+			Title:       it.Title,
+			ContentHTML: firstNonEmpty(it.Content, it.Summary),
+			Tags:        it.Categories,
+		}
+		if it.Content != "" && it.Summary != "" && it.Summary != it.Content {
+			item.Summary = plaintext.Render(it.Summary, 0)
+		}
+		if !it.Published.IsZero() {
+			item.DatePublished = it.Published.UTC().Format(time.RFC3339)
+		}
+		if u := it.updated(); !u.IsZero() && !u.Equal(it.Published) {
+			item.DateModified = u.UTC().Format(time.RFC3339)
+		}
+		if it.Author != "" {
+			item.Authors = []jsonAuthor{{Name: it.Author}}
+		}
+		for _, e := range it.Enclosures {
+			item.Attachments = append(item.Attachments, jsonAttachment{URL: e.URL, MimeType: e.Type, SizeInBytes: e.Length})
+		}
+		if it.Source != nil && it.Source.URL != "" {
+			item.Source = &jsonSource{Title: it.Source.Title, URL: it.Source.URL}
+		}
+		doc.Items = append(doc.Items, item)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(doc)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func firstNonZero(times ...time.Time) time.Time {
+	for _, t := range times {
+		if !t.IsZero() {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,167 @@
+package feedgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testFeed has a dated item with everything set, an item that was only
+// ever updated and one with markup and entities in every field.
+func testFeed() *Feed {
+	published := time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	return &Feed{
+		Title:       "alice's posts",
+		Description: "What alice reads",
+		Link:        "https://gator.example.com/",
+		SelfURL:     "https://gator.example.com/u/abc123/feed.rss",
+		Author:      "alice",
+		Items: []Item{
+			{
+				ID:         "urn:uuid:6f1c1b1e-8d3a-4c1e-9a57-2b1f0d7c9e01",
+				Title:      "Go 1.22 is released",
+				Link:       "https://go.dev/blog/go1.22",
+				Summary:    "<p>Loop variables, at last.</p>",
+				Content:    "<p>Loop variables, at last.</p><p>And more.</p>",
+				Author:     "The Go Team",
+				Published:  published,
+				Updated:    published.Add(2 * time.Hour),
+				Categories: []string{"go", "releases"},
+				Source:     &Source{Title: "The Go Blog", URL: "https://go.dev/blog/feed.atom"},
+				Enclosures: []Enclosure{{URL: "https://go.dev/talk.mp3", Type: "audio/mpeg", Length: 1234}},
+			},
+			{
+				ID:      "urn:uuid:0d9e2a44-3f5b-4b8e-8c11-7a6e5d4c3b02",
+				Title:   "Undated",
+				Link:    "https://example.com/undated",
+				Summary: "<p>No date in the feed.</p>",
+				Updated: time.Date(2024, 4, 30, 8, 30, 0, 0, time.UTC),
+			},
+			{
+				ID:        "https://example.com/?a=1&b=2",
+				Title:     `Tom & Jerry <3 "quotes" ]]>`,
+				Link:      "https://example.com/?a=1&b=2",
+				Summary:   `<p>5 &lt; 6 &amp; <a href="/x?y=1&amp;z=2">x</a></p>`,
+				Author:    "O'Brien & Sons",
+				Published: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+}
+
+// Each format is compared with testdata/feed.<format>.
+func TestWriteGolden(t *testing.T) {
+	for _, format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, format, testFeed()); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			want, err := os.ReadFile(filepath.Join("testdata", "feed."+format))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != string(want) {
+				t.Errorf("Write(%s) =\n%s\nwant\n%s", format, got, want)
+			}
+
+			// The same posts make the same document, so readers don't see
+			// items again.
+			var again bytes.Buffer
+			Write(&again, format, testFeed())
+			if again.String() != buf.String() {
+				t.Errorf("Write(%s) isn't stable", format)
+			}
+		})
+	}
+}
+
+// The escaped title and summary read back as they were given.
+func TestWriteEscaping(t *testing.T) {
+	feed := testFeed()
+	want := feed.Items[2]
+
+	var rss bytes.Buffer
+	if err := WriteRSS(&rss, feed); err != nil {
+		t.Fatal(err)
+	}
+	var rssDoc rssDocument
+	if err := xml.Unmarshal(rss.Bytes(), &rssDoc); err != nil {
+		t.Fatalf("RSS doesn't parse: %v", err)
+	}
+	if got := rssDoc.Channel.Items[2]; got.Title != want.Title || got.Description != want.Summary || got.Link != want.Link {
+		t.Errorf("RSS item read back as %q, %q, %q", got.Title, got.Description, got.Link)
+	}
+
+	var atom bytes.Buffer
+	if err := WriteAtom(&atom, feed); err != nil {
+		t.Fatal(err)
+	}
+	var atomDoc atomFeed
+	if err := xml.Unmarshal(atom.Bytes(), &atomDoc); err != nil {
+		t.Fatalf("Atom doesn't parse: %v", err)
+	}
+	if got := atomDoc.Entries[2]; got.Title != want.Title || got.Summary.Value != want.Summary || got.ID != want.ID {
+		t.Errorf("Atom entry read back as %q, %q, %q", got.Title, got.Summary.Value, got.ID)
+	}
+
+	var js bytes.Buffer
+	if err := WriteJSON(&js, feed); err != nil {
+		t.Fatal(err)
+	}
+	var jsonDoc jsonFeed
+	if err := json.Unmarshal(js.Bytes(), &jsonDoc); err != nil {
+		t.Fatalf("JSON Feed doesn't parse: %v", err)
+	}
+	if got := jsonDoc.Items[2]; got.Title != want.Title || got.ContentHTML != want.Summary || got.ID != want.ID {
+		t.Errorf("JSON Feed item read back as %q, %q, %q", got.Title, got.ContentHTML, got.ID)
+	}
+}
+
+// Without a self URL, Atom uses the feed's ID and no format links to
+// itself.
+func TestWriteWithoutSelfURL(t *testing.T) {
+	feed := testFeed()
+	feed.SelfURL = ""
+	feed.ID = "tag:gator.example.com,2024:alice"
+
+	for _, format := range Formats {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, feed); err != nil {
+			t.Fatalf("Write(%s): %v", format, err)
+		}
+		for _, self := range []string{`rel="self" type=`, `"feed_url"`} {
+			if strings.Contains(buf.String(), self) {
+				t.Errorf("Write(%s) without a self URL has %s", format, self)
+			}
+		}
+		if format == FormatAtom && !strings.Contains(buf.String(), "<id>tag:gator.example.com,2024:alice</id>") {
+			t.Errorf("Atom without a self URL doesn't use the feed ID:\n%s", buf.String())
+		}
+	}
+}
+
+func TestLastModified(t *testing.T) {
+	feed := testFeed()
+	if got, want := feed.LastModified(), time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("LastModified = %s, want the latest item update %s", got, want)
+	}
+	feed.Updated = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	if got := feed.LastModified(); !got.Equal(feed.Updated) {
+		t.Errorf("LastModified = %s, want Updated %s", got, feed.Updated)
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	if err := Write(&bytes.Buffer{}, "opml", testFeed()); err == nil {
+		t.Error("Write accepted opml")
+	}
+	if got := ContentType("opml"); got != "" {
+		t.Errorf("ContentType(opml) = %q", got)
+	}
+}
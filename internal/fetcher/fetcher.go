@@ -0,0 +1,465 @@
+// Package fetcher is the HTTP client gator uses for everything it
+// downloads: feeds, article pages, media and WebSub hub requests. It adds
+// the limits a long-running aggregator needs when talking to arbitrary
+// servers: timeouts, a response size cap, a redirect limit, a descriptive
+// user agent, and protection against being pointed at internal addresses.
+package fetcher
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/jjboykin/gator/internal/ratelimit"
+)
+
+var (
+	// ErrTooLarge is returned when a response body exceeds MaxBodyBytes.
+	ErrTooLarge = errors.New("response body too large")
+	// ErrTooManyRedirects is returned when a request is redirected more
+	// than MaxRedirects times.
+	ErrTooManyRedirects = errors.New("too many redirects")
+	// ErrBlockedAddress is returned when a host resolves to a private,
+	// loopback or otherwise internal address and AllowPrivate is unset.
+	ErrBlockedAddress = errors.New("refusing to connect to a private address")
+)
+
+// DefaultUserAgent identifies gator to the servers it polls.
+const DefaultUserAgent = "gator/1.0 (+https://github.com/jjboykin/gator)"
+
+// Options configures a Client. Zero values select the defaults.
+type Options struct {
+	// UserAgent replaces DefaultUserAgent.
+	UserAgent string
+	// Contact, a URL or email address, is appended to the user agent so
+	// that server operators can reach whoever runs this instance.
+	Contact string
+
+	// ConnectTimeout bounds the TCP connect and TLS handshake; ReadTimeout
+	// bounds waiting for the response headers and, for Get, reading the
+	// body.
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration
+
+	// MaxBodyBytes caps the decoded size of a body read by Get.
+	MaxBodyBytes int64
+	MaxRedirects int
+
+	// CAFile is a PEM bundle trusted in addition to the system roots.
+	CAFile string
+	// AllowPrivate permits connections to loopback, private and link-local
+	// addresses, which are refused by default.
+	AllowPrivate bool
+
+	// Limiter, if set, paces requests per host and defers hosts that
+	// answer 429 or 503 with a Retry-After header.
+	Limiter *ratelimit.Limiter
+}
+
+const (
+	defaultConnectTimeout = 10 * time.Second
+	defaultReadTimeout    = 30 * time.Second
+	defaultMaxBodyBytes   = 20 << 20
+	defaultMaxRedirects   = 5
+
+	// maxRetryAfter caps how long a host can make us back off for.
+	maxRetryAfter = 24 * time.Hour
+)
+
+// Client is safe for concurrent use.
+type Client struct {
+	opts   Options
+	http   *http.Client
+	proxy  func(*http.Request) (*url.URL, error)
+	header string
+}
+
+// Redirect is one hop of a redirect chain.
+type Redirect struct {
+	From       string
+	To         string
+	StatusCode int
+}
+
+// Permanent reports whether the hop was a 301 or 308.
+func (r Redirect) Permanent() bool {
+	return r.StatusCode == http.StatusMovedPermanently || r.StatusCode == http.StatusPermanentRedirect
+}
+
+// Response is a fully read response.
+type Response struct {
+	// URL is the final URL, after redirects.
+	URL        string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Redirects  []Redirect
+}
+
+// New builds a Client. Proxies are taken from HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY.
+func New(opts Options) (*Client, error) {
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultUserAgent
+	}
+	if opts.ConnectTimeout <= 0 {
+		opts.ConnectTimeout = defaultConnectTimeout
+	}
+	if opts.ReadTimeout <= 0 {
+		opts.ReadTimeout = defaultReadTimeout
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if opts.MaxRedirects <= 0 {
+		opts.MaxRedirects = defaultMaxRedirects
+	}
+
+	c := &Client{
+		opts:   opts,
+		proxy:  http.ProxyFromEnvironment,
+		header: opts.UserAgent,
+	}
+	if opts.Contact != "" {
+		c.header = strings.TrimSuffix(opts.UserAgent, ")") + "; " + opts.Contact
+		if strings.HasSuffix(opts.UserAgent, ")") {
+			c.header += ")"
+		}
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if opts.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := &http.Transport{
+		Proxy:                 c.proxy,
+		DialContext:           c.dial,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   opts.ConnectTimeout,
+		ResponseHeaderTimeout: opts.ReadTimeout,
+		ExpectContinueTimeout: time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   4,
+		ForceAttemptHTTP2:     true,
+		// Get negotiates and decodes compression itself, so that the size
+		// limit applies to the decoded body.
+		DisableCompression: true,
+	}
+	var next http.RoundTripper = transport
+	if opts.Limiter != nil {
+		next = limitTransport{limiter: opts.Limiter, next: transport}
+	}
+	c.http = &http.Client{
+		Transport:     userAgentTransport{agent: c.header, next: next},
+		CheckRedirect: c.checkRedirect,
+	}
+	return c, nil
+}
+
+// UserAgent returns the User-Agent header sent with every request.
+func (c *Client) UserAgent() string {
+	return c.header
+}
+
+// HTTPClient returns the underlying client for streaming requests, such as
+// media downloads. It applies the same address checks, timeouts, redirect
+// limit and user agent, but not the body size limit.
+func (c *Client) HTTPClient() *http.Client {
+	return c.http
+}
+
+type redirectsKey struct{}
+
+// Get fetches rawURL and reads the whole body, decoding gzip, deflate and
+// brotli.
+// header may add or override request headers. Any status code is returned
+// as a Response; only transport failures and the limits are errors.
+func (c *Client) Get(ctx context.Context, rawURL string, header http.Header) (*Response, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var redirects []Redirect
+	ctx = context.WithValue(ctx, redirectsKey{}, &redirects)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	if err := c.checkProxiedTarget(ctx, req); err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return &Response{Redirects: redirects}, err
+	}
+	defer resp.Body.Close()
+
+	// The headers are in; from here ReadTimeout bounds reading the body.
+	timer := time.AfterFunc(c.opts.ReadTimeout, cancel)
+	defer timer.Stop()
+
+	out := &Response{
+		URL:        resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Redirects:  redirects,
+	}
+
+	body, err := decode(resp)
+	if err != nil {
+		return out, err
+	}
+	out.Body, err = io.ReadAll(io.LimitReader(body, c.opts.MaxBodyBytes+1))
+	if err != nil {
+		return out, err
+	}
+	if int64(len(out.Body)) > c.opts.MaxBodyBytes {
+		out.Body = nil
+		return out, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, c.opts.MaxBodyBytes)
+	}
+	return out, nil
+}
+
+// decode unwraps the Content-Encoding of resp.
+func decode(resp *http.Response) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return resp.Body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(resp.Body)
+	case "deflate":
+		// "deflate" is meant to be zlib-wrapped, but some servers send
+		// raw DEFLATE data.
+		buffered := bufio.NewReader(resp.Body)
+		if header, err := buffered.Peek(2); err == nil && isZlibHeader(header) {
+			return zlib.NewReader(buffered)
+		}
+		return flate.NewReader(buffered), nil
+	case "br":
+		return brotli.NewReader(resp.Body), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", resp.Header.Get("Content-Encoding"))
+	}
+}
+
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > c.opts.MaxRedirects {
+		return fmt.Errorf("%w: stopped after %d", ErrTooManyRedirects, c.opts.MaxRedirects)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return fmt.Errorf("refusing redirect to %s URL", req.URL.Scheme)
+	}
+	if redirects, ok := req.Context().Value(redirectsKey{}).(*[]Redirect); ok && req.Response != nil {
+		*redirects = append(*redirects, Redirect{
+			From:       via[len(via)-1].URL.String(),
+			To:         req.URL.String(),
+			StatusCode: req.Response.StatusCode,
+		})
+	}
+	return c.checkProxiedTarget(req.Context(), req)
+}
+
+// checkProxiedTarget applies the address check to requests that go
+// through a proxy, where the dialer only ever sees the proxy's address.
+func (c *Client) checkProxiedTarget(ctx context.Context, req *http.Request) error {
+	if c.opts.AllowPrivate {
+		return nil
+	}
+	proxyURL, err := c.proxy(req)
+	if err != nil || proxyURL == nil {
+		return err
+	}
+	_, err = c.resolve(ctx, req.URL.Hostname())
+	return err
+}
+
+// dial connects to addr, refusing internal addresses. The host is resolved
+// here and the connection made to the checked IP, so a DNS answer can't
+// change between the check and the connect.
+func (c *Client) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: c.opts.ConnectTimeout, KeepAlive: 30 * time.Second}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if c.opts.AllowPrivate || c.isProxy(addr) {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	ips, err := c.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, ip := range ips {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// isProxy reports whether addr is one of the configured proxies, which
+// are trusted even on a private network.
+func (c *Client) isProxy(addr string) bool {
+	for _, scheme := range []string{"http", "https"} {
+		req := &http.Request{URL: &url.URL{Scheme: scheme, Host: "gator.invalid"}}
+		proxyURL, err := c.proxy(req)
+		if err != nil || proxyURL == nil {
+			continue
+		}
+		port := proxyURL.Port()
+		if port == "" {
+			port = map[string]string{"http": "80", "https": "443", "socks5": "1080"}[proxyURL.Scheme]
+		}
+		if net.JoinHostPort(proxyURL.Hostname(), port) == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve looks up host and fails if any of its addresses is internal.
+func (c *Client) resolve(ctx context.Context, host string) ([]net.IP, error) {
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			ips = append(ips, a.IP)
+		}
+	}
+	for _, ip := range ips {
+		if IsPrivate(ip) {
+			return nil, fmt.Errorf("%w: %s resolves to %s", ErrBlockedAddress, host, ip)
+		}
+	}
+	return ips, nil
+}
+
+// reservedNetworks are ranges the net.IP methods don't cover: "this
+// network" (0.0.0.0/8), carrier-grade NAT (100.64.0.0/10), the benchmarking
+// range (198.18.0.0/15) and NAT64 (64:ff9b::/96), which embeds IPv4
+// addresses, internal ones included.
+var reservedNetworks = []*net.IPNet{
+	{IP: net.IPv4(0, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)},
+	{IP: net.IPv4(100, 64, 0, 0).To4(), Mask: net.CIDRMask(10, 32)},
+	{IP: net.IPv4(198, 18, 0, 0).To4(), Mask: net.CIDRMask(15, 32)},
+	{IP: net.ParseIP("64:ff9b::"), Mask: net.CIDRMask(96, 128)},
+}
+
+// IsPrivate reports whether ip is an address a feed URL should never
+// point at: loopback, private, link-local, multicast, unspecified or one of
+// the reserved networks.
+func IsPrivate(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// userAgentTransport sets the User-Agent of requests that don't carry one.
+type userAgentTransport struct {
+	agent string
+	next  http.RoundTripper
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.agent)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// limitTransport waits for the limiter before each request, redirects
+// included, and holds the host's concurrency slot until the response body
+// is closed.
+type limitTransport struct {
+	limiter *ratelimit.Limiter
+	next    http.RoundTripper
+}
+
+func (t limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	release, err := t.limiter.Acquire(req.Context(), host)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	if ratelimit.IsBackoffStatus(resp.StatusCode) {
+		if wait, ok := ratelimit.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok && wait > 0 {
+			t.limiter.Defer(host, time.Now().Add(min(wait, maxRetryAfter)))
+		}
+	}
+	resp.Body = releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b releasingBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
+
+// isZlibHeader checks the CMF and FLG bytes of a zlib stream.
+func isZlibHeader(b []byte) bool {
+	return b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
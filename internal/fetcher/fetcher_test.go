@@ -0,0 +1,72 @@
+package fetcher
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestIsPrivate(t *testing.T) {
+	for _, tt := range []struct {
+		ip   string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"192.168.0.1", true},
+		{"169.254.169.254", true},
+		{"0.0.0.0", true},
+		{"0.1.2.3", true},
+		{"100.64.0.1", true},
+		{"198.18.0.1", true},
+		{"198.19.255.255", true},
+		{"::1", true},
+		{"fd00::1", true},
+		{"::ffff:10.0.0.1", true},
+		{"64:ff9b::a00:1", true},
+		{"93.184.216.34", false},
+		{"198.20.0.1", false},
+		{"100.128.0.1", false},
+		{"2606:2800:220:1::1", false},
+		{"64:ff9b:1::a00:1", false},
+	} {
+		if got := IsPrivate(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("IsPrivate(%q) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeBrotli(t *testing.T) {
+	body, err := os.Open("testdata/feed.xml.br")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	want, err := os.ReadFile("testdata/feed.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := &http.Response{Header: http.Header{"Content-Encoding": {"br"}}, Body: body}
+	r, err := decode(resp)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("got %q..., want %q...", got[:min(len(got), 40)], want[:40])
+	}
+}
+
+func TestDecodeUnsupported(t *testing.T) {
+	resp := &http.Response{Header: http.Header{"Content-Encoding": {"zstd"}}, Body: io.NopCloser(strings.NewReader(""))}
+	if _, err := decode(resp); err == nil {
+		t.Error("decode accepted zstd")
+	}
+}
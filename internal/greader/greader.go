@@ -0,0 +1,285 @@
+// Package greader holds the protocol details of the Google Reader API as
+// spoken by FreshRSS, Miniflux and the clients built for them: stream and
+// item identifiers, tokens and the JSON documents.
+package greader
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// States are the stream IDs of the built-in item states. Clients send them
+// with "-" or with their user ID in the second segment.
+const (
+	StateReadingList = "user/-/state/com.google/reading-list"
+	StateRead        = "user/-/state/com.google/read"
+	StateStarred     = "user/-/state/com.google/starred"
+	StateKeptUnread  = "user/-/state/com.google/kept-unread"
+
+	labelPrefix = "user/-/label/"
+	feedPrefix  = "feed/"
+)
+
+// Kinds of streams.
+const (
+	KindReadingList = "reading-list"
+	KindRead        = "read"
+	KindStarred     = "starred"
+	KindKeptUnread  = "kept-unread"
+	KindLabel       = "label"
+	KindFeed        = "feed"
+)
+
+// Stream is a parsed stream ID. Value is the label name or feed URL.
+type Stream struct {
+	Kind  string
+	Value string
+}
+
+// ID returns the canonical stream ID.
+func (s Stream) ID() string {
+	switch s.Kind {
+	case KindLabel:
+		return LabelID(s.Value)
+	case KindFeed:
+		return FeedID(s.Value)
+	default:
+		return "user/-/state/com.google/" + s.Kind
+	}
+}
+
+// LabelID returns the stream ID of a label.
+func LabelID(name string) string {
+	return labelPrefix + name
+}
+
+// FeedID returns the stream ID of a feed.
+func FeedID(url string) string {
+	return feedPrefix + url
+}
+
+// ParseStream parses a stream ID.
+func ParseStream(id string) (Stream, error) {
+	if url, ok := strings.CutPrefix(id, feedPrefix); ok {
+		if url == "" {
+			return Stream{}, errors.New("empty feed stream")
+		}
+		return Stream{Kind: KindFeed, Value: url}, nil
+	}
+	parts := strings.SplitN(id, "/", 4)
+	if len(parts) == 4 && parts[0] == "user" {
+		switch {
+		case parts[2] == "label" && parts[3] != "":
+			return Stream{Kind: KindLabel, Value: parts[3]}, nil
+		case parts[2] == "state":
+			switch strings.TrimPrefix(parts[3], "com.google/") {
+			case KindReadingList:
+				return Stream{Kind: KindReadingList}, nil
+			case KindRead:
+				return Stream{Kind: KindRead}, nil
+			case KindStarred:
+				return Stream{Kind: KindStarred}, nil
+			case KindKeptUnread:
+				return Stream{Kind: KindKeptUnread}, nil
+			}
+		}
+	}
+	return Stream{}, fmt.Errorf("unknown stream %q", id)
+}
+
+const itemIDPrefix = "tag:google.com,2005:reader/item/"
+
+// ItemID returns the long form of an item ID, which is how items are
+// identified in stream contents.
+func ItemID(n int64) string {
+	return fmt.Sprintf("%s%016x", itemIDPrefix, uint64(n))
+}
+
+// ParseItemID accepts both the long form of an item ID and the short,
+// decimal one that stream/items/ids returns.
+func ParseItemID(id string) (int64, error) {
+	if hexID, ok := strings.CutPrefix(id, itemIDPrefix); ok {
+		n, err := strconv.ParseUint(hexID, 16, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid item id %q", id)
+		}
+		return int64(n), nil
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid item id %q", id)
+	}
+	return n, nil
+}
+
+// Sign returns a token for subject that only the holder of key can make.
+// Tokens are keyed with the user's password hash, so changing the password
+// revokes them.
+func Sign(key, purpose, subject string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(purpose))
+	mac.Write([]byte{0})
+	mac.Write([]byte(subject))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether token was made by Sign with the same arguments.
+func Verify(token, key, purpose, subject string) bool {
+	return hmac.Equal([]byte(token), []byte(Sign(key, purpose, subject)))
+}
+
+// AuthToken returns the token of the Authorization header from a request,
+// which clients send as "GoogleLogin auth=<token>".
+func AuthToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(header, "GoogleLogin auth=")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// Usec formats t in microseconds since the epoch, as the timestampUsec
+// fields want.
+func Usec(t time.Time) string {
+	return strconv.FormatInt(t.UnixMicro(), 10)
+}
+
+// ParseTime reads a time given in seconds, or in microseconds as some
+// clients send, since the epoch.
+func ParseTime(s string) (time.Time, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+	}
+	if n > 1e14 {
+		return time.UnixMicro(n), nil
+	}
+	return time.Unix(n, 0), nil
+}
+
+// UserInfo answers user-info.
+type UserInfo struct {
+	UserID        string `json:"userId"`
+	UserName      string `json:"userName"`
+	UserProfileID string `json:"userProfileId"`
+	UserEmail     string `json:"userEmail"`
+}
+
+// Tag is a state or label in tag/list.
+type Tag struct {
+	ID   string `json:"id"`
+	Type string `json:"type,omitempty"`
+}
+
+// TagList answers tag/list.
+type TagList struct {
+	Tags []Tag `json:"tags"`
+}
+
+// Category is a label a subscription is filed under.
+type Category struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+// Subscription is a followed feed.
+type Subscription struct {
+	ID         string     `json:"id"`
+	Title      string     `json:"title"`
+	Categories []Category `json:"categories"`
+	URL        string     `json:"url"`
+	HTMLURL    string     `json:"htmlUrl"`
+	IconURL    string     `json:"iconUrl"`
+}
+
+// SubscriptionList answers subscription/list.
+type SubscriptionList struct {
+	Subscriptions []Subscription `json:"subscriptions"`
+}
+
+// QuickAddResult answers subscription/quickadd.
+type QuickAddResult struct {
+	NumResults int    `json:"numResults"`
+	Query      string `json:"query"`
+	StreamID   string `json:"streamId,omitempty"`
+	StreamName string `json:"streamName,omitempty"`
+}
+
+// UnreadCount is the number of unread items in a stream.
+type UnreadCount struct {
+	ID                      string `json:"id"`
+	Count                   int64  `json:"count"`
+	NewestItemTimestampUsec string `json:"newestItemTimestampUsec"`
+}
+
+// UnreadCounts answers unread-count.
+type UnreadCounts struct {
+	Max          int64         `json:"max"`
+	UnreadCounts []UnreadCount `json:"unreadcounts"`
+}
+
+// ItemRef is an item in stream/items/ids. ID is the short form.
+type ItemRef struct {
+	ID              string   `json:"id"`
+	DirectStreamIDs []string `json:"directStreamIds"`
+	TimestampUsec   string   `json:"timestampUsec"`
+}
+
+// ItemRefs answers stream/items/ids.
+type ItemRefs struct {
+	ItemRefs     []ItemRef `json:"itemRefs"`
+	Continuation string    `json:"continuation,omitempty"`
+}
+
+// Link is a URL in an item.
+type Link struct {
+	Href   string `json:"href"`
+	Type   string `json:"type,omitempty"`
+	Length int64  `json:"length,omitempty"`
+}
+
+// Content is an item's HTML.
+type Content struct {
+	Direction string `json:"direction"`
+	Content   string `json:"content"`
+}
+
+// Origin is the feed an item comes from.
+type Origin struct {
+	StreamID string `json:"streamId"`
+	Title    string `json:"title"`
+	HTMLURL  string `json:"htmlUrl"`
+}
+
+// Item is an entry in stream contents.
+type Item struct {
+	ID            string   `json:"id"`
+	CrawlTimeMsec string   `json:"crawlTimeMsec"`
+	TimestampUsec string   `json:"timestampUsec"`
+	Published     int64    `json:"published"`
+	Updated       int64    `json:"updated"`
+	Title         string   `json:"title"`
+	Author        string   `json:"author,omitempty"`
+	Canonical     []Link   `json:"canonical"`
+	Alternate     []Link   `json:"alternate"`
+	Enclosure     []Link   `json:"enclosure,omitempty"`
+	Categories    []string `json:"categories"`
+	Origin        Origin   `json:"origin"`
+	Summary       Content  `json:"summary"`
+}
+
+// StreamContents answers stream/contents and stream/items/contents.
+type StreamContents struct {
+	ID           string `json:"id"`
+	Updated      int64  `json:"updated"`
+	Items        []Item `json:"items"`
+	Continuation string `json:"continuation,omitempty"`
+}
@@ -0,0 +1,116 @@
+package greader
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseStream(t *testing.T) {
+	for _, tt := range []struct {
+		id   string
+		want Stream
+		ok   bool
+	}{
+		{"user/-/state/com.google/reading-list", Stream{Kind: KindReadingList}, true},
+		{"user/1005/state/com.google/read", Stream{Kind: KindRead}, true},
+		{"user/-/state/com.google/starred", Stream{Kind: KindStarred}, true},
+		{"user/-/state/com.google/kept-unread", Stream{Kind: KindKeptUnread}, true},
+		{"user/-/label/Tech/Go", Stream{Kind: KindLabel, Value: "Tech/Go"}, true},
+		{"feed/https://example.com/feed.xml", Stream{Kind: KindFeed, Value: "https://example.com/feed.xml"}, true},
+		{"feed/", Stream{}, false},
+		{"user/-/label/", Stream{}, false},
+		{"user/-/state/com.google/broadcast", Stream{}, false},
+		{"pop/topic/top", Stream{}, false},
+		{"", Stream{}, false},
+	} {
+		got, err := ParseStream(tt.id)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("ParseStream(%q) = %+v, %v, want %+v, ok %v", tt.id, got, err, tt.want, tt.ok)
+		}
+		if tt.ok && tt.want.Kind != KindReadingList && tt.want.Kind != KindRead {
+			if id := got.ID(); id != tt.id {
+				t.Errorf("ParseStream(%q).ID() = %q", tt.id, id)
+			}
+		}
+	}
+}
+
+func TestItemID(t *testing.T) {
+	if got, want := ItemID(0x1f), "tag:google.com,2005:reader/item/000000000000001f"; got != want {
+		t.Errorf("ItemID(0x1f) = %q, want %q", got, want)
+	}
+	for _, tt := range []struct {
+		id   string
+		want int64
+		ok   bool
+	}{
+		{"tag:google.com,2005:reader/item/000000000000001f", 31, true},
+		{"31", 31, true},
+		{"tag:google.com,2005:reader/item/xyz", 0, false},
+		{"0x1f", 0, false},
+		{"", 0, false},
+	} {
+		got, err := ParseItemID(tt.id)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("ParseItemID(%q) = %d, %v, want %d, ok %v", tt.id, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	token := Sign("hash", "auth", "user-1")
+	if !Verify(token, "hash", "auth", "user-1") {
+		t.Fatal("Verify rejected a token made by Sign")
+	}
+	for _, tt := range []struct {
+		name                         string
+		token, key, purpose, subject string
+	}{
+		{"other key", token, "new hash", "auth", "user-1"},
+		{"other purpose", token, "hash", "edit", "user-1"},
+		{"other subject", token, "hash", "auth", "user-2"},
+		{"forged", "00" + token[2:], "hash", "auth", "user-1"},
+		{"empty", "", "hash", "auth", "user-1"},
+	} {
+		if Verify(tt.token, tt.key, tt.purpose, tt.subject) {
+			t.Errorf("Verify accepted a token for the %s", tt.name)
+		}
+	}
+}
+
+func TestAuthToken(t *testing.T) {
+	for _, tt := range []struct {
+		header string
+		want   string
+	}{
+		{"GoogleLogin auth=abc/def", "abc/def"},
+		{"GoogleLogin auth= abc ", "abc"},
+		{"Bearer abc", ""},
+		{"", ""},
+	} {
+		r := httptest.NewRequest("GET", "/reader/api/0/user-info", nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		if got := AuthToken(r); got != tt.want {
+			t.Errorf("AuthToken with %q = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	want := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, s := range []string{"1714564800", "1714564800000000"} {
+		got, err := ParseTime(s)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("ParseTime(%q) = %s, %v, want %s", s, got, err, want)
+		}
+	}
+	if _, err := ParseTime("yesterday"); err == nil {
+		t.Error("ParseTime accepted yesterday")
+	}
+	if got := Usec(want); got != "1714564800000000" {
+		t.Errorf("Usec = %q", got)
+	}
+}
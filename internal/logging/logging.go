@@ -0,0 +1,77 @@
+// Package logging builds the slog.Logger used by gator from its config.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+type Options struct {
+	// Level is one of debug, info, warn or error.
+	Level string
+	// Format is either text or json.
+	Format string
+	// File is the log file path. Logs go to stderr when it is empty.
+	File string
+	// MaxSizeMB is the size at which File is rotated; 0 disables rotation.
+	MaxSizeMB int
+	// MaxBackups is the number of rotated files to keep.
+	MaxBackups int
+}
+
+// New returns a logger configured by opts. The returned io.Closer closes
+// the log file, if any, and must be called on shutdown.
+func New(opts Options) (*slog.Logger, io.Closer, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var out io.Writer = os.Stderr
+	var closer io.Closer = nopCloser{}
+	if opts.File != "" {
+		f, err := newRotatingFile(opts.File, int64(opts.MaxSizeMB)*1024*1024, opts.MaxBackups)
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening log file: %w", err)
+		}
+		out = f
+		closer = f
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", "text":
+		handler = slog.NewTextHandler(out, handlerOpts)
+	case "json":
+		handler = slog.NewJSONHandler(out, handlerOpts)
+	default:
+		closer.Close()
+		return nil, nil, fmt.Errorf("unknown log format: %s", opts.Format)
+	}
+
+	return slog.New(handler), closer, nil
+}
+
+// ParseLevel converts a level name to a slog.Level. An empty name is info.
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown log level: %s", name)
+	}
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
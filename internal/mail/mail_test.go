@@ -0,0 +1,247 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// session is what the stand-in server received.
+type session struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// serveSMTP answers one SMTP session on l, replying authReply to AUTH,
+// and sends what it received on the returned channel.
+func serveSMTP(t *testing.T, l net.Listener, authReply string) <-chan session {
+	t.Helper()
+	done := make(chan session, 1)
+	go func() {
+		var s session
+		defer func() { done <- s }()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 localhost ESMTP stand-in")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			verb, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(verb) {
+			case "EHLO":
+				tp.PrintfLine("250-localhost")
+				tp.PrintfLine("250 AUTH PLAIN")
+			case "AUTH":
+				mechanism, response, _ := strings.Cut(arg, " ")
+				decoded, _ := base64.StdEncoding.DecodeString(response)
+				if mechanism == "PLAIN" {
+					s.auth = string(decoded)
+				}
+				tp.PrintfLine("%s", authReply)
+			case "MAIL":
+				s.from = arg
+				tp.PrintfLine("250 OK")
+			case "RCPT":
+				s.to = append(s.to, arg)
+				tp.PrintfLine("250 OK")
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				data, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				s.data = string(data)
+				tp.PrintfLine("250 queued")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				return
+			default:
+				tp.PrintfLine("502 not implemented")
+			}
+		}
+	}()
+	return done
+}
+
+func listen(t *testing.T) (net.Listener, int) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l, l.Addr().(*net.TCPAddr).Port
+}
+
+func TestSend(t *testing.T) {
+	l, port := listen(t)
+	received := serveSMTP(t, l, "235 2.7.0 Authentication successful")
+
+	long := strings.Repeat("Résumé of the day's posts, ", 6)
+	m := &Message{
+		From:    "gator <gator@example.com>",
+		To:      []string{"Ana <ana@example.com>", "bo@example.com"},
+		Subject: "Digest: 3 new posts — café",
+		Text:    "Hello,\n" + long + "\nx = 1",
+		HTML:    "<p>Hello,</p>\n<p>" + long + "</p>",
+		Date:    time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC),
+	}
+	err := Send(context.Background(), Config{
+		Host:     "127.0.0.1",
+		Port:     port,
+		Username: "gator",
+		Password: "hunter2",
+		TLS:      TLSNone,
+		Timeout:  10 * time.Second,
+	}, m)
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	s := <-received
+
+	if s.auth != "\x00gator\x00hunter2" {
+		t.Errorf("AUTH PLAIN = %q, want %q", s.auth, "\x00gator\x00hunter2")
+	}
+	if s.from != "FROM:<gator@example.com>" {
+		t.Errorf("MAIL %s, want FROM:<gator@example.com>", s.from)
+	}
+	if want := []string{"TO:<ana@example.com>", "TO:<bo@example.com>"}; strings.Join(s.to, " ") != strings.Join(want, " ") {
+		t.Errorf("RCPT %v, want %v", s.to, want)
+	}
+
+	msg, err := netmail.ReadMessage(strings.NewReader(s.data))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != m.Subject {
+		t.Errorf("Subject = %q, %v, want %q", subject, err, m.Subject)
+	}
+	if got := msg.Header.Get("Date"); got != "Wed, 01 May 2024 07:00:00 +0000" {
+		t.Errorf("Date = %q", got)
+	}
+	if got := msg.Header.Get("Message-ID"); !strings.HasSuffix(got, "@example.com>") {
+		t.Errorf("Message-ID = %q, want one at example.com", got)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v, want multipart/alternative", msg.Header.Get("Content-Type"), err)
+	}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for _, want := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		part, err := parts.NextRawPart()
+		if err != nil {
+			t.Fatalf("NextRawPart: %v", err)
+		}
+		if got := part.Header.Get("Content-Type"); got != want.contentType {
+			t.Errorf("part Content-Type = %q, want %q", got, want.contentType)
+		}
+		if got := part.Header.Get("Content-Transfer-Encoding"); got != "quoted-printable" {
+			t.Errorf("part Content-Transfer-Encoding = %q, want quoted-printable", got)
+		}
+		raw, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkQuotedPrintable(t, string(raw))
+
+		decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(string(raw))))
+		if err != nil {
+			t.Fatalf("decoding %s: %v", want.contentType, err)
+		}
+		if got := string(decoded); got != want.body {
+			t.Errorf("%s body = %q, want %q", want.contentType, got, want.body)
+		}
+	}
+	if _, err := parts.NextRawPart(); err != io.EOF {
+		t.Errorf("after the HTML part: %v, want io.EOF", err)
+	}
+}
+
+// checkQuotedPrintable checks that body is 7-bit with lines of at most 76
+// characters, as quoted-printable requires.
+func checkQuotedPrintable(t *testing.T, body string) {
+	t.Helper()
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(line) > 76 {
+			t.Errorf("line of %d characters: %q", len(line), line)
+		}
+		for _, c := range []byte(line) {
+			if c >= 0x80 {
+				t.Errorf("8-bit byte in %q", line)
+				break
+			}
+		}
+	}
+	if !strings.Contains(body, "=C3=A9") {
+		t.Errorf("é isn't encoded in %q", body)
+	}
+}
+
+func TestSendAuthRejected(t *testing.T) {
+	l, port := listen(t)
+	received := serveSMTP(t, l, "535 5.7.8 Bad credentials")
+
+	err := Send(context.Background(), Config{
+		Host:     "127.0.0.1",
+		Port:     port,
+		Username: "gator",
+		Password: "wrong",
+		TLS:      TLSNone,
+	}, &Message{From: "gator@example.com", To: []string{"ana@example.com"}, Subject: "x", Text: "x"})
+	if err == nil || !strings.Contains(err.Error(), "authentication failed") {
+		t.Errorf("Send = %v, want an authentication error", err)
+	}
+	l.Close()
+	if s := <-received; s.data != "" {
+		t.Errorf("message sent despite failed authentication: %q", s.data)
+	}
+}
+
+func TestBytesPlainText(t *testing.T) {
+	data, err := (&Message{
+		From:    "gator@example.com",
+		To:      []string{"ana@example.com"},
+		Subject: "plain",
+		Text:    "one\ntwo=2",
+	}).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := netmail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := msg.Header.Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	body, _ := io.ReadAll(msg.Body)
+	if got, want := string(body), "one\r\ntwo=3D2"; got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+}
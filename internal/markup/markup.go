@@ -0,0 +1,302 @@
+// Package markup is a small, forgiving HTML parser. It does not implement
+// the full HTML5 tree construction algorithm; it builds a reasonable tree
+// from the kind of markup found in feed items and article pages, which is
+// all the sanitizer, the text renderer and the readability extractor need.
+package markup
+
+import (
+	"html"
+	"io"
+	"strings"
+)
+
+// NodeType is the kind of a Node.
+type NodeType int
+
+const (
+	DocumentNode NodeType = iota
+	ElementNode
+	TextNode
+	CommentNode
+)
+
+// Attr is an element attribute. Key is lower-cased; Val is unescaped.
+type Attr struct {
+	Key string
+	Val string
+}
+
+// Node is an element, text or comment in a parsed document. For elements
+// Data is the lower-cased tag name; for text and comments it is the
+// unescaped content.
+type Node struct {
+	Type     NodeType
+	Data     string
+	Attr     []Attr
+	Parent   *Node
+	Children []*Node
+}
+
+// voidElements never have content or an end tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"param": true, "source": true, "track": true, "wbr": true,
+}
+
+// rawTextElements hold text that is not parsed as markup.
+var rawTextElements = map[string]bool{
+	"script": true, "style": true, "textarea": true, "title": true,
+	"xmp": true, "noscript": true, "iframe": true, "noembed": true,
+}
+
+// closesParagraph lists the elements whose start tag implicitly ends an
+// open <p>.
+var closesParagraph = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"div": true, "dl": true, "fieldset": true, "figure": true, "footer": true,
+	"form": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true,
+	"h6": true, "header": true, "hr": true, "main": true, "nav": true,
+	"ol": true, "p": true, "pre": true, "section": true, "table": true,
+	"ul": true,
+}
+
+// implicitClose maps an element to the open elements its start tag
+// closes, and the elements that bound the search.
+var implicitClose = map[string]struct{ closes, scope []string }{
+	"li":     {[]string{"li"}, []string{"ul", "ol"}},
+	"dt":     {[]string{"dt", "dd"}, []string{"dl"}},
+	"dd":     {[]string{"dt", "dd"}, []string{"dl"}},
+	"tr":     {[]string{"tr", "td", "th"}, []string{"table", "thead", "tbody", "tfoot"}},
+	"td":     {[]string{"td", "th"}, []string{"tr", "table"}},
+	"th":     {[]string{"td", "th"}, []string{"tr", "table"}},
+	"option": {[]string{"option"}, []string{"select", "datalist"}},
+}
+
+// IsVoid reports whether name is a void element such as <br> or <img>.
+func IsVoid(name string) bool {
+	return voidElements[name]
+}
+
+// Parse builds a tree from an HTML document or fragment. It never fails:
+// malformed markup is repaired or dropped.
+func Parse(s string) *Node {
+	doc := &Node{Type: DocumentNode}
+	p := parser{stack: []*Node{doc}}
+	tokenize(s, p.token)
+	return doc
+}
+
+type parser struct {
+	stack []*Node
+}
+
+func (p *parser) current() *Node {
+	return p.stack[len(p.stack)-1]
+}
+
+func (p *parser) token(t token) {
+	switch t.kind {
+	case textToken:
+		cur := p.current()
+		if n := len(cur.Children); n > 0 && cur.Children[n-1].Type == TextNode {
+			cur.Children[n-1].Data += t.data
+			return
+		}
+		cur.AppendChild(&Node{Type: TextNode, Data: t.data})
+	case commentToken:
+		p.current().AppendChild(&Node{Type: CommentNode, Data: t.data})
+	case startTagToken:
+		if closesParagraph[t.data] {
+			p.closeWithin([]string{"p"}, []string{"div", "td", "th", "li", "blockquote", "section", "article"})
+		}
+		if rule, ok := implicitClose[t.data]; ok {
+			p.closeWithin(rule.closes, rule.scope)
+		}
+		n := &Node{Type: ElementNode, Data: t.data, Attr: t.attr}
+		p.current().AppendChild(n)
+		if !t.selfClosing && !voidElements[t.data] {
+			p.stack = append(p.stack, n)
+		}
+	case endTagToken:
+		for i := len(p.stack) - 1; i > 0; i-- {
+			if p.stack[i].Data == t.data {
+				p.stack = p.stack[:i]
+				return
+			}
+		}
+	}
+}
+
+// closeWithin pops the innermost open element named in closes, unless an
+// element named in scope is reached first.
+func (p *parser) closeWithin(closes, scope []string) {
+	for i := len(p.stack) - 1; i > 0; i-- {
+		name := p.stack[i].Data
+		if contains(closes, name) {
+			p.stack = p.stack[:i]
+			return
+		}
+		if contains(scope, name) {
+			return
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// AppendChild adds c as the last child of n.
+func (n *Node) AppendChild(c *Node) {
+	c.Parent = n
+	n.Children = append(n.Children, c)
+}
+
+// Remove detaches n from its parent.
+func (n *Node) Remove() {
+	if n.Parent == nil {
+		return
+	}
+	siblings := n.Parent.Children
+	for i, c := range siblings {
+		if c == n {
+			n.Parent.Children = append(siblings[:i:i], siblings[i+1:]...)
+			break
+		}
+	}
+	n.Parent = nil
+}
+
+// GetAttr returns the value of the attribute key.
+func (n *Node) GetAttr(key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// AttrVal returns the value of the attribute key, or "" when it is absent.
+func (n *Node) AttrVal(key string) string {
+	v, _ := n.GetAttr(key)
+	return v
+}
+
+// SetAttr sets the attribute key, replacing any existing value.
+func (n *Node) SetAttr(key, val string) {
+	for i := range n.Attr {
+		if n.Attr[i].Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, Attr{Key: key, Val: val})
+}
+
+// Text returns the concatenated text content of n and its descendants.
+func (n *Node) Text() string {
+	var b strings.Builder
+	n.Walk(func(c *Node) bool {
+		if c.Type == TextNode {
+			b.WriteString(c.Data)
+		}
+		return true
+	})
+	return b.String()
+}
+
+// Walk calls fn for n and its descendants in document order. Returning
+// false from fn skips the children of that node.
+func (n *Node) Walk(fn func(*Node) bool) {
+	if !fn(n) {
+		return
+	}
+	// Copy, so fn may remove nodes while walking.
+	for _, c := range append([]*Node(nil), n.Children...) {
+		c.Walk(fn)
+	}
+}
+
+// Find returns the elements below n with the given tag name.
+func (n *Node) Find(name string) []*Node {
+	var found []*Node
+	for _, c := range n.Children {
+		c.Walk(func(d *Node) bool {
+			if d.Type == ElementNode && d.Data == name {
+				found = append(found, d)
+			}
+			return true
+		})
+	}
+	return found
+}
+
+// Render writes n as HTML. A DocumentNode renders its children only.
+func Render(w io.Writer, n *Node) error {
+	var b strings.Builder
+	render(&b, n)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// String renders n as HTML.
+func (n *Node) String() string {
+	var b strings.Builder
+	render(&b, n)
+	return b.String()
+}
+
+// InnerHTML renders the children of n.
+func (n *Node) InnerHTML() string {
+	var b strings.Builder
+	for _, c := range n.Children {
+		render(&b, c)
+	}
+	return b.String()
+}
+
+func render(b *strings.Builder, n *Node) {
+	switch n.Type {
+	case DocumentNode:
+		for _, c := range n.Children {
+			render(b, c)
+		}
+	case TextNode:
+		if n.Parent != nil && (n.Parent.Data == "script" || n.Parent.Data == "style") {
+			b.WriteString(n.Data)
+			return
+		}
+		b.WriteString(html.EscapeString(n.Data))
+	case CommentNode:
+		b.WriteString("<!--")
+		b.WriteString(strings.ReplaceAll(n.Data, "--", ""))
+		b.WriteString("-->")
+	case ElementNode:
+		b.WriteByte('<')
+		b.WriteString(n.Data)
+		for _, a := range n.Attr {
+			b.WriteByte(' ')
+			b.WriteString(a.Key)
+			b.WriteString(`="`)
+			b.WriteString(html.EscapeString(a.Val))
+			b.WriteByte('"')
+		}
+		b.WriteByte('>')
+		if voidElements[n.Data] {
+			return
+		}
+		for _, c := range n.Children {
+			render(b, c)
+		}
+		b.WriteString("</")
+		b.WriteString(n.Data)
+		b.WriteByte('>')
+	}
+}
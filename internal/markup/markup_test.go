@@ -0,0 +1,68 @@
+package markup
+
+import "testing"
+
+func TestParseRender(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"text", "a &amp; b", "a &amp; b"},
+		{"nested", "<div><p>a<b>b</b></p></div>", "<div><p>a<b>b</b></p></div>"},
+		{"uppercase tags", "<P CLASS=x>a</P>", `<p class="x">a</p>`},
+		{"implicit p close", "<p>a<p>b", "<p>a</p><p>b</p>"},
+		{"implicit li close", "<ul><li>a<li>b</ul>", "<ul><li>a</li><li>b</li></ul>"},
+		{"void element", "<br>a<img src=x>", `<br>a<img src="x">`},
+		{"self-closing", "<span/>a", "<span></span>a"},
+		{"stray end tag", "a</b>c", "ac"},
+		{"unclosed", "<div><em>a", "<div><em>a</em></div>"},
+		{"lone less-than", "a < b", "a &lt; b"},
+		{"doctype dropped", "<!DOCTYPE html><p>a</p>", "<p>a</p>"},
+		{"processing instruction dropped", `<?xml version="1.0"?><p>a</p>`, "<p>a</p>"},
+		{"comment", "<!-- c -->a", "<!-- c -->a"},
+		{"comment with dashes", "<!-- a -- b -->", "<!-- a  b -->"},
+		{"unterminated comment", "<!-- a", "<!-- a-->"},
+		{"cdata", "<![CDATA[<b>]]>", "&lt;b&gt;"},
+		{"entities in attributes", `<a title="&quot;&lt;x&gt;">a</a>`, `<a title="&#34;&lt;x&gt;">a</a>`},
+		{"attribute quote breakout", `<a title='"><script>'>a</a>`, `<a title="&#34;&gt;&lt;script&gt;">a</a>`},
+		{"duplicate attribute", `<a href="1" href="2">a</a>`, `<a href="1">a</a>`},
+		{"attribute without value", "<input disabled>", `<input disabled="">`},
+		{"script raw text", "<script>if (a < b && c) {}</script>", "<script>if (a < b && c) {}</script>"},
+		{"script fake end tag", "<script>a='</scripty>'</script>", "<script>a='</scripty>'</script>"},
+		{"script markup not parsed", "<script><b>x</b></script>", "<script><b>x</b></script>"},
+		{"textarea unescaped", "<textarea>&lt;b&gt;</textarea>", "<textarea>&lt;b&gt;</textarea>"},
+		{"title raw text", "<title><script>x</script></title>", "<title>&lt;script&gt;x&lt;/script&gt;</title>"},
+		{"unterminated script", "<script>alert(1)", "<script>alert(1)</script>"},
+		{"raw text with runes that grow when lowercased", "<style>ȺȺȺȺȺȺȺȺȺȺ</style>a", "<style>ȺȺȺȺȺȺȺȺȺȺ</style>a"},
+		{"raw text with runes that shrink when lowercased", "<style>İİİİ</style>a<b>c</b>", "<style>İİİİ</style>a<b>c</b>"},
+		{"raw text end tag uppercase", "<style>x</STYLE >a", "<style>x</style>a"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.in).String(); got != tt.want {
+				t.Errorf("Parse(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	doc := Parse(`<img src = "a.png" ALT='x' onerror=alert(1) data-x>`)
+	imgs := doc.Find("img")
+	if len(imgs) != 1 {
+		t.Fatalf("found %d img elements, want 1", len(imgs))
+	}
+	img := imgs[0]
+	for key, want := range map[string]string{"src": "a.png", "alt": "x", "onerror": "alert(1)", "data-x": ""} {
+		if got, ok := img.GetAttr(key); !ok || got != want {
+			t.Errorf("attribute %s = %q, %v, want %q", key, got, ok, want)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	doc := Parse("<p>a <b>b</b><!-- c --> &amp; d</p>")
+	if got, want := doc.Text(), "a b & d"; got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+}
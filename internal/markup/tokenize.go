@@ -0,0 +1,225 @@
+package markup
+
+import (
+	"html"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	textToken tokenKind = iota
+	startTagToken
+	endTagToken
+	commentToken
+)
+
+type token struct {
+	kind        tokenKind
+	data        string
+	attr        []Attr
+	selfClosing bool
+}
+
+// tokenize splits s into tokens, calling emit for each. Doctypes and
+// processing instructions are dropped.
+func tokenize(s string, emit func(token)) {
+	for len(s) > 0 {
+		lt := strings.IndexByte(s, '<')
+		if lt < 0 {
+			emit(token{kind: textToken, data: html.UnescapeString(s)})
+			return
+		}
+		if lt > 0 {
+			emit(token{kind: textToken, data: html.UnescapeString(s[:lt])})
+			s = s[lt:]
+		}
+
+		switch {
+		case strings.HasPrefix(s, "<!--"):
+			end := strings.Index(s[4:], "-->")
+			if end < 0 {
+				emit(token{kind: commentToken, data: s[4:]})
+				return
+			}
+			emit(token{kind: commentToken, data: s[4 : 4+end]})
+			s = s[4+end+3:]
+		case strings.HasPrefix(s, "<![CDATA["):
+			end := strings.Index(s, "]]>")
+			if end < 0 {
+				emit(token{kind: textToken, data: s[9:]})
+				return
+			}
+			emit(token{kind: textToken, data: s[9:end]})
+			s = s[end+3:]
+		case strings.HasPrefix(s, "<!"), strings.HasPrefix(s, "<?"):
+			end := strings.IndexByte(s, '>')
+			if end < 0 {
+				return
+			}
+			s = s[end+1:]
+		case len(s) > 2 && s[1] == '/' && isLetter(s[2]):
+			name, rest := readName(s[2:])
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return
+			}
+			emit(token{kind: endTagToken, data: name})
+			s = rest[end+1:]
+		case len(s) > 1 && isLetter(s[1]):
+			var t token
+			t, s = readStartTag(s[1:])
+			emit(t)
+			if rawTextElements[t.data] && !t.selfClosing {
+				var text string
+				text, s = readRawText(s, t.data)
+				if text != "" {
+					if t.data == "textarea" || t.data == "title" {
+						text = html.UnescapeString(text)
+					}
+					emit(token{kind: textToken, data: text})
+				}
+				emit(token{kind: endTagToken, data: t.data})
+			}
+		default:
+			emit(token{kind: textToken, data: "<"})
+			s = s[1:]
+		}
+	}
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// readName reads a tag or attribute name and returns it lower-cased.
+func readName(s string) (string, string) {
+	i := 0
+	for i < len(s) && !isSpace(s[i]) && s[i] != '>' && s[i] != '/' && s[i] != '=' {
+		i++
+	}
+	return strings.ToLower(s[:i]), s[i:]
+}
+
+// readStartTag parses a start tag, s starting just after the '<'.
+func readStartTag(s string) (token, string) {
+	t := token{kind: startTagToken}
+	t.data, s = readName(s)
+	for {
+		for len(s) > 0 && (isSpace(s[0]) || s[0] == '/') {
+			if s[0] == '/' && len(s) > 1 && s[1] == '>' {
+				t.selfClosing = true
+			}
+			s = s[1:]
+		}
+		if len(s) == 0 {
+			return t, s
+		}
+		if s[0] == '>' {
+			return t, s[1:]
+		}
+
+		var key string
+		key, s = readName(s)
+		if key == "" {
+			// A stray '=' or similar; skip it.
+			s = s[1:]
+			continue
+		}
+		for len(s) > 0 && isSpace(s[0]) {
+			s = s[1:]
+		}
+		var val string
+		if len(s) > 0 && s[0] == '=' {
+			s = s[1:]
+			for len(s) > 0 && isSpace(s[0]) {
+				s = s[1:]
+			}
+			val, s = readAttrValue(s)
+		}
+		if _, dup := findAttr(t.attr, key); !dup {
+			t.attr = append(t.attr, Attr{Key: key, Val: html.UnescapeString(val)})
+		}
+	}
+}
+
+func readAttrValue(s string) (string, string) {
+	if len(s) == 0 {
+		return "", s
+	}
+	if q := s[0]; q == '"' || q == '\'' {
+		end := strings.IndexByte(s[1:], q)
+		if end < 0 {
+			return s[1:], ""
+		}
+		return s[1 : 1+end], s[1+end+1:]
+	}
+	i := 0
+	for i < len(s) && !isSpace(s[i]) && s[i] != '>' {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func findAttr(attrs []Attr, key string) (int, bool) {
+	for i, a := range attrs {
+		if a.Key == key {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// readRawText returns the content of a raw text element up to its end tag,
+// and the input following the end tag. The end tag is matched with ASCII
+// case folding on the original bytes: lowercasing the whole input would
+// shift offsets wherever a rune's lowercase has a different length.
+func readRawText(s, name string) (string, string) {
+	closing := "</" + name
+	from := 0
+	for {
+		i := strings.Index(s[from:], "</")
+		if i < 0 {
+			return s, ""
+		}
+		i += from
+		after := i + len(closing)
+		if after > len(s) {
+			return s, ""
+		}
+		if equalFoldASCII(s[i:after], closing) &&
+			(after == len(s) || isSpace(s[after]) || s[after] == '>' || s[after] == '/') {
+			end := strings.IndexByte(s[after:], '>')
+			if end < 0 {
+				return s[:i], ""
+			}
+			return s[:i], s[after+end+1:]
+		}
+		from = i + 2
+	}
+}
+
+// equalFoldASCII reports whether a and b are equal ignoring the case of
+// ASCII letters only.
+func equalFoldASCII(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		x, y := a[i], b[i]
+		if 'A' <= x && x <= 'Z' {
+			x += 'a' - 'A'
+		}
+		if 'A' <= y && y <= 'Z' {
+			y += 'a' - 'A'
+		}
+		if x != y {
+			return false
+		}
+	}
+	return true
+}
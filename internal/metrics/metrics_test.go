@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, r *Registry) string {
+	t.Helper()
+	server := httptest.NewServer(r.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("scrape: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("scrape: status %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("scrape: %v", err)
+	}
+	return string(body)
+}
+
+func assertLines(t *testing.T, body string, want []string) {
+	t.Helper()
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(body, "\n") {
+		lines[line] = true
+	}
+	for _, line := range want {
+		if !lines[line] {
+			t.Errorf("missing line %q in:\n%s", line, body)
+		}
+	}
+}
+
+func TestCounter(t *testing.T) {
+	r := NewRegistry()
+	fetches := r.NewCounter("test_fetches_total", "Fetches by status.", "status")
+	fetches.Inc("200")
+	fetches.Inc("200")
+	fetches.Add(3, "404")
+	fetches.Inc(`we"ird`)
+	plain := r.NewCounter("test_bytes_total", "Bytes.\nSecond line.")
+	plain.Add(1.5)
+
+	assertLines(t, scrape(t, r), []string{
+		"# HELP test_fetches_total Fetches by status.",
+		"# TYPE test_fetches_total counter",
+		`test_fetches_total{status="200"} 2`,
+		`test_fetches_total{status="404"} 3`,
+		`test_fetches_total{status="we\"ird"} 1`,
+		`# HELP test_bytes_total Bytes.\nSecond line.`,
+		"test_bytes_total 1.5",
+	})
+}
+
+func TestGauge(t *testing.T) {
+	r := NewRegistry()
+	feeds := r.NewGauge("test_feeds", "Feeds.")
+	scrapes := 0
+	r.OnScrape(func() {
+		scrapes++
+		feeds.Set(float64(10 * scrapes))
+	})
+
+	assertLines(t, scrape(t, r), []string{
+		"# TYPE test_feeds gauge",
+		"test_feeds 10",
+	})
+	assertLines(t, scrape(t, r), []string{"test_feeds 20"})
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("test_duration_seconds", "Durations.", []float64{0.5, 1, 5})
+	for _, v := range []float64{0.1, 0.5, 0.7, 3, 10} {
+		h.Observe(v)
+	}
+
+	assertLines(t, scrape(t, r), []string{
+		"# TYPE test_duration_seconds histogram",
+		`test_duration_seconds_bucket{le="0.5"} 2`,
+		`test_duration_seconds_bucket{le="1"} 3`,
+		`test_duration_seconds_bucket{le="5"} 4`,
+		`test_duration_seconds_bucket{le="+Inf"} 5`,
+		"test_duration_seconds_sum 14.3",
+		"test_duration_seconds_count 5",
+	})
+}
@@ -0,0 +1,234 @@
+// Package notify delivers alert messages to where users want them: a
+// generic JSON webhook, a Slack-compatible incoming webhook or a local
+// command. Senders make one attempt; retrying is up to the caller, guided
+// by IsPermanent.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of targets.
+const (
+	KindWebhook = "webhook"
+	KindSlack   = "slack"
+	KindCommand = "command"
+)
+
+// Headers of webhook deliveries. The signature is the hex HMAC-SHA256 of
+// the timestamp, a dot and the body, keyed with the alert's secret, so a
+// receiver can check both who sent a delivery and that it isn't replayed.
+const (
+	HeaderEvent     = "X-Gator-Event"
+	HeaderTimestamp = "X-Gator-Timestamp"
+	HeaderSignature = "X-Gator-Signature"
+)
+
+// ErrNotAllowed is returned for a command whose program isn't on the
+// allowlist given to CommandAllowed.
+var ErrNotAllowed = errors.New("command not allowed")
+
+// Message is what an alert delivers. It is sent as JSON to webhooks and on
+// standard input to commands.
+type Message struct {
+	Alert       string    `json:"alert"`
+	Match       string    `json:"match"`
+	FeedName    string    `json:"feed_name"`
+	FeedURL     string    `json:"feed_url"`
+	PostID      string    `json:"post_id"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	PublishedAt time.Time `json:"published_at"`
+	Summary     string    `json:"summary,omitempty"`
+}
+
+// Text is the message as a single line for chat.
+func (m Message) Text() string {
+	return fmt.Sprintf("[%s] %s matched %q: %s <%s>", m.Alert, m.FeedName, m.Match, m.Title, m.URL)
+}
+
+// Sender delivers a message once.
+type Sender interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// New returns the sender for a target of the given kind. client is used
+// by the webhook kinds.
+func New(kind, target, secret string, client *http.Client) (Sender, error) {
+	switch kind {
+	case KindWebhook:
+		return &Webhook{URL: target, Secret: secret, Client: client}, nil
+	case KindSlack:
+		return &Slack{URL: target, Client: client}, nil
+	case KindCommand:
+		if len(strings.Fields(target)) == 0 {
+			return nil, errors.New("empty command")
+		}
+		return &Command{Command: target}, nil
+	default:
+		return nil, fmt.Errorf("unknown target kind %q", kind)
+	}
+}
+
+// StatusError is returned when a webhook answers with anything but 2xx.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("webhook answered %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("webhook answered %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+// IsPermanent reports whether retrying a failed delivery is pointless: the
+// receiver rejected the request itself rather than being unavailable.
+func IsPermanent(err error) bool {
+	var status *StatusError
+	if errors.As(err, &status) {
+		code := status.StatusCode
+		return code >= 400 && code < 500 && code != http.StatusRequestTimeout && code != http.StatusTooManyRequests
+	}
+	return errors.Is(err, exec.ErrNotFound) || errors.Is(err, ErrNotAllowed)
+}
+
+// CommandAllowed reports whether command runs one of the allowed programs.
+// Programs are compared as written, so "/usr/local/bin/notify" doesn't
+// allow "notify" to be looked up in PATH.
+func CommandAllowed(command string, allowed []string) bool {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return false
+	}
+	for _, program := range allowed {
+		if args[0] == strings.TrimSpace(program) {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook POSTs the message as JSON.
+type Webhook struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+func (w *Webhook) Send(ctx context.Context, m Message) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set(HeaderEvent, "alert")
+	if w.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		header.Set(HeaderTimestamp, timestamp)
+		header.Set(HeaderSignature, "sha256="+Sign(w.Secret, timestamp, body))
+	}
+	return post(ctx, w.Client, w.URL, header, body)
+}
+
+// Sign returns the signature a webhook delivery carries; receivers compute
+// it the same way to verify a delivery.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Slack POSTs the message as text to an incoming webhook, which Slack,
+// Mattermost, Rocket.Chat and Discord (with /slack appended) accept.
+type Slack struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s *Slack) Send(ctx context.Context, m Message) error {
+	body, err := json.Marshal(map[string]string{"text": m.Text()})
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.Client, s.URL, http.Header{}, body)
+}
+
+func post(ctx context.Context, client *http.Client, url string, header http.Header, body []byte) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+	return &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(snippet))}
+}
+
+// Command runs a local program with the message as JSON on standard input
+// and its main fields in GATOR_ALERT_* environment variables. The command
+// line is split on spaces; no shell is involved.
+type Command struct {
+	Command string
+}
+
+func (c *Command) Send(ctx context.Context, m Message) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	args := strings.Fields(c.Command)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"GATOR_ALERT="+m.Alert,
+		"GATOR_ALERT_MATCH="+m.Match,
+		"GATOR_ALERT_FEED="+m.FeedName,
+		"GATOR_ALERT_TITLE="+m.Title,
+		"GATOR_ALERT_URL="+m.URL,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if out := strings.TrimSpace(string(output)); out != "" {
+			return fmt.Errorf("%w: %s", err, truncate(out, 200))
+		}
+		return err
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
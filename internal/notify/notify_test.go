@@ -0,0 +1,189 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var testMessage = Message{
+	Alert:       "outages",
+	Match:       "down",
+	FeedName:    "Status",
+	FeedURL:     "https://status.example.com/feed.xml",
+	PostID:      "7d3c6a5e-0000-4000-8000-000000000001",
+	Title:       "API down",
+	URL:         "https://status.example.com/1",
+	PublishedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+}
+
+// request is what a test server received.
+type request struct {
+	header http.Header
+	body   []byte
+}
+
+func newServer(t *testing.T, status int) (*httptest.Server, <-chan request) {
+	t.Helper()
+	received := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- request{header: r.Header, body: body}
+		w.WriteHeader(status)
+		fmt.Fprint(w, "nope")
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func TestWebhookSignature(t *testing.T) {
+	server, received := newServer(t, http.StatusNoContent)
+	sender, err := New(KindWebhook, server.URL, "s3cret", server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.Send(context.Background(), testMessage); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	req := <-received
+
+	if got := req.header.Get(HeaderEvent); got != "alert" {
+		t.Errorf("%s = %q, want %q", HeaderEvent, got, "alert")
+	}
+	if got := req.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	timestamp := req.header.Get(HeaderTimestamp)
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(sent, 0)).Abs() > time.Minute {
+		t.Errorf("%s = %q, want the current time", HeaderTimestamp, timestamp)
+	}
+
+	// Verify the way a receiver would, without Sign.
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(req.body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := req.header.Get(HeaderSignature); got != want {
+		t.Errorf("%s = %q, want %q", HeaderSignature, got, want)
+	}
+
+	var got Message
+	if err := json.Unmarshal(req.body, &got); err != nil {
+		t.Fatalf("body isn't a message: %v", err)
+	}
+	if got != testMessage {
+		t.Errorf("body = %+v, want %+v", got, testMessage)
+	}
+}
+
+func TestWebhookUnsigned(t *testing.T) {
+	server, received := newServer(t, http.StatusOK)
+	sender, err := New(KindWebhook, server.URL, "", server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.Send(context.Background(), testMessage); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	req := <-received
+	if got := req.header.Get(HeaderSignature); got != "" {
+		t.Errorf("%s = %q without a secret", HeaderSignature, got)
+	}
+}
+
+func TestSlackPayload(t *testing.T) {
+	server, received := newServer(t, http.StatusOK)
+	sender, err := New(KindSlack, server.URL, "", server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.Send(context.Background(), testMessage); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	req := <-received
+
+	var payload map[string]string
+	if err := json.Unmarshal(req.body, &payload); err != nil {
+		t.Fatalf("body isn't JSON: %v", err)
+	}
+	want := map[string]string{"text": `[outages] Status matched "down": API down <https://status.example.com/1>`}
+	if len(payload) != 1 || payload["text"] != want["text"] {
+		t.Errorf("payload = %v, want %v", payload, want)
+	}
+}
+
+func TestStatusErrors(t *testing.T) {
+	for _, tt := range []struct {
+		status    int
+		permanent bool
+	}{
+		{http.StatusBadRequest, true},
+		{http.StatusNotFound, true},
+		{http.StatusRequestTimeout, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusServiceUnavailable, false},
+	} {
+		server, _ := newServer(t, tt.status)
+		err := (&Slack{URL: server.URL, Client: server.Client()}).Send(context.Background(), testMessage)
+		var status *StatusError
+		if !errors.As(err, &status) || status.StatusCode != tt.status || status.Body != "nope" {
+			t.Errorf("status %d: err = %v, want a StatusError", tt.status, err)
+			continue
+		}
+		if got := IsPermanent(err); got != tt.permanent {
+			t.Errorf("IsPermanent(%v) = %v, want %v", err, got, tt.permanent)
+		}
+	}
+}
+
+func TestIsPermanent(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{exec.ErrNotFound, true},
+		{fmt.Errorf("run: %w", ErrNotAllowed), true},
+		{context.DeadlineExceeded, false},
+		{errors.New("connection refused"), false},
+	} {
+		if got := IsPermanent(tt.err); got != tt.want {
+			t.Errorf("IsPermanent(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestCommandAllowed(t *testing.T) {
+	allowed := []string{"/usr/local/bin/notify-me", " /opt/hooks/page "}
+	for _, tt := range []struct {
+		command string
+		want    bool
+	}{
+		{"/usr/local/bin/notify-me", true},
+		{"/usr/local/bin/notify-me --urgent", true},
+		{"  /opt/hooks/page  ops", true},
+		{"notify-me", false},
+		{"/usr/local/bin/notify-me-too", false},
+		{"/bin/sh -c /usr/local/bin/notify-me", false},
+		{"", false},
+	} {
+		if got := CommandAllowed(tt.command, allowed); got != tt.want {
+			t.Errorf("CommandAllowed(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+	if CommandAllowed("/usr/local/bin/notify-me", nil) {
+		t.Error("CommandAllowed allowed a command with an empty allowlist")
+	}
+}
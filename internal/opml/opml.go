@@ -0,0 +1,115 @@
+// Package opml reads and writes OPML subscription lists, the format feed
+// readers use to move subscriptions between each other. Folders are
+// outlines without a feed URL that contain other outlines.
+package opml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/jjboykin/gator/internal/charset"
+)
+
+type Document struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    Head     `xml:"head"`
+	Body    Body     `xml:"body"`
+}
+
+type Head struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type Body struct {
+	Outlines []Outline `xml:"outline"`
+}
+
+// Outline is a feed when XMLURL is set, and a folder otherwise.
+type Outline struct {
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr,omitempty"`
+	Type     string    `xml:"type,attr,omitempty"`
+	XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string    `xml:"htmlUrl,attr,omitempty"`
+	Outlines []Outline `xml:"outline"`
+}
+
+// Name returns the title of the outline, falling back to its text.
+func (o Outline) Name() string {
+	if title := strings.TrimSpace(o.Title); title != "" {
+		return title
+	}
+	return strings.TrimSpace(o.Text)
+}
+
+// Feed is a feed outline together with the folders it is nested in.
+type Feed struct {
+	Title   string
+	XMLURL  string
+	HTMLURL string
+	// Folders is the path of folder names from the top level down; it is
+	// empty for feeds that aren't in a folder.
+	Folders []string
+}
+
+// Parse reads an OPML document. Files exported by other readers are often
+// sloppy, so HTML entities and unclosed tags are tolerated, and documents
+// in legacy encodings are converted.
+func Parse(data []byte) (*Document, error) {
+	if converted, err := charset.ToUTF8(data, charset.Detect(data, "")); err == nil {
+		data = converted
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	var doc Document
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// Feeds returns every feed in the document, depth first.
+func (d *Document) Feeds() []Feed {
+	var feeds []Feed
+	var walk func(outlines []Outline, folders []string)
+	walk = func(outlines []Outline, folders []string) {
+		for _, o := range outlines {
+			if url := strings.TrimSpace(o.XMLURL); url != "" {
+				feeds = append(feeds, Feed{
+					Title:   o.Name(),
+					XMLURL:  url,
+					HTMLURL: strings.TrimSpace(o.HTMLURL),
+					Folders: folders,
+				})
+				continue
+			}
+			// Copy, so that sibling folders don't share a backing array.
+			walk(o.Outlines, append(folders[:len(folders):len(folders)], o.Name()))
+		}
+	}
+	walk(d.Body.Outlines, nil)
+	return feeds
+}
+
+// Write writes d as an indented OPML 2.0 document.
+func (d *Document) Write(w io.Writer) error {
+	d.Version = "2.0"
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(d); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,131 @@
+package opml
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFeeds(t *testing.T) {
+	doc, err := Parse([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<opml version="1.0">
+  <head><title>Subscriptions</title></head>
+  <body>
+    <outline text="Top level" xmlUrl=" https://example.com/top.xml " htmlUrl="https://example.com/"/>
+    <outline text="Tech" title="Technology">
+      <outline text="Go">
+        <outline type="rss" text="Go blog" xmlUrl="https://go.dev/blog/feed.atom"/>
+      </outline>
+      <outline type="rss" text="Lobsters" title="" xmlUrl="https://lobste.rs/rss"/>
+      <outline type="rss" text="No URL"/>
+    </outline>
+    <outline text="Empty folder"/>
+    <outline text="News">
+      <outline text="Daily" xmlUrl="https://news.example.com/daily"/>
+    </outline>
+  </body>
+</opml>`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if doc.Head.Title != "Subscriptions" {
+		t.Errorf("title = %q", doc.Head.Title)
+	}
+	want := []Feed{
+		{Title: "Top level", XMLURL: "https://example.com/top.xml", HTMLURL: "https://example.com/"},
+		{Title: "Go blog", XMLURL: "https://go.dev/blog/feed.atom", Folders: []string{"Technology", "Go"}},
+		{Title: "Lobsters", XMLURL: "https://lobste.rs/rss", Folders: []string{"Technology"}},
+		{Title: "Daily", XMLURL: "https://news.example.com/daily", Folders: []string{"News"}},
+	}
+	if got := doc.Feeds(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Feeds() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseSloppy(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		data string
+		want Feed
+	}{
+		{
+			"HTML entities",
+			`<opml><body><outline text="Caf&eacute;&nbsp;News &amp; more" xmlUrl="https://example.com/?a=1&amp;b=2"/></body></opml>`,
+			Feed{Title: "Café News & more", XMLURL: "https://example.com/?a=1&b=2"},
+		},
+		{
+			"unclosed outline",
+			`<opml><body><outline text="Folder"><outline text="Feed" xmlUrl="https://example.com/feed"></body></opml>`,
+			Feed{Title: "Feed", XMLURL: "https://example.com/feed", Folders: []string{"Folder"}},
+		},
+		{
+			"Latin-1",
+			"<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><opml><body><outline text=\"Caf\xe9\" xmlUrl=\"https://example.com/feed\"/></body></opml>",
+			Feed{Title: "Café", XMLURL: "https://example.com/feed"},
+		},
+	} {
+		doc, err := Parse([]byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: Parse: %v", tt.name, err)
+			continue
+		}
+		feeds := doc.Feeds()
+		if len(feeds) != 1 || !reflect.DeepEqual(feeds[0], tt.want) {
+			t.Errorf("%s: Feeds() = %+v, want %+v", tt.name, feeds, tt.want)
+		}
+	}
+
+	if _, err := Parse([]byte("not opml")); err == nil {
+		t.Error("Parse accepted a document without an opml element")
+	}
+}
+
+// Written documents parse back to the same feeds, whatever characters
+// the titles and URLs hold.
+func TestRoundTrip(t *testing.T) {
+	title := `Tom & Jerry's <"news">`
+	doc := &Document{
+		Head: Head{Title: "gator subscriptions", DateCreated: "Wed, 01 May 2024 12:00:00 +0000"},
+		Body: Body{Outlines: []Outline{
+			{Text: title, Title: title, Type: "rss", XMLURL: "https://example.com/?a=1&b=2", HTMLURL: "https://example.com/"},
+			{Text: "A & B", Title: "A & B", Outlines: []Outline{
+				{Text: "Inner", Title: "Inner", Outlines: []Outline{
+					{Text: "Deep", Title: "Deep", Type: "rss", XMLURL: "https://deep.example.com/feed"},
+				}},
+				{Text: "Sibling", Title: "Sibling", Type: "rss", XMLURL: "https://sibling.example.com/feed"},
+			}},
+		}},
+	}
+
+	var buf bytes.Buffer
+	if err := doc.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	written := buf.String()
+	if !strings.HasPrefix(written, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+`<opml version="2.0">`) {
+		t.Errorf("document starts with %q", written[:min(len(written), 60)])
+	}
+	if !strings.Contains(written, `text="Tom &amp; Jerry&#39;s &lt;&#34;news&#34;&gt;"`) {
+		t.Errorf("title isn't escaped in\n%s", written)
+	}
+
+	parsed, err := Parse(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if !reflect.DeepEqual(parsed.Head, doc.Head) {
+		t.Errorf("head = %+v, want %+v", parsed.Head, doc.Head)
+	}
+	if got, want := parsed.Feeds(), doc.Feeds(); !reflect.DeepEqual(got, want) {
+		t.Errorf("feeds after a round trip =\n%+v\nwant\n%+v", got, want)
+	}
+	want := []Feed{
+		{Title: title, XMLURL: "https://example.com/?a=1&b=2", HTMLURL: "https://example.com/"},
+		{Title: "Deep", XMLURL: "https://deep.example.com/feed", Folders: []string{"A & B", "Inner"}},
+		{Title: "Sibling", XMLURL: "https://sibling.example.com/feed", Folders: []string{"A & B"}},
+	}
+	if got := parsed.Feeds(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Feeds() =\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,74 @@
+// Package password hashes and checks passwords with PBKDF2-HMAC-SHA256.
+// Hashes are self-describing strings, "pbkdf2-sha256$<iterations>$<salt>$<key>"
+// with the salt and key in unpadded base64, so the cost can be raised later
+// without invalidating existing hashes.
+package password
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	scheme     = "pbkdf2-sha256"
+	iterations = 600000
+	saltLength = 16
+	keyLength  = 32
+)
+
+// MinLength is the shortest password Hash accepts.
+const MinLength = 8
+
+// Hash returns the hash of password to store.
+func Hash(password string) (string, error) {
+	if len(password) < MinLength {
+		return "", fmt.Errorf("password must be at least %d characters", MinLength)
+	}
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, keyLength)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{
+		scheme,
+		strconv.Itoa(iterations),
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	}, "$"), nil
+}
+
+// Check reports whether password matches hash. It fails with an error
+// only when hash is malformed.
+func Check(hash, password string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != scheme {
+		return false, errors.New("unknown password hash format")
+	}
+	iter, err := strconv.Atoi(parts[1])
+	if err != nil || iter <= 0 {
+		return false, errors.New("invalid password hash iterations")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false, errors.New("invalid password hash salt")
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(want) == 0 {
+		return false, errors.New("invalid password hash key")
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iter, len(want))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1, nil
+}
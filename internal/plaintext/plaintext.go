@@ -0,0 +1,357 @@
+// Package plaintext renders HTML post bodies as wrapped terminal text, with
+// list markers, quoted blockquotes and links collected into footnotes.
+package plaintext
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jjboykin/gator/internal/markup"
+)
+
+// blockElements start and end a line of output.
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"caption": true, "dd": true, "details": true, "div": true, "dl": true,
+	"dt": true, "figcaption": true, "figure": true, "footer": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "hr": true, "li": true, "main": true, "nav": true,
+	"ol": true, "p": true, "pre": true, "section": true, "summary": true,
+	"table": true, "tr": true, "ul": true,
+}
+
+// spacedElements are separated from their neighbours by a blank line.
+var spacedElements = map[string]bool{
+	"blockquote": true, "dl": true, "figure": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "hr": true, "ol": true,
+	"p": true, "pre": true, "table": true, "ul": true,
+}
+
+// skippedElements have no readable content.
+var skippedElements = map[string]bool{
+	"head": true, "script": true, "style": true, "template": true,
+	"noscript": true, "iframe": true, "object": true, "svg": true,
+}
+
+// Render converts an HTML fragment to plain text wrapped at width columns.
+// A width of zero or less disables wrapping. Links are numbered in the text
+// and listed at the end. Control characters other than newlines and tabs
+// are removed, so a feed can't send escape sequences to the terminal.
+func Render(s string, width int) string {
+	r := renderer{width: width}
+	r.walk(markup.Parse(s))
+	r.flush()
+
+	out := strings.TrimRight(r.out.String(), "\n")
+	if len(r.links) > 0 {
+		var b strings.Builder
+		b.WriteString(out)
+		b.WriteString("\n")
+		for i, link := range r.links {
+			fmt.Fprintf(&b, "\n[%d] %s", i+1, link)
+		}
+		out = b.String()
+	}
+	return stripControl(out)
+}
+
+// stripControl removes C0 and C1 control characters and DEL from s, except
+// for newlines and tabs.
+func stripControl(s string) string {
+	return strings.Map(func(c rune) rune {
+		if c != '\n' && c != '\t' && unicode.IsControl(c) {
+			return -1
+		}
+		return c
+	}, s)
+}
+
+type renderer struct {
+	width int
+	out   strings.Builder
+
+	// inline collects the text of the current block until it is flushed.
+	inline strings.Builder
+	pre    int
+	blank  bool
+
+	// indent holds one entry per open list item or blockquote.
+	indent []indent
+	links  []string
+}
+
+type indent struct {
+	first, rest string
+	used        bool
+}
+
+func (r *renderer) walk(n *markup.Node) {
+	switch n.Type {
+	case markup.TextNode:
+		if r.pre > 0 {
+			r.inline.WriteString(n.Data)
+		} else {
+			r.inline.WriteString(collapseSpace(n.Data))
+		}
+		return
+	case markup.DocumentNode:
+		r.children(n)
+		return
+	case markup.ElementNode:
+	default:
+		return
+	}
+
+	name := n.Data
+	if skippedElements[name] {
+		return
+	}
+	if blockElements[name] {
+		r.block(spacedElements[name] && !r.inList(name))
+	}
+
+	switch name {
+	case "br":
+		r.inline.WriteString("\n")
+	case "hr":
+		r.inline.WriteString(strings.Repeat("-", min(max(r.width, 10), 40)))
+		r.block(true)
+	case "img":
+		if alt := strings.TrimSpace(n.AttrVal("alt")); alt != "" {
+			r.inline.WriteString("[image: " + alt + "]")
+		} else {
+			r.inline.WriteString("[image]")
+		}
+	case "a":
+		r.children(n)
+		r.footnote(n)
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level, _ := strconv.Atoi(name[1:])
+		r.inline.WriteString(strings.Repeat("#", level) + " ")
+		r.children(n)
+	case "pre":
+		r.pre++
+		r.children(n)
+		r.block(false)
+		r.pre--
+	case "blockquote":
+		r.indent = append(r.indent, indent{first: "> ", rest: "> "})
+		r.children(n)
+		r.block(false)
+		r.indent = r.indent[:len(r.indent)-1]
+	case "ol", "ul":
+		number := 1
+		if start, err := strconv.Atoi(n.AttrVal("start")); err == nil {
+			number = start
+		}
+		for _, c := range n.Children {
+			if c.Type != markup.ElementNode || c.Data != "li" {
+				r.walk(c)
+				continue
+			}
+			marker := "- "
+			if name == "ol" {
+				marker = strconv.Itoa(number) + ". "
+				number++
+			}
+			r.block(false)
+			r.indent = append(r.indent, indent{first: marker, rest: strings.Repeat(" ", len(marker))})
+			r.children(c)
+			r.block(false)
+			r.indent = r.indent[:len(r.indent)-1]
+		}
+	case "dd":
+		r.indent = append(r.indent, indent{first: "    ", rest: "    "})
+		r.children(n)
+		r.block(false)
+		r.indent = r.indent[:len(r.indent)-1]
+	case "td", "th":
+		if r.inline.Len() > 0 {
+			r.inline.WriteString(" | ")
+		}
+		r.children(n)
+	default:
+		r.children(n)
+	}
+
+	if blockElements[name] {
+		r.block(spacedElements[name] && !r.inList(name))
+	}
+}
+
+func (r *renderer) children(n *markup.Node) {
+	for _, c := range n.Children {
+		r.walk(c)
+	}
+}
+
+// inList reports whether a nested list or paragraph sits inside a list
+// item, where blank lines would break up the item.
+func (r *renderer) inList(name string) bool {
+	if name != "ul" && name != "ol" && name != "p" {
+		return false
+	}
+	for _, in := range r.indent {
+		if in.first != "> " {
+			return true
+		}
+	}
+	return false
+}
+
+// footnote numbers the link target of a, unless it is what the link text
+// already says.
+func (r *renderer) footnote(a *markup.Node) {
+	href := strings.TrimSpace(a.AttrVal("href"))
+	if href == "" || strings.HasPrefix(href, "#") {
+		return
+	}
+	text := strings.TrimSpace(a.Text())
+	if text == href || "mailto:"+text == href {
+		return
+	}
+	for i, link := range r.links {
+		if link == href {
+			fmt.Fprintf(&r.inline, " [%d]", i+1)
+			return
+		}
+	}
+	r.links = append(r.links, href)
+	fmt.Fprintf(&r.inline, " [%d]", len(r.links))
+}
+
+// block ends the current block, requesting a blank line before the next
+// one when spaced is set.
+func (r *renderer) block(spaced bool) {
+	r.flush()
+	if spaced && r.out.Len() > 0 {
+		r.blank = true
+	}
+}
+
+// flush writes the collected inline text, wrapped and prefixed.
+func (r *renderer) flush() {
+	text := r.inline.String()
+	r.inline.Reset()
+
+	var lines []string
+	if r.pre > 0 {
+		lines = strings.Split(strings.Trim(text, "\n"), "\n")
+		if strings.TrimSpace(text) == "" {
+			return
+		}
+	} else {
+		for _, line := range strings.Split(text, "\n") {
+			lines = append(lines, r.wrap(strings.Fields(line))...)
+		}
+		for len(lines) > 0 && lines[0] == "" {
+			lines = lines[1:]
+		}
+		for len(lines) > 0 && lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		if len(lines) == 0 {
+			return
+		}
+	}
+
+	if r.blank {
+		r.out.WriteString(r.prefix(true))
+		r.out.WriteString("\n")
+		r.blank = false
+	}
+	for _, line := range lines {
+		r.out.WriteString(strings.TrimRight(r.prefix(false)+line, " "))
+		r.out.WriteString("\n")
+	}
+}
+
+// prefix returns the indentation for the next line. A blank separator line
+// only repeats the markers of blockquotes that have already started.
+func (r *renderer) prefix(separator bool) string {
+	var b strings.Builder
+	for i := range r.indent {
+		in := &r.indent[i]
+		switch {
+		case separator && !in.used:
+		case separator && in.first == "> ":
+			b.WriteString(">")
+		case separator:
+			b.WriteString(in.rest)
+		case !in.used:
+			b.WriteString(in.first)
+			in.used = true
+		default:
+			b.WriteString(in.rest)
+		}
+	}
+	if separator {
+		return strings.TrimRight(b.String(), " ")
+	}
+	return b.String()
+}
+
+func (r *renderer) prefixWidth() int {
+	n := 0
+	for _, in := range r.indent {
+		n += len(in.rest)
+	}
+	return n
+}
+
+// wrap joins words into lines no longer than the available width. A word
+// longer than the width gets a line of its own.
+func (r *renderer) wrap(words []string) []string {
+	if len(words) == 0 {
+		return []string{""}
+	}
+	width := r.width - r.prefixWidth()
+	if r.width <= 0 {
+		return []string{strings.Join(words, " ")}
+	}
+	width = max(width, 20)
+
+	var lines []string
+	var line strings.Builder
+	lineLen := 0
+	for _, word := range words {
+		wordLen := utf8.RuneCountInString(word)
+		if lineLen > 0 && lineLen+1+wordLen > width {
+			lines = append(lines, line.String())
+			line.Reset()
+			lineLen = 0
+		}
+		if lineLen > 0 {
+			line.WriteByte(' ')
+			lineLen++
+		}
+		line.WriteString(word)
+		lineLen += wordLen
+	}
+	return append(lines, line.String())
+}
+
+// collapseSpace replaces runs of whitespace with a single space, as a
+// browser would outside <pre>.
+func collapseSpace(s string) string {
+	var b strings.Builder
+	space := false
+	for _, c := range s {
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(c)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
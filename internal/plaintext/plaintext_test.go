@@ -0,0 +1,58 @@
+package plaintext
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		in    string
+		width int
+		want  string
+	}{
+		{"paragraphs", "<p>a</p><p>b</p>", 0, "a\n\nb"},
+		{"collapsed space", "a  \n b", 0, "a b"},
+		{"wrapping", "<p>one two three four five six</p>", 20, "one two three four\nfive six"},
+		{"list", "<ul><li>a</li><li>b</li></ul>", 0, "- a\n- b"},
+		{"ordered list", `<ol start="3"><li>a</li><li>b</li></ol>`, 0, "3. a\n4. b"},
+		{"blockquote", "<blockquote><p>a</p><p>b</p></blockquote>", 0, "> a\n>\n> b"},
+		{"heading", "<h2>Title</h2>text", 0, "## Title\n\ntext"},
+		{"link footnote", `<a href="https://a.example/">a</a> and <a href="https://a.example/">again</a>`, 0, "a [1] and again [1]\n\n[1] https://a.example/"},
+		{"link showing its URL", `<a href="https://a.example/">https://a.example/</a>`, 0, "https://a.example/"},
+		{"script skipped", "<script>alert(1)</script>a", 0, "a"},
+		{"image alt", `<img src="x.png" alt="cat">`, 0, "[image: cat]"},
+		{"pre keeps tabs", "<pre>a\tb\n  c</pre>", 0, "a\tb\n  c"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.in, tt.width); got != tt.want {
+				t.Errorf("Render(%q, %d)\n got %q\nwant %q", tt.in, tt.width, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderStripsControlCharacters(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"escape sequence", "a\x1b[31mred\x1b[0m", "a[31mred[0m"},
+		{"escape entity", "a&#27;]0;title&#7;b", "a]0;titleb"},
+		{"bell and backspace", "a\x07\x08b", "ab"},
+		{"carriage return in pre", "<pre>safe\rEVIL</pre>", "safeEVIL"},
+		{"delete", "a\x7fb", "ab"},
+		{"C1 CSI", "a\u009b31mb", "a31mb"},
+		{"C1 OSC", "a\u009d0;x\u009cb", "a0;xb"},
+		{"null", "a\x00b", "ab"},
+		{"newline and tab kept", "<pre>a\n\tb</pre>", "a\n\tb"},
+		{"link target", `<a href="https://a.example/&#27;[2J">x</a>`, "x [1]\n\n[1] https://a.example/[2J"},
+		{"image alt", "<img alt=\"\x1b]8;;https://evil.example\x07cat\">", "[image: ]8;;https://evil.examplecat]"},
+		{"printable unicode kept", "café – ☃", "café – ☃"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.in, 0); got != tt.want {
+				t.Errorf("Render(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
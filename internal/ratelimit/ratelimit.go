@@ -0,0 +1,266 @@
+// Package ratelimit keeps gator polite towards the hosts it fetches from:
+// each host gets a token bucket and a cap on concurrent requests, and a
+// host that asked us to back off (429/503 with Retry-After) is left alone
+// until the time it gave. Budgets can be shared by the subdomains of a
+// rule; deferrals are always for the exact host that asked.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDeferred is wrapped by the error Acquire returns for a host that
+// asked us to back off.
+var ErrDeferred = errors.New("host asked to back off")
+
+// DeferredError reports until when requests to Host are deferred.
+type DeferredError struct {
+	Host  string
+	Until time.Time
+}
+
+func (e *DeferredError) Error() string {
+	return fmt.Sprintf("%s: %s until %s", ErrDeferred, e.Host, e.Until.Format(time.RFC3339))
+}
+
+func (e *DeferredError) Unwrap() error {
+	return ErrDeferred
+}
+
+// Limits apply to one host. Zero means unlimited.
+type Limits struct {
+	// PerMinute is the sustained request rate; Burst is how many requests
+	// may be made at once after a quiet period.
+	PerMinute int
+	Burst     int
+	// MaxConcurrent caps the requests in flight.
+	MaxConcurrent int
+}
+
+// Limiter is safe for concurrent use.
+type Limiter struct {
+	mu       sync.Mutex
+	defaults Limits
+	rules    map[string]Limits
+	// hosts holds the budgets, keyed as Key returns; deferrals are keyed
+	// by host name.
+	hosts     map[string]*host
+	deferrals map[string]time.Time
+}
+
+type host struct {
+	tokens   float64
+	refilled time.Time
+	active   int
+	// freed is closed, and replaced, whenever a request finishes.
+	freed chan struct{}
+}
+
+// New returns a Limiter applying defaults to every host except those
+// matching a key of rules. A rule for "example.com" covers example.com
+// and all of its subdomains, which then share a single budget: feeds on
+// a.substack.com and b.substack.com are served by the same machines.
+func New(defaults Limits, rules map[string]Limits) *Limiter {
+	l := &Limiter{hosts: make(map[string]*host), deferrals: make(map[string]time.Time)}
+	l.SetLimits(defaults, rules)
+	return l
+}
+
+// SetLimits replaces the limits, keeping the state of every host,
+// including deferrals.
+func (l *Limiter) SetLimits(defaults Limits, rules map[string]Limits) {
+	normalized := make(map[string]Limits, len(rules))
+	for name, limits := range rules {
+		normalized[strings.TrimPrefix(strings.ToLower(name), ".")] = limits
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.defaults = defaults
+	l.rules = normalized
+}
+
+// Key returns the name under which hostname is limited: the longest
+// matching rule, or the host name itself.
+func (l *Limiter) Key(hostname string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key, _ := l.match(hostname)
+	return key
+}
+
+func (l *Limiter) match(hostname string) (string, Limits) {
+	hostname = normalize(hostname)
+	key, limits := hostname, l.defaults
+	best := -1
+	for name, rule := range l.rules {
+		if (hostname == name || strings.HasSuffix(hostname, "."+name)) && len(name) > best {
+			key, limits, best = name, rule, len(name)
+		}
+	}
+	return key, limits
+}
+
+func normalize(hostname string) string {
+	return strings.TrimSuffix(strings.ToLower(hostname), ".")
+}
+
+// deferredUntil returns until when hostname is deferred, forgetting
+// deferrals that have run out. l.mu must be held.
+func (l *Limiter) deferredUntil(hostname string, now time.Time) (time.Time, bool) {
+	until, ok := l.deferrals[hostname]
+	if !ok {
+		return time.Time{}, false
+	}
+	if !now.Before(until) {
+		delete(l.deferrals, hostname)
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+func (l *Limiter) state(key string) *host {
+	h, ok := l.hosts[key]
+	if !ok {
+		h = &host{tokens: -1, freed: make(chan struct{})}
+		l.hosts[key] = h
+	}
+	return h
+}
+
+// Acquire waits until a request to hostname is allowed and returns the
+// function to call once it has finished. It fails right away with a
+// *DeferredError if the host asked us to back off.
+func (l *Limiter) Acquire(ctx context.Context, hostname string) (release func(), err error) {
+	for {
+		l.mu.Lock()
+		key, limits := l.match(hostname)
+		h := l.state(key)
+		now := time.Now()
+
+		if until, ok := l.deferredUntil(normalize(hostname), now); ok {
+			l.mu.Unlock()
+			return nil, &DeferredError{Host: normalize(hostname), Until: until}
+		}
+
+		h.refill(limits, now)
+		hasSlot := limits.MaxConcurrent <= 0 || h.active < limits.MaxConcurrent
+		hasToken := limits.PerMinute <= 0 || h.tokens >= 1
+		if hasSlot && hasToken {
+			if limits.PerMinute > 0 {
+				h.tokens--
+			}
+			h.active++
+			l.mu.Unlock()
+			var once sync.Once
+			return func() { once.Do(func() { l.release(h) }) }, nil
+		}
+
+		// Out of tokens: wait for the next one. Out of slots: wait for a
+		// request to finish.
+		var timer *time.Timer
+		var wait <-chan time.Time
+		if hasSlot {
+			timer = time.NewTimer(time.Duration((1 - h.tokens) / perSecond(limits) * float64(time.Second)))
+			wait = timer.C
+		}
+		freed := h.freed
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-wait:
+		case <-freed:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (l *Limiter) release(h *host) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h.active--
+	close(h.freed)
+	h.freed = make(chan struct{})
+}
+
+// refill adds the tokens earned since the last refill. A new host starts
+// with a full bucket.
+func (h *host) refill(limits Limits, now time.Time) {
+	if limits.PerMinute <= 0 {
+		return
+	}
+	burst := float64(max(limits.Burst, 1))
+	if h.tokens < 0 {
+		h.tokens = burst
+	} else {
+		h.tokens = min(burst, h.tokens+now.Sub(h.refilled).Seconds()*perSecond(limits))
+	}
+	h.refilled = now
+}
+
+func perSecond(limits Limits) float64 {
+	return float64(limits.PerMinute) / 60
+}
+
+// Defer refuses requests to hostname until the given time. Other hosts
+// sharing its rule keep their budget and aren't deferred: they may well
+// be served by different machines. An earlier deferral never shortens a
+// later one.
+func (l *Limiter) Defer(hostname string, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	hostname = normalize(hostname)
+	if current, ok := l.deferredUntil(hostname, time.Now()); !ok || until.After(current) {
+		l.deferrals[hostname] = until
+	}
+}
+
+// Deferral returns the deferral in effect for hostname, or nil.
+func (l *Limiter) Deferral(hostname string) *DeferredError {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	hostname = normalize(hostname)
+	if until, ok := l.deferredUntil(hostname, time.Now()); ok {
+		return &DeferredError{Host: hostname, Until: until}
+	}
+	return nil
+}
+
+// ParseRetryAfter parses a Retry-After header, which is either a number
+// of seconds or an HTTP date, into how long to wait from now.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	when, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(when.Sub(now), 0), true
+}
+
+// IsBackoffStatus reports whether a response with status code asks the
+// client to slow down, making its Retry-After worth honouring.
+func IsBackoffStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+}
@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// acquireWithin tries to acquire within d and reports whether it did.
+func acquireWithin(t *testing.T, l *Limiter, hostname string, d time.Duration) (func(), bool) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	release, err := l.Acquire(ctx, hostname)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, false
+	}
+	if err != nil {
+		t.Fatalf("Acquire(%q): %v", hostname, err)
+	}
+	return release, true
+}
+
+func TestTokenBucket(t *testing.T) {
+	// 6000 a minute is a token every 10ms.
+	l := New(Limits{PerMinute: 6000, Burst: 3}, nil)
+
+	for i := range 3 {
+		release, ok := acquireWithin(t, l, "example.com", time.Millisecond)
+		if !ok {
+			t.Fatalf("request %d of the burst had to wait", i+1)
+		}
+		release()
+	}
+	if _, ok := acquireWithin(t, l, "example.com", time.Millisecond); ok {
+		t.Fatal("request after the burst didn't wait")
+	}
+	start := time.Now()
+	if _, ok := acquireWithin(t, l, "example.com", time.Second); !ok {
+		t.Fatal("no token within a second")
+	}
+	if waited := time.Since(start); waited > 500*time.Millisecond {
+		t.Errorf("waited %s for a token earned every 10ms", waited)
+	}
+
+	// Other hosts have their own bucket.
+	if _, ok := acquireWithin(t, l, "example.org", time.Millisecond); !ok {
+		t.Error("another host had to wait")
+	}
+}
+
+func TestConcurrencyCap(t *testing.T) {
+	l := New(Limits{MaxConcurrent: 1}, map[string]Limits{"Example.com": {MaxConcurrent: 2}})
+
+	release, ok := acquireWithin(t, l, "other.net", time.Millisecond)
+	if !ok {
+		t.Fatal("first request had to wait")
+	}
+	if _, ok := acquireWithin(t, l, "other.net", 20*time.Millisecond); ok {
+		t.Fatal("second request ran past the cap of 1")
+	}
+	released := make(chan struct{})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		release()
+		release() // a second call is harmless
+		close(released)
+	}()
+	if _, ok := acquireWithin(t, l, "other.net", time.Second); !ok {
+		t.Fatal("request didn't get the freed slot")
+	}
+	<-released
+
+	// The rule's subdomains share its budget of 2.
+	if _, ok := acquireWithin(t, l, "a.example.com", time.Millisecond); !ok {
+		t.Fatal("first request under the rule had to wait")
+	}
+	if _, ok := acquireWithin(t, l, "B.EXAMPLE.COM.", time.Millisecond); !ok {
+		t.Fatal("second request under the rule had to wait")
+	}
+	if _, ok := acquireWithin(t, l, "example.com", 20*time.Millisecond); ok {
+		t.Fatal("third request under the rule ran past its cap of 2")
+	}
+	if got := l.Key("b.example.com"); got != "example.com" {
+		t.Errorf("Key(b.example.com) = %q, want example.com", got)
+	}
+	if got := l.Key("notexample.com"); got != "notexample.com" {
+		t.Errorf("Key(notexample.com) = %q, want notexample.com", got)
+	}
+}
+
+func TestDefer(t *testing.T) {
+	l := New(Limits{}, map[string]Limits{"example.com": {MaxConcurrent: 5}})
+	until := time.Now().Add(time.Hour)
+	l.Defer("A.example.com", until)
+
+	_, err := l.Acquire(context.Background(), "a.example.com.")
+	var deferral *DeferredError
+	if !errors.As(err, &deferral) || !errors.Is(err, ErrDeferred) {
+		t.Fatalf("Acquire on a deferred host = %v, want a DeferredError", err)
+	}
+	if deferral.Host != "a.example.com" || !deferral.Until.Equal(until) {
+		t.Errorf("deferral = %s until %s, want a.example.com until %s", deferral.Host, deferral.Until, until)
+	}
+	if got := l.Deferral("a.example.com"); got == nil || !got.Until.Equal(until) {
+		t.Errorf("Deferral(a.example.com) = %v, want until %s", got, until)
+	}
+
+	// Hosts sharing the rule aren't deferred.
+	for _, hostname := range []string{"b.example.com", "example.com"} {
+		if got := l.Deferral(hostname); got != nil {
+			t.Errorf("Deferral(%s) = %v, want nil", hostname, got)
+		}
+		if _, ok := acquireWithin(t, l, hostname, time.Millisecond); !ok {
+			t.Errorf("Acquire(%s) had to wait", hostname)
+		}
+	}
+
+	// An earlier deferral doesn't shorten a later one.
+	l.Defer("a.example.com", time.Now().Add(time.Minute))
+	if got := l.Deferral("a.example.com"); got == nil || !got.Until.Equal(until) {
+		t.Errorf("after a shorter deferral, Deferral = %v, want until %s", got, until)
+	}
+
+	// A deferral that has run out no longer applies.
+	l.Defer("c.example.com", time.Now().Add(-time.Second))
+	if got := l.Deferral("c.example.com"); got != nil {
+		t.Errorf("expired Deferral = %v, want nil", got)
+	}
+	if _, ok := acquireWithin(t, l, "c.example.com", time.Millisecond); !ok {
+		t.Error("host with an expired deferral had to wait")
+	}
+}
+
+func TestSetLimitsKeepsDeferrals(t *testing.T) {
+	l := New(Limits{}, nil)
+	l.Defer("example.com", time.Now().Add(time.Hour))
+	l.SetLimits(Limits{PerMinute: 10}, map[string]Limits{"example.com": {}})
+	if l.Deferral("example.com") == nil {
+		t.Error("SetLimits dropped a deferral")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{" 0 ", 0, true},
+		{"-1", 0, false},
+		{"Wed, 01 May 2024 12:05:00 GMT", 5 * time.Minute, true},
+		{"Wed, 01 May 2024 11:00:00 GMT", 0, true},
+		{"soon", 0, false},
+		{"", 0, false},
+	} {
+		got, ok := ParseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseRetryAfter(%q) = %s, %v, want %s, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+// Package readability extracts the main article from a web page, in the
+// spirit of Arc90's Readability: paragraphs score the elements that contain
+// them, link-heavy and boilerplate-looking elements are penalized, and the
+// best scoring element plus its related siblings make up the article.
+package readability
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jjboykin/gator/internal/markup"
+)
+
+// ErrNoContent is returned when no part of the page looks like an article.
+var ErrNoContent = errors.New("no article content found")
+
+// Article is the result of an extraction.
+type Article struct {
+	Title string
+	// Content is the article HTML. It is not sanitized.
+	Content string
+	// TextLength is the number of characters of text in Content.
+	TextLength int
+}
+
+// minTextLength is the least amount of text accepted as an article.
+const minTextLength = 250
+
+var (
+	unlikelyCandidates = regexp.MustCompile(`(?i)banner|breadcrumbs|combx|comment|community|cookie|disqus|extra|footer|gdpr|header|legends|menu|modal|nav|pager|pagination|popup|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|tweet|ad-break|agegate`)
+	maybeCandidate     = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positiveNames      = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|pagination|post|text|blog|story`)
+	negativeNames      = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|foot|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+)
+
+// removedElements never hold article text.
+var removedElements = map[string]bool{
+	"script": true, "style": true, "noscript": true, "iframe": true,
+	"form": true, "button": true, "input": true, "select": true,
+	"textarea": true, "nav": true, "aside": true, "footer": true,
+	"svg": true, "object": true, "embed": true, "link": true, "meta": true,
+}
+
+// Extract finds the article in page. base, when set, is used to make
+// relative links and image sources absolute.
+func Extract(page string, base *url.URL) (Article, error) {
+	doc := markup.Parse(page)
+	article := Article{Title: title(doc)}
+
+	body := doc
+	if bodies := doc.Find("body"); len(bodies) > 0 {
+		body = bodies[0]
+	}
+	prune(body)
+
+	scores := score(body)
+	top := topCandidate(scores, body)
+	if top == nil {
+		return article, ErrNoContent
+	}
+
+	content := &markup.Node{Type: markup.ElementNode, Data: "div"}
+	for _, n := range related(top, scores) {
+		content.AppendChild(n)
+	}
+	if base != nil {
+		absolutize(content, base)
+	}
+
+	article.TextLength = utf8.RuneCountInString(strings.Join(strings.Fields(content.Text()), " "))
+	if article.TextLength < minTextLength {
+		return article, ErrNoContent
+	}
+	article.Content = content.InnerHTML()
+	return article, nil
+}
+
+// title prefers the Open Graph title, which unlike <title> usually lacks
+// the site name.
+func title(doc *markup.Node) string {
+	for _, meta := range doc.Find("meta") {
+		if meta.AttrVal("property") == "og:title" {
+			if t := strings.TrimSpace(meta.AttrVal("content")); t != "" {
+				return t
+			}
+		}
+	}
+	if titles := doc.Find("title"); len(titles) > 0 {
+		return strings.TrimSpace(titles[0].Text())
+	}
+	return ""
+}
+
+// prune removes elements that can't be part of the article, and those whose
+// class or id suggests boilerplate.
+func prune(root *markup.Node) {
+	root.Walk(func(n *markup.Node) bool {
+		if n.Type == markup.CommentNode {
+			n.Remove()
+			return false
+		}
+		if n.Type != markup.ElementNode || n == root {
+			return true
+		}
+		if removedElements[n.Data] {
+			n.Remove()
+			return false
+		}
+		if n.Data == "body" || n.Data == "article" || n.Data == "main" {
+			return true
+		}
+		names := n.AttrVal("class") + " " + n.AttrVal("id")
+		if unlikelyCandidates.MatchString(names) && !maybeCandidate.MatchString(names) {
+			n.Remove()
+			return false
+		}
+		if hidden(n) {
+			n.Remove()
+			return false
+		}
+		return true
+	})
+}
+
+func hidden(n *markup.Node) bool {
+	if _, ok := n.GetAttr("hidden"); ok {
+		return true
+	}
+	if n.AttrVal("aria-hidden") == "true" {
+		return true
+	}
+	style := strings.ReplaceAll(strings.ToLower(n.AttrVal("style")), " ", "")
+	return strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden")
+}
+
+// score credits every element containing a paragraph of text: the parent
+// in full and the grandparent by half.
+func score(root *markup.Node) map[*markup.Node]float64 {
+	scores := make(map[*markup.Node]float64)
+	initialize := func(n *markup.Node) {
+		if _, ok := scores[n]; !ok {
+			scores[n] = baseScore(n)
+		}
+	}
+
+	root.Walk(func(n *markup.Node) bool {
+		if n.Type != markup.ElementNode {
+			return true
+		}
+		switch n.Data {
+		case "p", "pre", "td", "blockquote":
+		case "div":
+			// A div holding only inline content acts as a paragraph.
+			if hasBlockChild(n) {
+				return true
+			}
+		default:
+			return true
+		}
+
+		text := strings.TrimSpace(n.Text())
+		length := utf8.RuneCountInString(text)
+		if length < 25 || n.Parent == nil {
+			return true
+		}
+		points := 1 + float64(strings.Count(text, ",")) + min(float64(length)/100, 3)
+
+		parent := n.Parent
+		initialize(parent)
+		scores[parent] += points
+		if grand := parent.Parent; grand != nil && grand.Type == markup.ElementNode {
+			initialize(grand)
+			scores[grand] += points / 2
+		}
+		return true
+	})
+
+	for n := range scores {
+		scores[n] *= 1 - linkDensity(n)
+	}
+	return scores
+}
+
+func baseScore(n *markup.Node) float64 {
+	var s float64
+	switch n.Data {
+	case "article":
+		s = 10
+	case "div", "main", "section":
+		s = 5
+	case "pre", "td", "blockquote":
+		s = 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		s = -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		s = -5
+	}
+	return s + classWeight(n)
+}
+
+func classWeight(n *markup.Node) float64 {
+	var weight float64
+	for _, name := range []string{n.AttrVal("class"), n.AttrVal("id")} {
+		if name == "" {
+			continue
+		}
+		if negativeNames.MatchString(name) {
+			weight -= 25
+		}
+		if positiveNames.MatchString(name) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+var blockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"div": true, "dl": true, "figure": true, "footer": true, "h1": true,
+	"h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "ol": true, "p": true, "pre": true, "section": true,
+	"table": true, "ul": true,
+}
+
+func hasBlockChild(n *markup.Node) bool {
+	for _, c := range n.Children {
+		if c.Type == markup.ElementNode && blockTags[c.Data] {
+			return true
+		}
+	}
+	return false
+}
+
+// linkDensity is the fraction of n's text that sits inside links.
+func linkDensity(n *markup.Node) float64 {
+	total := utf8.RuneCountInString(strings.TrimSpace(n.Text()))
+	if total == 0 {
+		return 0
+	}
+	var linked int
+	for _, a := range n.Find("a") {
+		linked += utf8.RuneCountInString(strings.TrimSpace(a.Text()))
+	}
+	return float64(linked) / float64(total)
+}
+
+func topCandidate(scores map[*markup.Node]float64, body *markup.Node) *markup.Node {
+	candidates := make([]*markup.Node, 0, len(scores))
+	for n := range scores {
+		candidates = append(candidates, n)
+	}
+	if len(candidates) == 0 {
+		if body.Type == markup.ElementNode {
+			return body
+		}
+		return nil
+	}
+	// Break ties by document order so extraction is deterministic.
+	order := documentOrder(body)
+	sort.Slice(candidates, func(i, j int) bool {
+		if scores[candidates[i]] != scores[candidates[j]] {
+			return scores[candidates[i]] > scores[candidates[j]]
+		}
+		return order[candidates[i]] < order[candidates[j]]
+	})
+	return candidates[0]
+}
+
+func documentOrder(root *markup.Node) map[*markup.Node]int {
+	order := make(map[*markup.Node]int)
+	root.Walk(func(n *markup.Node) bool {
+		order[n] = len(order)
+		return true
+	})
+	return order
+}
+
+// related returns top together with the siblings that look like part of
+// the same article: well scored elements and link-poor paragraphs.
+func related(top *markup.Node, scores map[*markup.Node]float64) []*markup.Node {
+	if top.Parent == nil || top.Data == "body" {
+		return []*markup.Node{top}
+	}
+	threshold := max(10, scores[top]*0.2)
+	var out []*markup.Node
+	for _, sibling := range append([]*markup.Node(nil), top.Parent.Children...) {
+		if sibling == top {
+			out = append(out, sibling)
+			continue
+		}
+		if sibling.Type != markup.ElementNode {
+			continue
+		}
+		if score, ok := scores[sibling]; ok && score >= threshold {
+			out = append(out, sibling)
+			continue
+		}
+		if sibling.Data == "p" {
+			text := strings.TrimSpace(sibling.Text())
+			length := utf8.RuneCountInString(text)
+			density := linkDensity(sibling)
+			if length > 80 && density < 0.25 || length > 0 && length <= 80 && density == 0 && strings.HasSuffix(text, ".") {
+				out = append(out, sibling)
+			}
+		}
+	}
+	for _, n := range out {
+		n.Remove()
+	}
+	return out
+}
+
+// absolutize resolves relative href and src attributes against base.
+func absolutize(root *markup.Node, base *url.URL) {
+	root.Walk(func(n *markup.Node) bool {
+		for _, key := range []string{"href", "src"} {
+			v, ok := n.GetAttr(key)
+			if !ok {
+				continue
+			}
+			if u, err := url.Parse(strings.TrimSpace(v)); err == nil {
+				n.SetAttr(key, base.ResolveReference(u).String())
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,73 @@
+package readability
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jjboykin/gator/internal/plaintext"
+)
+
+// Each testdata/<name>.html page is extracted and compared, rendered as
+// text, with testdata/<name>.txt: the title, a blank line and the text.
+func TestExtract(t *testing.T) {
+	base, _ := url.Parse("https://example.com/2024/post")
+
+	for _, name := range []string{"blog", "news", "divs"} {
+		t.Run(name, func(t *testing.T) {
+			page, err := os.ReadFile(filepath.Join("testdata", name+".html"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(filepath.Join("testdata", name+".txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			article, err := Extract(string(page), base)
+			if err != nil {
+				t.Fatalf("Extract: %v", err)
+			}
+			got := article.Title + "\n\n" + plaintext.Render(article.Content, 0) + "\n"
+			if got != string(want) {
+				t.Errorf("extracted text differs\n got:\n%s\nwant:\n%s", got, want)
+			}
+			if article.TextLength < minTextLength {
+				t.Errorf("TextLength = %d", article.TextLength)
+			}
+		})
+	}
+}
+
+func TestExtractRemovesBoilerplate(t *testing.T) {
+	page, err := os.ReadFile(filepath.Join("testdata", "blog.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	article, err := Extract(string(page), nil)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	for _, boilerplate := range []string{"<script", "Popular posts", "Tweet", "newsletter", "3 comments", "Copyright"} {
+		if strings.Contains(article.Content, boilerplate) {
+			t.Errorf("content contains %q", boilerplate)
+		}
+	}
+}
+
+func TestExtractNoContent(t *testing.T) {
+	page, err := os.ReadFile(filepath.Join("testdata", "short.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	article, err := Extract(string(page), nil)
+	if !errors.Is(err, ErrNoContent) {
+		t.Fatalf("Extract error = %v, want ErrNoContent", err)
+	}
+	if article.Title != "Page not found" {
+		t.Errorf("Title = %q", article.Title)
+	}
+}
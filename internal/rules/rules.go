@@ -0,0 +1,243 @@
+// Package rules matches posts against user-defined filters such as "title
+// contains webinar" and says what to do with the ones that match. Rules
+// are written and stored as short strings so that they can be typed on
+// the command line:
+//
+//	title:webinar          title contains "webinar", ignoring case
+//	description:/CVE-\d+/  description matches the regular expression
+//	author:/^jane/i        a trailing "i" makes the expression ignore case
+//
+// and actions as "read", "star", "hide", "notify" or "tag:<name>".
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Fields a condition can look at. "feed" matches the feed's name or URL,
+// "category" any of the post's categories.
+var Fields = []string{"feed", "title", "description", "author", "category"}
+
+// Action kinds. Bookmark is accepted as another name for star.
+const (
+	ActionRead   = "read"
+	ActionStar   = "star"
+	ActionHide   = "hide"
+	ActionNotify = "notify"
+	ActionTag    = "tag"
+)
+
+// Post is what conditions are evaluated against.
+type Post struct {
+	FeedName    string
+	FeedURL     string
+	Title       string
+	Description string
+	Author      string
+	Categories  []string
+}
+
+// Pattern is text to look for, ignoring case, or a regular expression
+// written between slashes.
+type Pattern struct {
+	Text string
+	// re is nil for a substring match.
+	re *regexp.Regexp
+}
+
+// ParsePattern parses "text" or "/regexp/", optionally followed by "i" to
+// make the expression ignore case.
+func ParsePattern(s string) (Pattern, error) {
+	if s == "" {
+		return Pattern{}, errors.New("empty pattern")
+	}
+	p := Pattern{Text: s}
+	expr := ""
+	switch {
+	case len(s) > 2 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/"):
+		expr = s[1 : len(s)-1]
+	case len(s) > 3 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/i"):
+		expr = "(?i)" + s[1:len(s)-2]
+	default:
+		return p, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return Pattern{}, err
+	}
+	p.re = re
+	return p, nil
+}
+
+func (p Pattern) String() string {
+	return p.Text
+}
+
+// Match reports whether value contains the pattern.
+func (p Pattern) Match(value string) bool {
+	if p.re != nil {
+		return p.re.MatchString(value)
+	}
+	return strings.Contains(strings.ToLower(value), strings.ToLower(p.Text))
+}
+
+// Condition tests one field of a post.
+type Condition struct {
+	Field   string
+	Pattern Pattern
+}
+
+// ParseCondition parses "field:pattern"; see ParsePattern.
+func ParseCondition(s string) (Condition, error) {
+	field, text, ok := strings.Cut(s, ":")
+	field = strings.ToLower(strings.TrimSpace(field))
+	if !ok || text == "" {
+		return Condition{}, fmt.Errorf("invalid condition %q: want field:text or field:/regexp/", s)
+	}
+	if !isField(field) {
+		return Condition{}, fmt.Errorf("invalid condition %q: unknown field %s (want one of %s)", s, field, strings.Join(Fields, ", "))
+	}
+	pattern, err := ParsePattern(text)
+	if err != nil {
+		return Condition{}, fmt.Errorf("invalid condition %q: %w", s, err)
+	}
+	return Condition{Field: field, Pattern: pattern}, nil
+}
+
+func isField(field string) bool {
+	for _, f := range Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Condition) String() string {
+	return c.Field + ":" + c.Pattern.String()
+}
+
+// Match reports whether the condition holds for p.
+func (c Condition) Match(p Post) bool {
+	var values []string
+	switch c.Field {
+	case "feed":
+		values = []string{p.FeedName, p.FeedURL}
+	case "title":
+		values = []string{p.Title}
+	case "description":
+		values = []string{p.Description}
+	case "author":
+		values = []string{p.Author}
+	case "category":
+		values = p.Categories
+	}
+	for _, value := range values {
+		if c.Pattern.Match(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Action is something done to a matching post. Arg is the tag name for
+// ActionTag and empty otherwise.
+type Action struct {
+	Kind string
+	Arg  string
+}
+
+// ParseAction parses "read", "star" (or "bookmark"), "hide", "notify" or
+// "tag:<name>".
+func ParseAction(s string) (Action, error) {
+	kind, arg, _ := strings.Cut(strings.TrimSpace(s), ":")
+	kind = strings.ToLower(kind)
+	switch kind {
+	case ActionRead, ActionStar, ActionHide, ActionNotify:
+		if arg != "" {
+			return Action{}, fmt.Errorf("invalid action %q: %s takes no argument", s, kind)
+		}
+		return Action{Kind: kind}, nil
+	case "bookmark":
+		return ParseAction(ActionStar)
+	case ActionTag:
+		if strings.TrimSpace(arg) == "" {
+			return Action{}, fmt.Errorf("invalid action %q: want tag:<name>", s)
+		}
+		return Action{Kind: kind, Arg: strings.TrimSpace(arg)}, nil
+	default:
+		return Action{}, fmt.Errorf("invalid action %q: want read, star, hide, notify or tag:<name>", s)
+	}
+}
+
+func (a Action) String() string {
+	if a.Arg != "" {
+		return a.Kind + ":" + a.Arg
+	}
+	return a.Kind
+}
+
+// Rule applies its actions to posts that meet all of its conditions.
+type Rule struct {
+	Name       string
+	Conditions []Condition
+	Actions    []Action
+}
+
+// Parse builds a rule from the string forms of its conditions and actions.
+func Parse(name string, conditions, actions []string) (Rule, error) {
+	if len(conditions) == 0 {
+		return Rule{}, errors.New("a rule needs at least one condition")
+	}
+	if len(actions) == 0 {
+		return Rule{}, errors.New("a rule needs at least one action")
+	}
+	rule := Rule{Name: name}
+	for _, s := range conditions {
+		c, err := ParseCondition(s)
+		if err != nil {
+			return Rule{}, err
+		}
+		rule.Conditions = append(rule.Conditions, c)
+	}
+	for _, s := range actions {
+		a, err := ParseAction(s)
+		if err != nil {
+			return Rule{}, err
+		}
+		rule.Actions = append(rule.Actions, a)
+	}
+	return rule, nil
+}
+
+// Match reports whether every condition of the rule holds for p.
+func (r Rule) Match(p Post) bool {
+	for _, c := range r.Conditions {
+		if !c.Match(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// ConditionStrings returns the conditions in the form Parse accepts, for
+// storing.
+func (r Rule) ConditionStrings() []string {
+	out := make([]string, len(r.Conditions))
+	for i, c := range r.Conditions {
+		out[i] = c.String()
+	}
+	return out
+}
+
+// ActionStrings returns the actions in the form Parse accepts, for storing.
+func (r Rule) ActionStrings() []string {
+	out := make([]string, len(r.Actions))
+	for i, a := range r.Actions {
+		out[i] = a.String()
+	}
+	return out
+}
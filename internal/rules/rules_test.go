@@ -0,0 +1,155 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+var testPost = Post{
+	FeedName:    "Hacker News",
+	FeedURL:     "https://news.ycombinator.com/rss",
+	Title:       "Free Webinar: Scaling Postgres",
+	Description: "Fixes CVE-2024-1234 in the parser.",
+	Author:      "Jane Doe",
+	Categories:  []string{"Databases", "Events"},
+}
+
+func TestConditionMatch(t *testing.T) {
+	for _, tt := range []struct {
+		condition string
+		want      bool
+	}{
+		// Each field.
+		{"feed:hacker news", true},
+		{"feed:ycombinator.com", true},
+		{"feed:lobsters", false},
+		{"title:webinar", true},
+		{"title:podcast", false},
+		{"description:CVE-", true},
+		{"description:webinar", false},
+		{"author:jane", true},
+		{"author:john", false},
+		{"category:events", true},
+		{"category:sports", false},
+
+		// Substrings ignore case, and so does the field name.
+		{"title:WEBINAR", true},
+		{"Title:webinar", true},
+		{" AUTHOR :doe", true},
+
+		// Expressions are case-sensitive unless they end in /i.
+		{`description:/CVE-\d+/`, true},
+		{`description:/cve-\d+/`, false},
+		{`description:/cve-\d+/i`, true},
+		{"author:/^jane/", false},
+		{"author:/^jane/i", true},
+		{"title:/^Free/", true},
+		{"category:/^Data/", true},
+		{"category:/^Events$/", true},
+		{"category:/^Event$/", false},
+
+		// A slash on its own is text.
+		{"title:/", false},
+		{"feed:/rss", true},
+	} {
+		c, err := ParseCondition(tt.condition)
+		if err != nil {
+			t.Errorf("ParseCondition(%q): %v", tt.condition, err)
+			continue
+		}
+		if got := c.Match(testPost); got != tt.want {
+			t.Errorf("%q matches %v, want %v", tt.condition, got, tt.want)
+		}
+	}
+}
+
+func TestParseConditionInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		condition string
+		err       string
+	}{
+		{"webinar", "want field:text"},
+		{"title:", "want field:text"},
+		{"body:webinar", "unknown field body"},
+		{"title:/[a-/", "invalid condition"},
+		{"title:/(?P<x/i", "invalid condition"},
+	} {
+		_, err := ParseCondition(tt.condition)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("ParseCondition(%q) = %v, want an error with %q", tt.condition, err, tt.err)
+		}
+	}
+	if _, err := ParsePattern(""); err == nil {
+		t.Error("ParsePattern accepted an empty pattern")
+	}
+}
+
+func TestParseAction(t *testing.T) {
+	for _, tt := range []struct {
+		action string
+		want   Action
+	}{
+		{"read", Action{Kind: ActionRead}},
+		{"star", Action{Kind: ActionStar}},
+		{"bookmark", Action{Kind: ActionStar}},
+		{"hide", Action{Kind: ActionHide}},
+		{"notify", Action{Kind: ActionNotify}},
+		{" READ ", Action{Kind: ActionRead}},
+		{"tag:later", Action{Kind: ActionTag, Arg: "later"}},
+		{"Tag: Later ", Action{Kind: ActionTag, Arg: "Later"}},
+	} {
+		got, err := ParseAction(tt.action)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseAction(%q) = %+v, %v, want %+v", tt.action, got, err, tt.want)
+		}
+	}
+
+	for _, action := range []string{"", "delete", "read:now", "tag", "tag: ", "notify:me"} {
+		if got, err := ParseAction(action); err == nil {
+			t.Errorf("ParseAction(%q) = %+v, want an error", action, got)
+		}
+	}
+}
+
+func TestRule(t *testing.T) {
+	rule, err := Parse("webinars", []string{"title:webinar", "feed:/news/"}, []string{"read", "tag:events"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rule.Match(testPost) {
+		t.Error("rule doesn't match a post meeting both conditions")
+	}
+	other := testPost
+	other.FeedName, other.FeedURL = "Lobsters", "https://lobste.rs/rss"
+	if rule.Match(other) {
+		t.Error("rule matches a post meeting only one condition")
+	}
+
+	// The stored strings parse back to the same rule.
+	again, err := Parse(rule.Name, rule.ConditionStrings(), rule.ActionStrings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(again.ConditionStrings(), " "), "title:webinar feed:/news/"; got != want {
+		t.Errorf("conditions = %q, want %q", got, want)
+	}
+	if got, want := strings.Join(again.ActionStrings(), " "), "read tag:events"; got != want {
+		t.Errorf("actions = %q, want %q", got, want)
+	}
+	if !again.Match(testPost) || again.Match(other) {
+		t.Error("the parsed-back rule matches differently")
+	}
+
+	for _, tt := range []struct {
+		conditions, actions []string
+	}{
+		{nil, []string{"read"}},
+		{[]string{"title:x"}, nil},
+		{[]string{"title:x", "nope"}, []string{"read"}},
+		{[]string{"title:x"}, []string{"read", "nope"}},
+	} {
+		if _, err := Parse("bad", tt.conditions, tt.actions); err == nil {
+			t.Errorf("Parse(%q, %q) accepted an invalid rule", tt.conditions, tt.actions)
+		}
+	}
+}
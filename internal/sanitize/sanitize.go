@@ -0,0 +1,240 @@
+// Package sanitize cleans untrusted HTML from feeds with an allowlist of
+// elements and attributes, so that post bodies are safe to display.
+package sanitize
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jjboykin/gator/internal/markup"
+)
+
+// allowedElements maps each permitted element to its permitted attributes,
+// in addition to globalAttrs. Elements not listed are unwrapped: the tag is
+// dropped but its content kept.
+var allowedElements = map[string][]string{
+	"a":          {"href"},
+	"abbr":       nil,
+	"audio":      {"src", "controls"},
+	"b":          nil,
+	"blockquote": {"cite"},
+	"br":         nil,
+	"caption":    nil,
+	"cite":       nil,
+	"code":       nil,
+	"dd":         nil,
+	"del":        {"cite", "datetime"},
+	"details":    nil,
+	"dfn":        nil,
+	"div":        nil,
+	"dl":         nil,
+	"dt":         nil,
+	"em":         nil,
+	"figcaption": nil,
+	"figure":     nil,
+	"h1":         nil,
+	"h2":         nil,
+	"h3":         nil,
+	"h4":         nil,
+	"h5":         nil,
+	"h6":         nil,
+	"hr":         nil,
+	"i":          nil,
+	"img":        {"src", "alt", "width", "height"},
+	"ins":        {"cite", "datetime"},
+	"kbd":        nil,
+	"li":         nil,
+	"mark":       nil,
+	"ol":         {"start", "reversed"},
+	"p":          nil,
+	"picture":    nil,
+	"pre":        nil,
+	"q":          {"cite"},
+	"s":          nil,
+	"samp":       nil,
+	"small":      nil,
+	"source":     {"src", "type"},
+	"span":       nil,
+	"strike":     nil,
+	"strong":     nil,
+	"sub":        nil,
+	"summary":    nil,
+	"sup":        nil,
+	"table":      nil,
+	"tbody":      nil,
+	"td":         {"colspan", "rowspan"},
+	"tfoot":      nil,
+	"th":         {"colspan", "rowspan", "scope"},
+	"thead":      nil,
+	"time":       {"datetime"},
+	"tr":         nil,
+	"u":          nil,
+	"ul":         nil,
+	"var":        nil,
+	"video":      {"src", "controls", "poster", "width", "height"},
+}
+
+var globalAttrs = []string{"title", "lang", "dir"}
+
+// droppedElements are removed together with their content.
+var droppedElements = map[string]bool{
+	"applet": true, "base": true, "button": true, "embed": true,
+	"form": true, "frame": true, "frameset": true, "head": true,
+	"iframe": true, "input": true, "link": true, "math": true,
+	"meta": true, "noembed": true, "noscript": true, "object": true,
+	"script": true, "select": true, "style": true, "svg": true,
+	"template": true, "textarea": true, "title": true,
+}
+
+// urlAttrs hold URLs, which are resolved and scheme-checked.
+var urlAttrs = map[string]bool{
+	"href": true, "src": true, "cite": true, "poster": true,
+}
+
+// trackerHosts serve the invisible images feeds use to count readers.
+var trackerHosts = []string{
+	"feeds.feedburner.com",
+	"feedads.g.doubleclick.net",
+	"pixel.wp.com",
+	"stats.wordpress.com",
+	"www.google-analytics.com",
+	"pixel.quantserve.com",
+	"feeds.wordpress.com",
+	"pi.feedsportal.com",
+}
+
+// HTML returns a sanitized copy of the HTML fragment s. Relative URLs are
+// resolved against base, which may be nil. Scripts, styles, embedded
+// objects, event handlers, non-http(s) URLs and tracking pixels are
+// removed; links are marked rel="nofollow noopener noreferrer".
+func HTML(s string, base *url.URL) string {
+	doc := markup.Parse(s)
+	clean(doc, base)
+	return strings.TrimSpace(doc.String())
+}
+
+func clean(n *markup.Node, base *url.URL) {
+	var children []*markup.Node
+	for _, c := range n.Children {
+		children = append(children, cleanNode(c, base)...)
+	}
+	n.Children = nil
+	for _, c := range children {
+		n.AppendChild(c)
+	}
+}
+
+// cleanNode returns the nodes that replace n in the sanitized tree.
+func cleanNode(n *markup.Node, base *url.URL) []*markup.Node {
+	switch n.Type {
+	case markup.TextNode:
+		return []*markup.Node{n}
+	case markup.ElementNode:
+	default:
+		return nil
+	}
+
+	if droppedElements[n.Data] {
+		return nil
+	}
+	allowed, ok := allowedElements[n.Data]
+	if !ok {
+		clean(n, base)
+		return n.Children
+	}
+
+	var attrs []markup.Attr
+	for _, a := range n.Attr {
+		if !contains(allowed, a.Key) && !contains(globalAttrs, a.Key) {
+			continue
+		}
+		if urlAttrs[a.Key] {
+			resolved, ok := safeURL(a.Val, base, n.Data == "a")
+			if !ok {
+				continue
+			}
+			a.Val = resolved
+		}
+		attrs = append(attrs, a)
+	}
+	n.Attr = attrs
+
+	switch n.Data {
+	case "img":
+		if n.AttrVal("src") == "" || isTrackingPixel(n) {
+			return nil
+		}
+	case "a":
+		if n.AttrVal("href") != "" {
+			n.SetAttr("rel", "nofollow noopener noreferrer")
+		}
+	}
+
+	clean(n, base)
+	return []*markup.Node{n}
+}
+
+// safeURL resolves raw against base and reports whether the result may be
+// kept. Only http and https are allowed, plus mailto for links.
+func safeURL(raw string, base *url.URL, link bool) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", false
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+	case "mailto":
+		if !link {
+			return "", false
+		}
+	case "":
+		// Still relative: only possible without a base. A relative
+		// reference can't carry a scheme, so it's harmless.
+		if base != nil {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+	return u.String(), true
+}
+
+func isTrackingPixel(img *markup.Node) bool {
+	w, werr := strconv.Atoi(strings.TrimSuffix(img.AttrVal("width"), "px"))
+	h, herr := strconv.Atoi(strings.TrimSuffix(img.AttrVal("height"), "px"))
+	if werr == nil && herr == nil && w <= 1 && h <= 1 {
+		return true
+	}
+	u, err := url.Parse(img.AttrVal("src"))
+	if err != nil {
+		return true
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, tracker := range trackerHosts {
+		if host == tracker {
+			// Feedburner also hosts real images; only its ~r/ paths count.
+			if tracker == "feeds.feedburner.com" && !strings.Contains(u.Path, "/~r/") {
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
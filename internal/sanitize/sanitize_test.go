@@ -0,0 +1,101 @@
+package sanitize
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHTML(t *testing.T) {
+	base, _ := url.Parse("https://example.com/posts/1")
+
+	for _, tt := range []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain text", "hello", "hello"},
+		{"allowed markup", "<p>a <em>b</em></p>", "<p>a <em>b</em></p>"},
+		{"unknown element unwrapped", "<custom>text</custom>", "text"},
+		{"script", `<p>a</p><script>alert(1)</script>`, "<p>a</p>"},
+		{"script uppercase", `<SCRIPT>alert(1)</SCRIPT>b`, "b"},
+		{"script split end tag", `<script>a("</scr"+"ipt>")</script>b`, "b"},
+		{"style", `<style>body{}</style>b`, "b"},
+		{"style with runes that change length when lowercased", "<p>x</p><style>ȺȺȺȺȺȺȺȺȺȺ</style>", "<p>x</p>"},
+		{"style with dotted capital I", "<style>İİİİ</style><p>x</p>", "<p>x</p>"},
+		{"iframe", `<iframe src="https://evil.example/"></iframe>b`, "b"},
+		{"svg onload", `<svg onload="alert(1)"><circle/></svg>b`, "b"},
+		{"math", `<math><mi xlink:href="javascript:alert(1)">x</mi></math>b`, "b"},
+		{"object", `<object data="x.swf"></object>b`, "b"},
+		{"embed", `<embed src="x.swf">b`, "b"},
+		{"form", `<form action="https://evil.example/"><input name="p"></form>b`, "b"},
+		{"meta refresh", `<meta http-equiv="refresh" content="0;url=javascript:alert(1)">b`, "b"},
+		{"base", `<base href="https://evil.example/">b`, "b"},
+		{"event handler", `<img src="a.png" onerror="alert(1)">`, `<img src="https://example.com/posts/a.png">`},
+		{"event handler unquoted", `<b onmouseover=alert(1)>x</b>`, "<b>x</b>"},
+		{"style attribute", `<p style="background:url(javascript:alert(1))">x</p>`, "<p>x</p>"},
+		{"javascript href", `<a href="javascript:alert(1)">x</a>`, "<a>x</a>"},
+		{"javascript href mixed case", `<a href="JaVaScRiPt:alert(1)">x</a>`, "<a>x</a>"},
+		{"javascript href leading space", `<a href="  javascript:alert(1)">x</a>`, "<a>x</a>"},
+		{"javascript href entity", `<a href="&#106;avascript:alert(1)">x</a>`, "<a>x</a>"},
+		{"javascript href hex entity", `<a href="&#x6A;avascript:alert(1)">x</a>`, "<a>x</a>"},
+		{"javascript href tab", "<a href=\"java\tscript:alert(1)\">x</a>", "<a>x</a>"},
+		{"javascript href newline entity", `<a href="java&#10;script:alert(1)">x</a>`, "<a>x</a>"},
+		{"vbscript href", `<a href="vbscript:msgbox(1)">x</a>`, "<a>x</a>"},
+		{"data href", `<a href="data:text/html;base64,PHNjcmlwdD4=">x</a>`, "<a>x</a>"},
+		{"data img", `<img src="data:image/svg+xml,<svg onload=alert(1)>">`, ""},
+		{"mailto link", `<a href="mailto:a@example.com">x</a>`, `<a href="mailto:a@example.com" rel="nofollow noopener noreferrer">x</a>`},
+		{"mailto img", `<img src="mailto:a@example.com">`, ""},
+		{"relative link", `<a href="/about">x</a>`, `<a href="https://example.com/about" rel="nofollow noopener noreferrer">x</a>`},
+		{"rel replaced", `<a href="https://a.example/" rel="opener">x</a>`, `<a href="https://a.example/" rel="nofollow noopener noreferrer">x</a>`},
+		{"target dropped", `<a href="https://a.example/" target="_blank">x</a>`, `<a href="https://a.example/" rel="nofollow noopener noreferrer">x</a>`},
+		{"attribute breakout", `<img src="a.png" alt='"><script>alert(1)</script>'>`, `<img src="https://example.com/posts/a.png" alt="&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;">`},
+		{"text escaped", `&lt;script&gt;alert(1)&lt;/script&gt;`, `&lt;script&gt;alert(1)&lt;/script&gt;`},
+		{"comment dropped", `<!-- <script>alert(1)</script> -->b`, "b"},
+		{"conditional comment", `<!--[if IE]><script>alert(1)</script><![endif]-->b`, "b"},
+		{"cdata", `<![CDATA[<script>alert(1)</script>]]>`, `&lt;script&gt;alert(1)&lt;/script&gt;`},
+		{"unterminated tag", `<img src="a.png" onerror="alert(1)"`, `<img src="https://example.com/posts/a.png">`},
+		{"tracking pixel", `<img src="https://example.com/p.gif" width="1" height="1">`, ""},
+		{"feedburner pixel", `<img src="https://feeds.feedburner.com/~r/x/~4/abc">`, ""},
+		{"video poster", `<video src="v.mp4" poster="javascript:alert(1)"></video>`, `<video src="https://example.com/posts/v.mp4"></video>`},
+		{"blockquote cite", `<blockquote cite="javascript:alert(1)">q</blockquote>`, "<blockquote>q</blockquote>"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTML(tt.in, base); got != tt.want {
+				t.Errorf("HTML(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTMLWithoutBase(t *testing.T) {
+	got := HTML(`<a href="/about">x</a><img src="javascript:alert(1)">`, nil)
+	want := `<a href="/about" rel="nofollow noopener noreferrer">x</a>`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestHTMLNoActiveContent runs a few payloads that try to confuse the parser
+// and checks that nothing able to run script survives.
+func TestHTMLNoActiveContent(t *testing.T) {
+	for _, in := range []string{
+		`<scr<script>ipt>alert(1)</script>`,
+		`<<script>script>alert(1)<</script>/script>`,
+		`<img/src="x"/onerror="alert(1)">`,
+		`<img src=x onerror=alert(1)//`,
+		`<a href="javas&#99;ript:alert(1)">x</a>`,
+		`<a href=" &#14; javascript:alert(1)">x</a>`,
+		`<div><iframe srcdoc="<script>alert(1)</script>"></iframe></div>`,
+		`<textarea></textarea><script>alert(1)</script>`,
+		`<title></title><img src=x onerror=alert(1)>`,
+		`<noscript><p title="</noscript><img src=x onerror=alert(1)>">`,
+	} {
+		got := strings.ToLower(HTML(in, nil))
+		for _, bad := range []string{"<script", "onerror", "javascript:", "<iframe", "srcdoc"} {
+			if strings.Contains(got, bad) {
+				t.Errorf("HTML(%q) = %q, contains %q", in, got, bad)
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Package schedule decides how often a feed should be polled, based on
+// the hints it publishes and how often it actually posts.
+package schedule
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Hints are the polling hints a feed can publish.
+type Hints struct {
+	// TTL is the RSS <ttl>: how long the feed may be cached.
+	TTL time.Duration
+	// SkipHours are the RSS <skipHours>, in GMT.
+	SkipHours []int
+	// SkipDays are the RSS <skipDays>.
+	SkipDays []time.Weekday
+	// UpdatePeriod is derived from sy:updatePeriod and sy:updateFrequency.
+	UpdatePeriod time.Duration
+}
+
+// Bounds limit the adaptive interval.
+type Bounds struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// maxSamples is how many of the most recent posting gaps are considered.
+const maxSamples = 10
+
+// Interval picks the polling interval for a feed from the publish times of
+// its current items, its hints and the interval used so far. The result
+// moves halfway from previous towards the new target on each call so that
+// one unusual fetch doesn't swing the schedule.
+func Interval(published []time.Time, hints Hints, bounds Bounds, previous time.Duration) time.Duration {
+	target := observedGap(published) / 2
+	if target == 0 {
+		target = hints.UpdatePeriod
+	}
+	if target == 0 {
+		target = previous
+	}
+
+	interval := target
+	if previous > 0 {
+		interval = (previous + target) / 2
+	}
+
+	// The feed asked not to be re-fetched within its TTL.
+	if hints.TTL > interval {
+		interval = hints.TTL
+	}
+	return clamp(interval, bounds)
+}
+
+// Next returns the next fetch time after now, skipping the hours and days
+// the feed asked not to be polled in.
+func Next(now time.Time, interval time.Duration, hints Hints) time.Time {
+	next := now.Add(interval)
+	// A week of hourly steps covers every combination of skip hints.
+	for i := 0; i < 7*24 && skipped(next, hints); i++ {
+		next = next.UTC().Truncate(time.Hour).Add(time.Hour)
+	}
+	return next
+}
+
+// UpdatePeriod converts the syndication module's sy:updatePeriod and
+// sy:updateFrequency into a duration. It returns 0 for unknown periods.
+func UpdatePeriod(period string, frequency int) time.Duration {
+	var d time.Duration
+	switch strings.ToLower(strings.TrimSpace(period)) {
+	case "hourly":
+		d = time.Hour
+	case "daily":
+		d = 24 * time.Hour
+	case "weekly":
+		d = 7 * 24 * time.Hour
+	case "monthly":
+		d = 30 * 24 * time.Hour
+	case "yearly":
+		d = 365 * 24 * time.Hour
+	default:
+		return 0
+	}
+	if frequency > 1 {
+		d /= time.Duration(frequency)
+	}
+	return d
+}
+
+// ParseWeekday parses an RSS <skipDays> day name.
+func ParseWeekday(name string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(strings.TrimSpace(name), d.String()) {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// observedGap is the median gap between consecutive posts, or 0 when there
+// aren't enough dated posts to tell.
+func observedGap(published []time.Time) time.Duration {
+	var times []time.Time
+	for _, t := range published {
+		if !t.IsZero() {
+			times = append(times, t)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].After(times[j]) })
+
+	var gaps []time.Duration
+	for i := 1; i < len(times) && len(gaps) < maxSamples; i++ {
+		if gap := times[i-1].Sub(times[i]); gap > 0 {
+			gaps = append(gaps, gap)
+		}
+	}
+	if len(gaps) == 0 {
+		return 0
+	}
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+	return gaps[len(gaps)/2]
+}
+
+func skipped(t time.Time, hints Hints) bool {
+	t = t.UTC()
+	for _, h := range hints.SkipHours {
+		if t.Hour() == h%24 {
+			return true
+		}
+	}
+	for _, d := range hints.SkipDays {
+		if t.Weekday() == d {
+			return true
+		}
+	}
+	return false
+}
+
+func clamp(d time.Duration, bounds Bounds) time.Duration {
+	if bounds.Min > 0 && d < bounds.Min {
+		return bounds.Min
+	}
+	if bounds.Max > 0 && d > bounds.Max {
+		return bounds.Max
+	}
+	return d
+}
@@ -0,0 +1,152 @@
+// Package websub implements the subscriber side of WebSub
+// (https://www.w3.org/TR/websub/): subscription requests to hubs and
+// validation of content distribution signatures.
+package websub
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request is a subscription or unsubscription request sent to a hub.
+type Request struct {
+	Hub      string
+	Topic    string
+	Callback string
+	Secret   string
+	Lease    time.Duration
+}
+
+// Subscribe asks the hub to start delivering updates for the topic. The
+// hub confirms asynchronously by calling the callback with a challenge.
+func Subscribe(ctx context.Context, client *http.Client, r Request) error {
+	return send(ctx, client, "subscribe", r)
+}
+
+// Unsubscribe asks the hub to stop delivering updates for the topic.
+func Unsubscribe(ctx context.Context, client *http.Client, r Request) error {
+	return send(ctx, client, "unsubscribe", r)
+}
+
+func send(ctx context.Context, client *http.Client, mode string, r Request) error {
+	form := url.Values{
+		"hub.mode":     {mode},
+		"hub.topic":    {r.Topic},
+		"hub.callback": {r.Callback},
+	}
+	if r.Secret != "" {
+		form.Set("hub.secret", r.Secret)
+	}
+	if r.Lease > 0 {
+		form.Set("hub.lease_seconds", strconv.Itoa(int(r.Lease/time.Second)))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", r.Hub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("hub returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Verification is the intent verification (or denial) a hub sends to the
+// callback with a GET request.
+type Verification struct {
+	Mode      string
+	Topic     string
+	Challenge string
+	Lease     time.Duration
+	Reason    string
+}
+
+// ParseVerification reads the hub.* query parameters of a callback GET.
+func ParseVerification(query url.Values) (Verification, error) {
+	v := Verification{
+		Mode:      query.Get("hub.mode"),
+		Topic:     query.Get("hub.topic"),
+		Challenge: query.Get("hub.challenge"),
+		Reason:    query.Get("hub.reason"),
+	}
+	switch v.Mode {
+	case "subscribe", "unsubscribe":
+		if v.Challenge == "" {
+			return v, fmt.Errorf("missing hub.challenge")
+		}
+	case "denied":
+	default:
+		return v, fmt.Errorf("unknown hub.mode %q", v.Mode)
+	}
+	if v.Topic == "" {
+		return v, fmt.Errorf("missing hub.topic")
+	}
+	if lease := query.Get("hub.lease_seconds"); lease != "" {
+		seconds, err := strconv.Atoi(lease)
+		if err != nil {
+			return v, fmt.Errorf("invalid hub.lease_seconds: %w", err)
+		}
+		v.Lease = time.Duration(seconds) * time.Second
+	}
+	return v, nil
+}
+
+// VerifySignature checks an X-Hub-Signature header ("sha256=<hex>") against
+// the HMAC of body keyed with secret.
+func VerifySignature(header string, body []byte, secret string) bool {
+	method, signature, ok := strings.Cut(header, "=")
+	if !ok {
+		return false
+	}
+
+	var h func() hash.Hash
+	switch strings.ToLower(method) {
+	case "sha1":
+		h = sha1.New
+	case "sha256":
+		h = sha256.New
+	case "sha384":
+		h = sha512.New384
+	case "sha512":
+		h = sha512.New
+	default:
+		return false
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// NewSecret returns a random secret for a subscription.
+func NewSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,219 @@
+package websub
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// hub is a stand-in hub that verifies every subscription request with the
+// callback and then delivers one signed update.
+type hub struct {
+	t       *testing.T
+	content []byte
+	// tamper, if set, changes the body after it was signed.
+	tamper bool
+
+	verified chan bool
+}
+
+func (h *hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.Form.Get("hub.mode") != "subscribe" {
+		http.Error(w, "unexpected mode", http.StatusBadRequest)
+		return
+	}
+	if r.Form.Get("hub.lease_seconds") != "3600" {
+		h.t.Errorf("hub.lease_seconds = %q", r.Form.Get("hub.lease_seconds"))
+	}
+	w.WriteHeader(http.StatusAccepted)
+
+	// Verification and delivery happen after the request is answered.
+	form := r.Form
+	go func() {
+		challenge := NewSecret()
+		query := url.Values{
+			"hub.mode":          {"subscribe"},
+			"hub.topic":         {form.Get("hub.topic")},
+			"hub.challenge":     {challenge},
+			"hub.lease_seconds": {"3600"},
+		}
+		resp, err := http.Get(form.Get("hub.callback") + "?" + query.Encode())
+		if err != nil {
+			h.t.Errorf("verification: %v", err)
+			h.verified <- false
+			return
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		ok := resp.StatusCode == http.StatusOK && string(body) == challenge
+		h.verified <- ok
+		if !ok {
+			return
+		}
+
+		mac := hmac.New(sha256.New, []byte(form.Get("hub.secret")))
+		mac.Write(h.content)
+		content := h.content
+		if h.tamper {
+			content = append([]byte("<!-- -->"), content...)
+		}
+		req, _ := http.NewRequest("POST", form.Get("hub.callback"), bytes.NewReader(content))
+		req.Header.Set("Content-Type", "application/atom+xml")
+		req.Header.Set("X-Hub-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		resp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			h.t.Errorf("delivery: %v", err)
+			return
+		}
+		resp.Body.Close()
+	}()
+}
+
+// subscriber is a callback that accepts verifications for topic and
+// reports whether each delivery carried a valid signature.
+type subscriber struct {
+	topic     string
+	secret    string
+	delivered chan bool
+}
+
+func (s *subscriber) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		v, err := ParseVerification(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if v.Mode != "subscribe" || v.Topic != s.topic || v.Lease != time.Hour {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(v.Challenge))
+	case "POST":
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+		s.delivered <- VerifySignature(r.Header.Get("X-Hub-Signature"), body, s.secret)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		tamper bool
+	}{
+		{"valid signature", false},
+		{"tampered body", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &hub{t: t, content: []byte("<feed/>"), tamper: tt.tamper, verified: make(chan bool, 1)}
+			hubServer := httptest.NewServer(h)
+			defer hubServer.Close()
+			sub := &subscriber{topic: "https://example.com/feed", secret: NewSecret(), delivered: make(chan bool, 1)}
+			callback := httptest.NewServer(sub)
+			defer callback.Close()
+
+			err := Subscribe(context.Background(), hubServer.Client(), Request{
+				Hub:      hubServer.URL,
+				Topic:    sub.topic,
+				Callback: callback.URL + "/websub/1",
+				Secret:   sub.secret,
+				Lease:    time.Hour,
+			})
+			if err != nil {
+				t.Fatalf("Subscribe: %v", err)
+			}
+
+			select {
+			case ok := <-h.verified:
+				if !ok {
+					t.Fatal("callback didn't echo the challenge")
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("hub never verified the subscription")
+			}
+			select {
+			case ok := <-sub.delivered:
+				if ok == tt.tamper {
+					t.Errorf("signature accepted = %v, want %v", ok, !tt.tamper)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("hub never delivered content")
+			}
+		})
+	}
+}
+
+func TestSubscribeHubError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "topic not allowed", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	err := Subscribe(context.Background(), server.Client(), Request{
+		Hub:      server.URL,
+		Topic:    "https://example.com/feed",
+		Callback: "https://gator.example.com/websub/1",
+	})
+	if err == nil {
+		t.Fatal("Subscribe succeeded against a hub that refused")
+	}
+}
+
+func TestParseVerification(t *testing.T) {
+	for _, tt := range []struct {
+		query   string
+		wantErr bool
+	}{
+		{"hub.mode=subscribe&hub.topic=t&hub.challenge=c&hub.lease_seconds=60", false},
+		{"hub.mode=unsubscribe&hub.topic=t&hub.challenge=c", false},
+		{"hub.mode=denied&hub.topic=t&hub.reason=spam", false},
+		{"hub.mode=subscribe&hub.topic=t", true},
+		{"hub.mode=subscribe&hub.challenge=c", true},
+		{"hub.mode=publish&hub.topic=t&hub.challenge=c", true},
+		{"hub.mode=subscribe&hub.topic=t&hub.challenge=c&hub.lease_seconds=soon", true},
+	} {
+		query, _ := url.ParseQuery(tt.query)
+		if _, err := ParseVerification(query); (err != nil) != tt.wantErr {
+			t.Errorf("ParseVerification(%q) error = %v, want error %v", tt.query, err, tt.wantErr)
+		}
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte("<feed/>")
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	for _, tt := range []struct {
+		header string
+		secret string
+		want   bool
+	}{
+		{"sha256=" + signature, "secret", true},
+		{"SHA256=" + signature, "secret", true},
+		{"sha256=" + signature, "other", false},
+		{"sha1=" + signature, "secret", false},
+		{"md5=" + signature, "secret", false},
+		{"sha256=zz", "secret", false},
+		{signature, "secret", false},
+		{"", "secret", false},
+	} {
+		if got := VerifySignature(tt.header, body, tt.secret); got != tt.want {
+			t.Errorf("VerifySignature(%q, %q) = %v, want %v", tt.header, tt.secret, got, tt.want)
+		}
+	}
+}
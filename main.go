@@ -3,27 +3,53 @@ package main
 import (
 	"context"
 	"database/sql"
-	"encoding/xml"
 	"errors"
+	"flag"
 	"fmt"
-	"html"
 	"io"
 	"log"
-	"net/http"
+	"log/slog"
 	"os"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jjboykin/gator/internal/config"
 	"github.com/jjboykin/gator/internal/database"
+	"github.com/jjboykin/gator/internal/fetcher"
+	"github.com/jjboykin/gator/internal/logging"
+	"github.com/jjboykin/gator/internal/plaintext"
+	"github.com/jjboykin/gator/internal/ratelimit"
 	_ "github.com/lib/pq"
 )
 
 type state struct {
-	db        *database.Queries
-	configPtr *config.Config
+	db         *database.Queries
+	configPtr  *config.Config
+	configOpts config.Options
+	globalArgs []string
+	dbConn     *sql.DB
+	logCloser  io.Closer
+	metrics    *aggregatorMetrics
+	limiter    *ratelimit.Limiter
+
+	// The logger and HTTP client are replaced when agg reloads its config
+	// while its HTTP servers may be using them; read them through logger
+	// and fetcher.
+	loggerPtr  atomic.Pointer[slog.Logger]
+	fetcherPtr atomic.Pointer[fetcher.Client]
 }
+
+func (s *state) logger() *slog.Logger {
+	return s.loggerPtr.Load()
+}
+
+func (s *state) fetcher() *fetcher.Client {
+	return s.fetcherPtr.Load()
+}
+
 type command struct {
 	name        string
 	description string
@@ -46,32 +72,66 @@ func (c *commands) run(s *state, cmd command) error {
 	return handler(s, cmd)
 }
 
-type RSSFeed struct {
-	Channel struct {
-		Title       string    `xml:"title"`
-		Link        string    `xml:"link"`
-		Description string    `xml:"description"`
-		Item        []RSSItem `xml:"item"`
-	} `xml:"channel"`
-}
-
-type RSSItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
-}
-
 func main() {
 
-	programState := state{}
-	cfg, err := config.Read()
+	// Global flags come before the command name, e.g. `gator --config x.json agg 1m`
+	globalFlags := flag.NewFlagSet("gator", flag.ContinueOnError)
+	configPath := globalFlags.String("config", "", "path to the config file")
+	globalFlags.String("db-url", "", "database connection URL")
+	globalFlags.String("user", "", "user name to run the command as")
+	globalFlags.String("log-level", "", "log level: debug, info, warn or error")
+	globalFlags.String("log-format", "", "log format: text or json")
+	globalFlags.String("log-file", "", "write logs to this file instead of stderr")
+	if err := globalFlags.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+
+	configOpts := config.Options{
+		Path:      *configPath,
+		Overrides: make(map[string]string),
+	}
+	flagKeys := map[string]string{
+		"db-url":     "db_url",
+		"user":       "current_user_name",
+		"log-level":  "log_level",
+		"log-format": "log_format",
+		"log-file":   "log_file",
+	}
+	globalFlags.Visit(func(f *flag.Flag) {
+		if key, ok := flagKeys[f.Name]; ok {
+			configOpts.Overrides[key] = f.Value.String()
+		}
+	})
+
+	programState := state{
+		configOpts: configOpts,
+		globalArgs: os.Args[1 : len(os.Args)-globalFlags.NArg()],
+		metrics:    newAggregatorMetrics(),
+	}
+	cfg, err := config.Load(configOpts)
 	if err != nil {
 		fmt.Println("Error reading config:", err)
 		os.Exit(1)
 	}
 	programState.configPtr = &cfg
 
+	logger, logCloser, err := logging.New(logOptions(&cfg))
+	if err != nil {
+		fmt.Println("Error configuring logging:", err)
+		os.Exit(1)
+	}
+	programState.loggerPtr.Store(logger)
+	programState.logCloser = logCloser
+	defer func() { programState.logCloser.Close() }()
+
+	programState.limiter = ratelimit.New(hostLimits(&cfg))
+	client, err := newFetcher(&cfg, programState.limiter)
+	if err != nil {
+		fmt.Println("Error configuring HTTP client:", err)
+		os.Exit(1)
+	}
+	programState.fetcherPtr.Store(client)
+
 	db, err := sql.Open("postgres", programState.configPtr.DBUrl)
 	if err != nil {
 		log.Fatal(errors.New(err.Error()))
@@ -79,6 +139,7 @@ func main() {
 	}
 	dbQueries := database.New(db)
 	programState.db = dbQueries
+	programState.dbConn = db
 
 	// Initialize the commands struct with its map
 	cliCommands := commands{
@@ -88,37 +149,113 @@ func main() {
 	//cliCommands.register("addfeed", handlerAddFeed)
 	cliCommands.register("addfeed", middlewareLoggedIn(handlerAddFeed))
 	cliCommands.register("agg", handlerAggregator)
+	cliCommands.register("alerts", middlewareLoggedIn(handlerAlerts))
 	cliCommands.register("browse", middlewareLoggedIn(handlerBrowse))
+	cliCommands.register("category", middlewareLoggedIn(handlerCategory))
+	cliCommands.register("check", handlerCheck)
+	cliCommands.register("completion", handlerCompletion(&cliCommands))
+	cliCommands.register("config", handlerConfig)
+	cliCommands.register("digest", middlewareLoggedIn(handlerDigest))
+	cliCommands.register("download", middlewareLoggedIn(handlerDownload))
+	cliCommands.register("episodes", middlewareLoggedIn(handlerEpisodes))
+	cliCommands.register("extract", middlewareLoggedIn(handlerExtract))
+	cliCommands.register("feed", handlerFeed)
 	cliCommands.register("feeds", handlerFeeds)
 	cliCommands.register("follow", middlewareLoggedIn(handlerFollow))
 	cliCommands.register("following", middlewareLoggedIn(handlerFollowing))
 	cliCommands.register("login", handlerLogin)
+	cliCommands.register("move", middlewareLoggedIn(handlerMove))
+	cliCommands.register("notifications", middlewareLoggedIn(handlerNotifications))
+	cliCommands.register("opml", middlewareLoggedIn(handlerOPML))
+	cliCommands.register("publish", middlewareLoggedIn(handlerPublish))
+	cliCommands.register("reader", middlewareLoggedIn(handlerReader))
 	cliCommands.register("register", handlerRegister)
 	cliCommands.register("reset", handlerReset)
+	cliCommands.register("rules", middlewareLoggedIn(handlerRules))
+	cliCommands.register("serve", handlerServe)
+	cliCommands.register("service", handlerService)
+	cliCommands.register("show", middlewareLoggedIn(handlerShow))
+	cliCommands.register("supervise", handlerSupervise)
+	cliCommands.register("tag", middlewareLoggedIn(handlerTag))
+	cliCommands.register("tags", middlewareLoggedIn(handlerTags))
 	cliCommands.register("unfollow", middlewareLoggedIn(handlerUnfollow))
+	cliCommands.register("untag", middlewareLoggedIn(handlerUntag))
 	cliCommands.register("users", handlerUsers)
 
 	// Check if we have enough arguments
-	if len(os.Args) < 2 {
+	args := globalFlags.Args()
+	if len(args) < 1 {
 		fmt.Println("Error: not enough arguments")
 		os.Exit(1)
 	}
 
 	// Create command from arguments
 	cmd := command{
-		name: os.Args[1],
-		args: os.Args[2:],
+		name: args[0],
+		args: args[1:],
 	}
 
 	// Run the command
 	err = cliCommands.run(&programState, cmd)
 	if err != nil {
 		fmt.Println("Error:", err)
+		programState.logCloser.Close()
 		os.Exit(1)
 	}
 
 }
 
+// newFetcher builds the HTTP client for feeds, pages and media from cfg.
+// The limiter outlives config reloads, so that it keeps its deferrals.
+func newFetcher(cfg *config.Config, limiter *ratelimit.Limiter) (*fetcher.Client, error) {
+	return fetcher.New(fetcher.Options{
+		UserAgent:      cfg.UserAgent,
+		Contact:        cfg.Contact,
+		ConnectTimeout: time.Duration(cfg.FetchConnectTimeout),
+		ReadTimeout:    time.Duration(cfg.FetchReadTimeout),
+		MaxBodyBytes:   int64(cfg.FetchMaxBodyMB) << 20,
+		MaxRedirects:   cfg.FetchMaxRedirects,
+		CAFile:         cfg.CAFile,
+		AllowPrivate:   cfg.AllowPrivateAddresses,
+		Limiter:        limiter,
+	})
+}
+
+// hostLimits returns the per-host limits of cfg, with the global values
+// filled in where a host_limits entry leaves them out.
+func hostLimits(cfg *config.Config) (ratelimit.Limits, map[string]ratelimit.Limits) {
+	defaults := ratelimit.Limits{
+		PerMinute:     cfg.HostRequestsPerMinute,
+		Burst:         cfg.HostBurst,
+		MaxConcurrent: cfg.HostMaxConcurrency,
+	}
+	rules := make(map[string]ratelimit.Limits, len(cfg.HostLimits))
+	for host, limit := range cfg.HostLimits {
+		rule := defaults
+		if limit.RequestsPerMinute != 0 {
+			rule.PerMinute = limit.RequestsPerMinute
+		}
+		if limit.Burst != 0 {
+			rule.Burst = limit.Burst
+		}
+		if limit.MaxConcurrency != 0 {
+			rule.MaxConcurrent = limit.MaxConcurrency
+		}
+		rules[host] = rule
+	}
+	return defaults, rules
+}
+
+func logOptions(cfg *config.Config) logging.Options {
+	return logging.Options{
+		Level:      cfg.LogLevel,
+		Format:     cfg.LogFormat,
+		File:       cfg.LogFile,
+		MaxSizeMB:  cfg.LogMaxSizeMB,
+		MaxBackups: cfg.LogMaxBackups,
+	}
+}
+
 // Handlers
 func handlerAddFeed(s *state, cmd command, user database.User) error {
 	if len(cmd.args) == 0 {
@@ -132,6 +269,10 @@ func handlerAddFeed(s *state, cmd command, user database.User) error {
 	name := cmd.args[0]
 	url := cmd.args[1]
 
+	if moved, err := s.db.GetFeedByAlias(context.Background(), url); err == nil {
+		return fmt.Errorf("%s has moved to %s, which is already a feed; follow that instead", url, moved.Url)
+	}
+
 	feedParams := database.CreateFeedParams{
 		ID:        uuid.New(),
 		CreatedAt: time.Now(),
@@ -142,7 +283,7 @@ func handlerAddFeed(s *state, cmd command, user database.User) error {
 	}
 	feed, err := s.db.CreateFeed(context.Background(), feedParams)
 	if err != nil {
-		fmt.Println("Error:", err)
+		return fmt.Errorf("couldn't create feed: %w", err)
 	}
 
 	feedFollowParams := database.CreateFeedFollowParams{
@@ -155,42 +296,35 @@ func handlerAddFeed(s *state, cmd command, user database.User) error {
 
 	follow, err := s.db.CreateFeedFollow(context.Background(), feedFollowParams)
 	if err != nil {
-		fmt.Println("Error:", err)
+		return fmt.Errorf("couldn't follow feed: %w", err)
 	}
 
 	fmt.Println("Feed: ", feed.ID, feed.CreatedAt, feed.UpdatedAt, feed.Name, feed.Url, feed.UserID)
 	fmt.Println("Follow: ", follow.ID, follow.CreatedAt, follow.UpdatedAt, follow.FeedName, follow.UserName)
 
-	return nil
-}
-
-func handlerAggregator(s *state, cmd command) error {
-	if len(cmd.args) == 0 {
-		return errors.New("time_between_reqs not given")
+	if err := subscribeToHub(context.Background(), s, feed); err != nil {
+		fmt.Println("WebSub:", err)
 	}
 
-	if len(cmd.args) > 1 {
-		return errors.New("too many command args given")
-	}
+	return nil
+}
 
-	time_between_reqs := cmd.args[0]
-	timeBetweenRequests, err := time.ParseDuration(time_between_reqs)
+func handlerBrowse(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet("browse", flag.ContinueOnError)
+	full := fs.Bool("full", false, "print the full article content instead of the summary")
+	category := fs.String("category", "", "only list posts from feeds in this category or below it")
+	tag := fs.String("tag", "", "only list posts with this tag")
+	starred := fs.Bool("starred", false, "only list starred posts")
+	unread := fs.Bool("unread", false, "only list unread posts")
+	args, err := parseCommandFlags(fs, cmd.args)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Collecting feeds every %s\n", timeBetweenRequests)
-	ticker := time.NewTicker(timeBetweenRequests)
-	for ; ; <-ticker.C {
-		scrapeFeeds(s)
-	}
-}
-
-func handlerBrowse(s *state, cmd command, user database.User) error {
 	limit := 2
 
-	if len(cmd.args) > 0 {
-		limitArg, err := strconv.Atoi(cmd.args[0])
+	if len(args) > 0 {
+		limitArg, err := strconv.Atoi(args[0])
 		if err != nil {
 			fmt.Println("Error:", err)
 			return err
@@ -199,8 +333,29 @@ func handlerBrowse(s *state, cmd command, user database.User) error {
 	}
 
 	userParams := database.GetPostsForUserParams{
-		ID:    user.ID,
-		Limit: int32(limit),
+		ID:      user.ID,
+		Starred: *starred,
+		Unread:  *unread,
+		Limit:   int32(limit),
+	}
+	if *category != "" {
+		tree, err := loadCategories(context.Background(), s, user.ID)
+		if err != nil {
+			return err
+		}
+		found, err := tree.find(*category)
+		if err != nil {
+			return err
+		}
+		userParams.FilterCategories = true
+		userParams.CategoryIds = tree.subtree(found.ID)
+	}
+	if *tag != "" {
+		normalized, err := normalizeTag(*tag)
+		if err != nil {
+			return err
+		}
+		userParams.Tag = sql.NullString{String: normalized, Valid: true}
 	}
 
 	posts, err := s.db.GetPostsForUser(context.Background(), userParams)
@@ -210,9 +365,20 @@ func handlerBrowse(s *state, cmd command, user database.User) error {
 
 	for _, post := range posts {
 		fmt.Printf("%s from %s\n", post.PublishedAt, post.FeedName)
-		fmt.Printf("--- %s ---\n", post.Title)
-		fmt.Printf("    %v\n", post.Description.String)
+		fmt.Printf("--- %s ---%s\n", post.Title, postStateMarkers(post.ReadAt, post.StarredAt))
+		body := post.Description.String
+		if *full {
+			body = postBody(post.Description, post.Content, post.ExtractedContent)
+		}
+		printIndented(plaintext.Render(body, bodyWidth-4), "    ")
 		fmt.Printf("Link: %s\n", post.Url)
+		if err := printEnclosures(s, user.ID, post.ID); err != nil {
+			return err
+		}
+		if err := printPostTags(s, user.ID, post.ID); err != nil {
+			return err
+		}
+		fmt.Printf("ID: %s\n", post.ID)
 		fmt.Println("==================================================")
 	}
 	return nil
@@ -236,37 +402,60 @@ func handlerFeeds(s *state, cmd command) error {
 }
 
 func handlerFollow(s *state, cmd command, user database.User) error {
-	if len(cmd.args) == 0 {
+	fs := flag.NewFlagSet("follow", flag.ContinueOnError)
+	category := fs.String("category", "", "put the feed in this category, e.g. Tech/Go, creating it if needed")
+	args, err := parseCommandFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
 		return errors.New("no command args given")
 	}
 
-	if len(cmd.args) != 1 {
+	if len(args) != 1 {
 		return errors.New("too many command args given")
 	}
 
-	url := cmd.args[0]
+	url := args[0]
 
-	feed, err := s.db.GetFeedByURL(context.Background(), url)
+	feed, err := lookupFeed(context.Background(), s, url)
 	if err != nil {
 		fmt.Println("Error:", err)
 	}
 
+	categoryID, err := resolveCategory(context.Background(), s, user.ID, *category)
+	if err != nil {
+		return err
+	}
+
 	feedFollowParams := database.CreateFeedFollowParams{
-		ID:        uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		UserID:    user.ID,
-		FeedID:    feed.ID,
+		ID:         uuid.New(),
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		UserID:     user.ID,
+		FeedID:     feed.ID,
+		CategoryID: categoryID,
 	}
 
 	follow, err := s.db.CreateFeedFollow(context.Background(), feedFollowParams)
 	if err != nil {
-		fmt.Println("Error:", err)
+		return fmt.Errorf("couldn't follow feed: %w", err)
 	}
 
 	fmt.Println(follow.FeedName)
 	fmt.Println(follow.UserName)
 
+	pushed, err := s.db.HasActiveWebSubSubscription(context.Background(), database.HasActiveWebSubSubscriptionParams{
+		FeedID: feed.ID,
+		Now:    time.Now(),
+	})
+	if err == nil && !pushed {
+		if err := subscribeToHub(context.Background(), s, feed); err != nil {
+			fmt.Println("WebSub:", err)
+		}
+	}
+
 	return nil
 }
 
@@ -280,9 +469,29 @@ func handlerFollowing(s *state, cmd command, user database.User) error {
 		fmt.Println("Error:", err)
 	}
 
+	tree, err := loadCategories(context.Background(), s, user.ID)
+	if err != nil {
+		return err
+	}
+
+	// Feeds without a category come first, then each category with its
+	// feeds and subcategories, indented.
+	byCategory := make(map[uuid.UUID][]string)
 	for _, follow := range follows {
-		fmt.Println(follow.FeedName)
+		byCategory[follow.CategoryID.UUID] = append(byCategory[follow.CategoryID.UUID], follow.FeedName)
 	}
+	var printCategory func(id uuid.UUID, depth int)
+	printCategory = func(id uuid.UUID, depth int) {
+		indent := strings.Repeat("    ", depth)
+		for _, name := range byCategory[id] {
+			fmt.Println(indent + name)
+		}
+		for _, child := range tree.children[id] {
+			fmt.Printf("%s%s%s\n", indent, child.Name, categorySeparator)
+			printCategory(child.ID, depth+1)
+		}
+	}
+	printCategory(uuid.Nil, 0)
 
 	return nil
 }
@@ -397,7 +606,7 @@ func handlerUnfollow(s *state, cmd command, user database.User) error {
 
 	url := cmd.args[0]
 
-	feed, err := s.db.GetFeedByURL(context.Background(), url)
+	feed, err := lookupFeed(context.Background(), s, url)
 	if err != nil {
 		fmt.Println("Error:", err)
 	}
@@ -425,126 +634,3 @@ func middlewareLoggedIn(handler func(s *state, cmd command, user database.User)
 		return handler(s, cmd, user)
 	}
 }
-
-// Other functions
-func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", "gator")
-
-	client := http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected HTTP status: %d", resp.StatusCode)
-	}
-
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	feed := &RSSFeed{}
-	err = xml.Unmarshal(data, feed)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch feed from %s: %w", feedURL, err)
-	}
-
-	for i := range feed.Channel.Item {
-		feed.Channel.Item[i].Title = html.UnescapeString(feed.Channel.Item[i].Title)
-		feed.Channel.Item[i].Description = html.UnescapeString(feed.Channel.Item[i].Description)
-	}
-	feed.Channel.Title = html.UnescapeString(feed.Channel.Title)
-	feed.Channel.Description = html.UnescapeString(feed.Channel.Description)
-
-	return feed, nil
-}
-
-func scrapeFeeds(s *state) error {
-
-	feed, err := s.db.GetNextFeedToFetch(context.Background())
-	if err != nil {
-		return err
-	}
-
-	nullableTimeSQL := sql.NullTime{
-		Time:  time.Now(),
-		Valid: true,
-	}
-
-	feedToMarkParams := database.MarkFeedFetchedParams{
-		ID:            feed.ID,
-		LastFetchedAt: nullableTimeSQL,
-		UpdatedAt:     nullableTimeSQL.Time,
-	}
-
-	err = s.db.MarkFeedFetched(context.Background(), feedToMarkParams)
-	if err != nil {
-		return err
-	}
-
-	fetchedFeed, err := fetchFeed(context.Background(), feed.Url)
-	if err != nil {
-		return err
-	}
-
-	//fmt.Printf("Feed Title: %s\n", fetchedFeed.Channel.Title)
-	//fmt.Printf("Feed Description: %s\n", fetchedFeed.Channel.Description)
-	//fmt.Println("Feed Items: ")
-	for _, item := range fetchedFeed.Channel.Item {
-
-		nullableStringDescription := sql.NullString{
-			String: item.Description,
-			Valid:  true,
-		}
-
-		timeFormats := []string{
-			"2006-01-02 15:04:05",
-			"2006/01/02",
-			"02 Jan 2006",
-			"01/02/2006 15:04:05 MST",
-			time.RFC3339,
-		}
-
-		var parsedPubDate time.Time
-
-		for _, layout := range timeFormats {
-			parsedTime, err := time.Parse(layout, item.PubDate)
-
-			if err != nil {
-				//fmt.Println("Error parsing date:", err)
-				continue
-			} else {
-				parsedPubDate = parsedTime
-				break
-			}
-		}
-
-		postParams := database.CreatePostParams{
-			ID:          uuid.New(),
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
-			Title:       item.Title,
-			Url:         item.Link,
-			Description: nullableStringDescription,
-			PublishedAt: parsedPubDate,
-			FeedID:      feed.ID,
-		}
-
-		post, err := s.db.CreatePost(context.Background(), postParams)
-		if err != nil {
-			fmt.Println("Error:", err)
-		}
-
-		fmt.Printf("Post '%s' created as id=%s\n", post.Title, post.ID)
-
-	}
-
-	return nil
-}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/jjboykin/gator/internal/database"
+	"github.com/jjboykin/gator/internal/metrics"
+)
+
+// aggregatorMetrics are the Prometheus metrics exported by `agg --metrics-addr`.
+type aggregatorMetrics struct {
+	registry *metrics.Registry
+
+	fetches         *metrics.Counter
+	fetchDuration   *metrics.Histogram
+	bytesDownloaded *metrics.Counter
+	postsInserted   *metrics.Counter
+	postsDuplicated *metrics.Counter
+	parseFailures   *metrics.Counter
+	feedRepairs     *metrics.Counter
+	ruleActions     *metrics.Counter
+	alertDeliveries *metrics.Counter
+
+	feeds         *metrics.Gauge
+	feedsDue      *metrics.Gauge
+	feedsOverdue  *metrics.Gauge
+	queueLag      *metrics.Gauge
+	statsFailures *metrics.Counter
+}
+
+func newAggregatorMetrics() *aggregatorMetrics {
+	r := metrics.NewRegistry()
+	return &aggregatorMetrics{
+		registry: r,
+
+		fetches: r.NewCounter("gator_feed_fetches_total",
+			"Feed fetches by HTTP status code (\"error\" when no response was received).", "status"),
+		fetchDuration: r.NewHistogram("gator_feed_fetch_duration_seconds",
+			"Time taken to download and parse a feed.", []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60}),
+		bytesDownloaded: r.NewCounter("gator_feed_bytes_downloaded_total",
+			"Bytes of feed content downloaded."),
+		postsInserted: r.NewCounter("gator_posts_inserted_total",
+			"Posts inserted into the database."),
+		postsDuplicated: r.NewCounter("gator_posts_duplicated_total",
+			"Feed items skipped because a post with the same URL already exists."),
+		parseFailures: r.NewCounter("gator_feed_parse_failures_total",
+			"Fetched feeds that could not be parsed."),
+		feedRepairs: r.NewCounter("gator_feed_repairs_total",
+			"Malformed feed documents that were parsed after repairs, by kind of repair.", "repair"),
+		ruleActions: r.NewCounter("gator_rule_actions_total",
+			"Actions taken by user rules on new posts, by action.", "action"),
+		alertDeliveries: r.NewCounter("gator_alert_deliveries_total",
+			"Alert delivery attempts, by result (delivered, retry or failed).", "result"),
+
+		feeds: r.NewGauge("gator_feeds",
+			"Number of feeds in the database."),
+		feedsDue: r.NewGauge("gator_feeds_due",
+			"Feeds whose next fetch time has passed."),
+		feedsOverdue: r.NewGauge("gator_feeds_overdue",
+			"Feeds that have been due for more than five aggregation ticks."),
+		queueLag: r.NewGauge("gator_feed_queue_lag_seconds",
+			"How long the longest-waiting due feed has been due."),
+		statsFailures: r.NewCounter("gator_feed_stats_failures_total",
+			"Scrapes where the feed queue gauges could not be refreshed."),
+	}
+}
+
+// observeFetch records the outcome of a single fetchFeed call.
+func (m *aggregatorMetrics) observeFetch(result fetchResult, duration time.Duration, err error) {
+	status := "error"
+	if result.StatusCode != 0 {
+		status = strconv.Itoa(result.StatusCode)
+	}
+	m.fetches.Inc(status)
+	m.fetchDuration.Observe(duration.Seconds())
+	m.bytesDownloaded.Add(float64(result.Bytes))
+	if isFeedParseError(err) {
+		m.parseFailures.Inc()
+	}
+}
+
+// watchQueue refreshes the feed queue gauges from next_fetch_at on every
+// scrape. A feed is overdue once it has been due for five ticks.
+func (m *aggregatorMetrics) watchQueue(db *database.Queries, interval time.Duration) {
+	m.registry.OnScrape(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		now := time.Now()
+		stats, err := db.GetFeedFetchStats(ctx, database.GetFeedFetchStatsParams{
+			Now:           now,
+			OverdueBefore: now.Add(-5 * interval),
+		})
+		if err != nil {
+			m.statsFailures.Inc()
+			return
+		}
+
+		m.feeds.Set(float64(stats.Total))
+		m.feedsDue.Set(float64(stats.Due))
+		m.feedsOverdue.Set(float64(stats.Overdue))
+		m.queueLag.Set(stats.QueueLagSeconds)
+	})
+}
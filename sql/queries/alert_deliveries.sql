@@ -0,0 +1,60 @@
+-- name: CreateAlertDelivery :one
+INSERT INTO alert_deliveries (id, created_at, updated_at, alert_id, post_id, match, next_attempt_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+ON CONFLICT (alert_id, post_id) DO NOTHING
+RETURNING *;
+
+-- name: GetDueAlertDeliveries :many
+SELECT
+d.*,
+a.name AS alert_name,
+a.target_kind,
+a.target,
+a.secret,
+p.title AS post_title,
+p.url AS post_url,
+p.description AS post_description,
+p.published_at,
+f.name AS feed_name,
+f.url AS feed_url
+FROM alert_deliveries d
+INNER JOIN alerts a ON a.id = d.alert_id
+INNER JOIN posts p ON p.id = d.post_id
+INNER JOIN feeds f ON f.id = p.feed_id
+WHERE d.status = 'pending' AND d.next_attempt_at <= $1
+ORDER BY d.next_attempt_at
+LIMIT $2
+;
+
+-- name: MarkAlertDelivered :exec
+UPDATE alert_deliveries
+SET status = 'delivered', attempts = attempts + 1, last_error = NULL, delivered_at = $2, updated_at = $2
+WHERE id = $1
+;
+
+-- name: MarkAlertDeliveryFailed :exec
+UPDATE alert_deliveries
+SET status = $2, attempts = attempts + 1, last_error = $3, next_attempt_at = $4, updated_at = $5
+WHERE id = $1
+;
+
+-- name: GetAlertDeliveriesForUser :many
+SELECT
+d.*,
+a.name AS alert_name,
+p.title AS post_title
+FROM alert_deliveries d
+INNER JOIN alerts a ON a.id = d.alert_id
+INNER JOIN posts p ON p.id = d.post_id
+WHERE a.user_id = $1
+ORDER BY d.created_at DESC
+LIMIT $2
+;
@@ -0,0 +1,41 @@
+-- name: CreateAlert :one
+INSERT INTO alerts (id, created_at, updated_at, user_id, name, patterns, feed_id, target_kind, target, secret)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10
+)
+RETURNING *;
+
+-- name: GetAlertsForUser :many
+SELECT alerts.*, feeds.url AS feed_url
+FROM alerts
+LEFT JOIN feeds ON feeds.id = alerts.feed_id
+WHERE alerts.user_id = $1
+ORDER BY alerts.name
+;
+
+-- name: GetAlertByName :one
+SELECT * FROM alerts
+WHERE user_id = $1 AND name = $2
+;
+
+-- name: GetAlertsForFeed :many
+SELECT alerts.* FROM alerts
+INNER JOIN feed_follows ON feed_follows.user_id = alerts.user_id
+WHERE feed_follows.feed_id = $1
+AND (alerts.feed_id IS NULL OR alerts.feed_id = feed_follows.feed_id)
+ORDER BY alerts.created_at
+;
+
+-- name: DeleteAlert :execrows
+DELETE FROM alerts
+WHERE user_id = $1 AND name = $2
+;
@@ -0,0 +1,21 @@
+-- name: SetAPIPassword :exec
+INSERT INTO api_passwords (user_id, created_at, updated_at, password_hash)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (user_id) DO UPDATE
+SET updated_at = EXCLUDED.updated_at, password_hash = EXCLUDED.password_hash
+;
+
+-- name: GetAPIPassword :one
+SELECT * FROM api_passwords
+WHERE user_id = $1
+;
+
+-- name: DeleteAPIPassword :execrows
+DELETE FROM api_passwords
+WHERE user_id = $1
+;
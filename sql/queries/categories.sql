@@ -0,0 +1,34 @@
+-- name: CreateCategory :one
+INSERT INTO categories (id, created_at, updated_at, user_id, parent_id, name)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+
+-- name: GetCategoriesForUser :many
+SELECT * FROM categories
+WHERE user_id = $1
+ORDER BY name
+;
+
+-- name: RenameCategory :exec
+UPDATE categories
+SET name = $2, updated_at = $3
+WHERE id = $1
+;
+
+-- name: ReparentCategories :exec
+UPDATE categories
+SET parent_id = sqlc.narg('new_parent_id'), updated_at = @updated_at
+WHERE parent_id = @parent_id
+;
+
+-- name: DeleteCategory :exec
+DELETE FROM categories
+WHERE id = $1
+;
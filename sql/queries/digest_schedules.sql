@@ -0,0 +1,35 @@
+-- name: SetDigestSchedule :one
+INSERT INTO digest_schedules (user_id, created_at, updated_at, email, send_hour)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (user_id) DO UPDATE
+SET updated_at = EXCLUDED.updated_at, email = EXCLUDED.email, send_hour = EXCLUDED.send_hour
+RETURNING *;
+
+-- name: GetDigestSchedule :one
+SELECT * FROM digest_schedules
+WHERE user_id = $1
+;
+
+-- name: GetDigestSchedules :many
+SELECT digest_schedules.*, users.name AS user_name
+FROM digest_schedules
+INNER JOIN users ON users.id = digest_schedules.user_id
+ORDER BY digest_schedules.created_at
+;
+
+-- name: DeleteDigestSchedule :execrows
+DELETE FROM digest_schedules
+WHERE user_id = $1
+;
+
+-- name: MarkDigestSent :exec
+UPDATE digest_schedules
+SET last_sent_at = $2
+WHERE user_id = $1
+;
@@ -0,0 +1,63 @@
+-- name: CreatePostEnclosure :exec
+INSERT INTO post_enclosures (id, created_at, post_id, url, mime_type, length)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+ON CONFLICT (post_id, url) DO NOTHING
+;
+
+-- name: GetEnclosuresForPost :many
+-- Only posts of feeds the user follows have enclosures to show, so a
+-- guessed post ID doesn't reveal media from other users' feeds.
+SELECT post_enclosures.* FROM post_enclosures
+INNER JOIN posts ON posts.id = post_enclosures.post_id
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE post_enclosures.post_id = $1 AND feed_follows.user_id = $2
+ORDER BY post_enclosures.created_at ASC
+;
+
+-- name: CreatePodcastEpisode :exec
+INSERT INTO podcast_episodes (post_id, created_at, duration_seconds, episode, season, explicit, image_url)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+ON CONFLICT (post_id) DO NOTHING
+;
+
+-- name: GetPodcastEpisode :one
+SELECT * FROM podcast_episodes
+WHERE post_id = $1;
+
+-- name: GetEpisodesForUser :many
+SELECT
+    p.id AS post_id,
+    p.title,
+    p.published_at,
+    f.name AS feed_name,
+    e.url AS enclosure_url,
+    e.mime_type,
+    e.length,
+    pe.duration_seconds,
+    pe.episode,
+    pe.season
+FROM posts p
+INNER JOIN feeds f ON f.id = p.feed_id
+INNER JOIN feed_follows ff ON ff.feed_id = f.id
+INNER JOIN post_enclosures e ON e.post_id = p.id
+LEFT JOIN podcast_episodes pe ON pe.post_id = p.id
+WHERE ff.user_id = $1
+AND (sqlc.narg('feed_url')::text IS NULL OR f.url = sqlc.narg('feed_url'))
+ORDER BY p.published_at DESC
+LIMIT $2
+;
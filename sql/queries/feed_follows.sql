@@ -1,12 +1,13 @@
 -- name: CreateFeedFollow :one
 WITH inserted_feed_follow AS (
-    INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
+    INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id, category_id)
     VALUES (
         $1,
         $2,
         $3,
         $4,
-        $5
+        $5,
+        $6
     )
     RETURNING *
 )
@@ -24,6 +25,7 @@ INNER JOIN feeds on feeds.id = inserted_feed_follow.feed_id
 SELECT 
     feed_follows.*,
     feeds.name AS feed_name,
+    feeds.url AS feed_url,
     users.name AS user_name
 FROM feed_follows
 INNER JOIN users on users.id = feed_follows.user_id
@@ -37,4 +39,16 @@ WHERE
 feed_follows.user_id = $1
 AND
 feed_follows.feed_id = $2
-;
\ No newline at end of file
+;
+
+-- name: SetFeedFollowCategory :exec
+UPDATE feed_follows
+SET category_id = $3, updated_at = $4
+WHERE user_id = $1 AND feed_id = $2
+;
+
+-- name: MoveFeedFollowsToCategory :exec
+UPDATE feed_follows
+SET category_id = sqlc.narg('new_category_id'), updated_at = @updated_at
+WHERE category_id = @category_id
+;
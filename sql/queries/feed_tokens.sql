@@ -0,0 +1,26 @@
+-- name: SetFeedToken :one
+INSERT INTO feed_tokens (user_id, created_at, token)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (user_id) DO UPDATE
+SET created_at = EXCLUDED.created_at, token = EXCLUDED.token
+RETURNING *;
+
+-- name: GetFeedToken :one
+SELECT * FROM feed_tokens
+WHERE user_id = $1
+;
+
+-- name: GetUserByFeedToken :one
+SELECT users.* FROM users
+INNER JOIN feed_tokens ON feed_tokens.user_id = users.id
+WHERE feed_tokens.token = $1
+;
+
+-- name: DeleteFeedToken :execrows
+DELETE FROM feed_tokens
+WHERE user_id = $1
+;
@@ -31,12 +31,89 @@ DELETE FROM feeds;
 
 -- name: MarkFeedFetched :exec
 UPDATE feeds
-SET last_fetched_at = $2, updated_at = $3
+SET last_fetched_at = $2, updated_at = $3, next_fetch_at = $4
 WHERE id = $1
 ;
 
 -- name: GetNextFeedToFetch :one
 SELECT * FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST
+WHERE dead_at IS NULL
+AND (next_fetch_at IS NULL OR next_fetch_at <= @now::timestamp)
+ORDER BY next_fetch_at ASC NULLS FIRST, last_fetched_at ASC NULLS FIRST
 LIMIT 1
-;
\ No newline at end of file
+;
+
+-- name: ScheduleFeed :exec
+UPDATE feeds
+SET next_fetch_at = $2, fetch_interval_seconds = $3, updated_at = $4
+WHERE id = $1
+;
+
+-- name: SetFeedIntervalOverride :exec
+UPDATE feeds
+SET fetch_interval_override_seconds = $2, next_fetch_at = $3, updated_at = $4
+WHERE id = $1
+;
+
+-- name: GetFeedFetchStats :one
+SELECT
+    COUNT(*) AS total,
+    COUNT(*) FILTER (WHERE next_fetch_at IS NULL OR next_fetch_at <= @now::timestamp) AS due,
+    COUNT(*) FILTER (WHERE next_fetch_at < @overdue_before::timestamp) AS overdue,
+    COALESCE(EXTRACT(EPOCH FROM (@now::timestamp - MIN(next_fetch_at) FILTER (WHERE next_fetch_at <= @now::timestamp))), 0)::float8 AS queue_lag_seconds
+FROM feeds
+WHERE dead_at IS NULL
+;
+
+-- name: SetFeedFulltext :exec
+UPDATE feeds
+SET fulltext = $2, updated_at = $3
+WHERE id = $1
+;
+
+-- name: GetFeedByAlias :one
+SELECT feeds.* FROM feeds
+INNER JOIN feed_aliases ON feed_aliases.feed_id = feeds.id
+WHERE feed_aliases.url = $1;
+
+-- name: CreateFeedAlias :exec
+INSERT INTO feed_aliases (url, created_at, feed_id)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (url) DO UPDATE SET feed_id = EXCLUDED.feed_id
+;
+
+-- name: RecordFeedRedirect :exec
+UPDATE feeds
+SET redirect_url = $2, redirect_count = $3, updated_at = $4
+WHERE id = $1
+;
+
+-- name: MoveFeed :exec
+UPDATE feeds
+SET url = $2, redirect_url = NULL, redirect_count = 0, updated_at = $3
+WHERE id = $1
+;
+
+-- name: MarkFeedDead :exec
+UPDATE feeds
+SET dead_at = $2, updated_at = $2
+WHERE id = $1
+;
+
+-- name: ReviveFeed :exec
+UPDATE feeds
+SET dead_at = NULL, next_fetch_at = $2, updated_at = $2
+WHERE id = $1
+;
+
+-- name: DeferFeedsOnHost :execrows
+UPDATE feeds
+SET next_fetch_at = @until::timestamp, updated_at = @now::timestamp
+WHERE dead_at IS NULL
+AND (next_fetch_at IS NULL OR next_fetch_at < @until::timestamp)
+AND lower(substring(url from '^[^:]+://(?:[^@/]*@)?([^/:?#]+)')) = @host::text
+;
@@ -0,0 +1,31 @@
+-- name: CreateFeedNotifications :exec
+INSERT INTO notifications (id, created_at, user_id, feed_id, message)
+SELECT gen_random_uuid(), $1, feed_follows.user_id, feed_follows.feed_id, $2
+FROM feed_follows
+WHERE feed_follows.feed_id = $3
+;
+
+-- name: GetNotificationsForUser :many
+SELECT * FROM notifications
+WHERE user_id = $1
+AND (read_at IS NULL OR sqlc.arg('include_read')::bool)
+ORDER BY created_at DESC
+LIMIT $2
+;
+
+-- name: MarkNotificationsRead :exec
+UPDATE notifications
+SET read_at = sqlc.narg('read_at')
+WHERE user_id = @user_id AND id = ANY(@ids::uuid[]) AND read_at IS NULL
+;
+
+-- name: CreateNotification :exec
+INSERT INTO notifications (id, created_at, user_id, feed_id, message)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+;
@@ -0,0 +1,59 @@
+-- name: SetPostState :exec
+-- Only sets the states that are given; a post that is already read stays
+-- read from the first time.
+INSERT INTO post_states (user_id, post_id, updated_at, read_at, starred_at, hidden_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET updated_at = EXCLUDED.updated_at,
+    read_at = COALESCE(post_states.read_at, EXCLUDED.read_at),
+    starred_at = COALESCE(post_states.starred_at, EXCLUDED.starred_at),
+    hidden_at = COALESCE(post_states.hidden_at, EXCLUDED.hidden_at)
+;
+
+-- name: SetPostsRead :exec
+-- Marks posts read, keeping the time they were first read, or unread when
+-- read_at is NULL.
+INSERT INTO post_states (user_id, post_id, updated_at, read_at)
+SELECT @user_id::uuid, unnest(@post_ids::uuid[]), @updated_at::timestamp, sqlc.narg('read_at')::timestamp
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET updated_at = EXCLUDED.updated_at,
+    read_at = CASE WHEN EXCLUDED.read_at IS NULL THEN NULL ELSE COALESCE(post_states.read_at, EXCLUDED.read_at) END
+;
+
+-- name: SetPostsStarred :exec
+-- Stars posts, keeping the time they were first starred, or unstars them
+-- when starred_at is NULL.
+INSERT INTO post_states (user_id, post_id, updated_at, starred_at)
+SELECT @user_id::uuid, unnest(@post_ids::uuid[]), @updated_at::timestamp, sqlc.narg('starred_at')::timestamp
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET updated_at = EXCLUDED.updated_at,
+    starred_at = CASE WHEN EXCLUDED.starred_at IS NULL THEN NULL ELSE COALESCE(post_states.starred_at, EXCLUDED.starred_at) END
+;
+
+-- name: MarkStreamRead :execrows
+INSERT INTO post_states (user_id, post_id, updated_at, read_at)
+SELECT ff.user_id, p.id, @read_at::timestamp, @read_at::timestamp
+FROM posts p
+INNER JOIN feed_follows ff ON ff.feed_id = p.feed_id
+LEFT JOIN post_states ps ON ps.user_id = ff.user_id AND ps.post_id = p.id
+WHERE ff.user_id = @user_id
+AND ps.read_at IS NULL
+AND (sqlc.narg('feed_id')::uuid IS NULL OR p.feed_id = sqlc.narg('feed_id'))
+AND (NOT @filter_categories::boolean OR ff.category_id = ANY(@category_ids::uuid[]))
+AND (sqlc.narg('tag')::text IS NULL OR EXISTS (
+    SELECT 1 FROM post_tags pt
+    WHERE pt.user_id = ff.user_id AND pt.post_id = p.id AND pt.tag = sqlc.narg('tag')
+))
+AND (NOT @starred::boolean OR ps.starred_at IS NOT NULL)
+AND p.published_at <= @published_before
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET updated_at = EXCLUDED.updated_at,
+    read_at = COALESCE(post_states.read_at, EXCLUDED.read_at)
+;
@@ -0,0 +1,34 @@
+-- name: AddPostTag :exec
+INSERT INTO post_tags (user_id, post_id, tag, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (user_id, post_id, tag) DO NOTHING
+;
+
+-- name: RemovePostTag :exec
+DELETE FROM post_tags
+WHERE user_id = $1 AND post_id = $2 AND tag = $3
+;
+
+-- name: RemoveAllPostTags :exec
+DELETE FROM post_tags
+WHERE user_id = $1 AND post_id = $2
+;
+
+-- name: GetTagsForPost :many
+SELECT tag FROM post_tags
+WHERE user_id = $1 AND post_id = $2
+ORDER BY tag
+;
+
+-- name: GetTagCountsForUser :many
+SELECT tag, COUNT(*) AS posts
+FROM post_tags
+WHERE user_id = $1
+GROUP BY tag
+ORDER BY tag
+;
@@ -1,5 +1,8 @@
 -- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
+-- Posts are unique by URL, and every scrape sees the posts already stored
+-- again. Those are skipped, so the query returns no row (sql.ErrNoRows)
+-- for a duplicate instead of failing with a unique violation.
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, content, author, categories, comments_url)
 VALUES (
     $1,
     $2,
@@ -8,19 +11,137 @@ VALUES (
     $5,
     $6,
     $7,
-    $8
+    $8,
+    $9,
+    $10,
+    $11,
+    $12
 )
+ON CONFLICT (url) DO NOTHING
 RETURNING *;
 
 -- name: GetPostsForUser :many
 SELECT 
 p.*,
 f.name as feed_name,
-u.id as user_id
+f.url as feed_url,
+ff.user_id,
+ps.read_at,
+ps.starred_at
 FROM posts p 
 inner join feeds f on f.id = p.feed_id
-inner join users u on u.id = f.user_id
-WHERE u.id = $1
+inner join feed_follows ff on ff.feed_id = f.id
+left join post_states ps on ps.user_id = ff.user_id and ps.post_id = p.id
+WHERE ff.user_id = @id
+AND ps.hidden_at IS NULL
+AND (NOT @filter_categories::boolean OR ff.category_id = ANY(@category_ids::uuid[]))
+AND (sqlc.narg('tag')::text IS NULL OR EXISTS (
+    SELECT 1 FROM post_tags pt
+    WHERE pt.user_id = ff.user_id AND pt.post_id = p.id AND pt.tag = sqlc.narg('tag')
+))
+AND (NOT @starred::boolean OR ps.starred_at IS NOT NULL)
+AND (NOT @unread::boolean OR ps.read_at IS NULL)
 ORDER BY published_at DESC
-LIMIT $2
-;
\ No newline at end of file
+LIMIT sqlc.arg('limit')
+;
+
+-- name: GetPost :one
+SELECT * FROM posts
+WHERE id = $1;
+
+-- name: SetPostExtractedContent :exec
+UPDATE posts
+SET extracted_content = $2, updated_at = $3
+WHERE id = $1
+;
+
+-- name: GetPostsForDigest :many
+SELECT
+p.id,
+p.title,
+p.url,
+p.description,
+p.author,
+p.published_at,
+f.name AS feed_name,
+ff.category_id
+FROM posts p
+INNER JOIN feeds f ON f.id = p.feed_id
+INNER JOIN feed_follows ff ON ff.feed_id = f.id
+LEFT JOIN post_states ps ON ps.user_id = ff.user_id AND ps.post_id = p.id
+WHERE ff.user_id = @user_id
+AND p.created_at > @since::timestamp
+AND p.created_at <= @until::timestamp
+AND ps.hidden_at IS NULL
+AND ps.read_at IS NULL
+ORDER BY f.name, p.published_at DESC
+LIMIT sqlc.arg('limit')
+;
+
+-- name: GetReaderItemRefs :many
+SELECT
+p.item_number,
+p.published_at,
+f.url AS feed_url
+FROM posts p
+INNER JOIN feeds f ON f.id = p.feed_id
+INNER JOIN feed_follows ff ON ff.feed_id = f.id
+LEFT JOIN post_states ps ON ps.user_id = ff.user_id AND ps.post_id = p.id
+WHERE ff.user_id = @user_id
+AND ps.hidden_at IS NULL
+AND (sqlc.narg('feed_id')::uuid IS NULL OR p.feed_id = sqlc.narg('feed_id'))
+AND (NOT @filter_categories::boolean OR ff.category_id = ANY(@category_ids::uuid[]))
+AND (sqlc.narg('tag')::text IS NULL OR EXISTS (
+    SELECT 1 FROM post_tags pt
+    WHERE pt.user_id = ff.user_id AND pt.post_id = p.id AND pt.tag = sqlc.narg('tag')
+))
+AND (NOT @starred::boolean OR ps.starred_at IS NOT NULL)
+AND (NOT @read::boolean OR ps.read_at IS NOT NULL)
+AND (NOT @unread::boolean OR ps.read_at IS NULL)
+AND (sqlc.narg('published_after')::timestamp IS NULL OR p.published_at >= sqlc.narg('published_after'))
+AND (sqlc.narg('published_before')::timestamp IS NULL OR p.published_at <= sqlc.narg('published_before'))
+ORDER BY
+    CASE WHEN @oldest_first::boolean THEN p.published_at END ASC,
+    CASE WHEN @oldest_first::boolean THEN p.item_number END ASC,
+    p.published_at DESC,
+    p.item_number DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset')
+;
+
+-- name: GetReaderItems :many
+SELECT
+p.*,
+f.name AS feed_name,
+f.url AS feed_url,
+ff.category_id,
+ps.read_at,
+ps.starred_at,
+ARRAY(
+    SELECT pt.tag FROM post_tags pt
+    WHERE pt.user_id = ff.user_id AND pt.post_id = p.id
+    ORDER BY pt.tag
+)::text[] AS tags
+FROM posts p
+INNER JOIN feeds f ON f.id = p.feed_id
+INNER JOIN feed_follows ff ON ff.feed_id = f.id
+LEFT JOIN post_states ps ON ps.user_id = ff.user_id AND ps.post_id = p.id
+WHERE ff.user_id = @user_id
+AND p.item_number = ANY(@item_numbers::bigint[])
+ORDER BY p.published_at DESC, p.item_number DESC
+;
+
+-- name: GetUnreadCounts :many
+SELECT
+f.url AS feed_url,
+ff.category_id,
+COUNT(*) AS count,
+MAX(p.published_at)::timestamp AS newest_published_at
+FROM posts p
+INNER JOIN feeds f ON f.id = p.feed_id
+INNER JOIN feed_follows ff ON ff.feed_id = f.id
+LEFT JOIN post_states ps ON ps.user_id = ff.user_id AND ps.post_id = p.id
+WHERE ff.user_id = $1
+AND ps.hidden_at IS NULL
+AND ps.read_at IS NULL
+GROUP BY f.url, ff.category_id
+;
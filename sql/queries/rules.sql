@@ -0,0 +1,35 @@
+-- name: CreateRule :one
+INSERT INTO rules (id, created_at, updated_at, user_id, name, conditions, actions)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+RETURNING *;
+
+-- name: GetRulesForUser :many
+SELECT * FROM rules
+WHERE user_id = $1
+ORDER BY name
+;
+
+-- name: GetRuleByName :one
+SELECT * FROM rules
+WHERE user_id = $1 AND name = $2
+;
+
+-- name: GetRulesForFeed :many
+SELECT rules.* FROM rules
+INNER JOIN feed_follows ON feed_follows.user_id = rules.user_id
+WHERE feed_follows.feed_id = $1
+ORDER BY rules.user_id, rules.created_at
+;
+
+-- name: DeleteRule :execrows
+DELETE FROM rules
+WHERE user_id = $1 AND name = $2
+;
@@ -0,0 +1,57 @@
+-- name: UpsertWebSubSubscription :one
+INSERT INTO websub_subscriptions (id, created_at, updated_at, feed_id, hub_url, topic_url, secret, status, last_attempt_at, pending_mode)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    'subscribe'
+)
+ON CONFLICT (feed_id) DO UPDATE
+SET hub_url = EXCLUDED.hub_url,
+    topic_url = EXCLUDED.topic_url,
+    status = CASE WHEN websub_subscriptions.status = 'active' THEN 'active' ELSE EXCLUDED.status END,
+    last_attempt_at = EXCLUDED.last_attempt_at,
+    pending_mode = EXCLUDED.pending_mode,
+    updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: GetWebSubSubscription :one
+SELECT * FROM websub_subscriptions
+WHERE id = $1;
+
+-- name: ActivateWebSubSubscription :exec
+UPDATE websub_subscriptions
+SET status = 'active', lease_expires_at = $2, last_error = NULL, pending_mode = NULL, updated_at = $3
+WHERE id = $1
+;
+
+-- name: SetWebSubSubscriptionStatus :exec
+UPDATE websub_subscriptions
+SET status = $2, last_error = $3, pending_mode = NULL, updated_at = $4
+WHERE id = $1
+;
+
+-- name: GetWebSubSubscriptionsToRenew :many
+SELECT websub_subscriptions.*, feeds.url AS feed_url
+FROM websub_subscriptions
+INNER JOIN feeds ON feeds.id = websub_subscriptions.feed_id
+WHERE (last_attempt_at IS NULL OR last_attempt_at < @retry_before::timestamp)
+AND (
+    (status = 'active' AND lease_expires_at < @renew_before::timestamp)
+    OR status IN ('pending', 'failed')
+)
+;
+
+-- name: HasActiveWebSubSubscription :one
+SELECT EXISTS (
+    SELECT 1 FROM websub_subscriptions
+    WHERE feed_id = $1
+    AND status = 'active'
+    AND lease_expires_at > @now::timestamp
+);
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN next_fetch_at TIMESTAMP,
+ADD COLUMN fetch_interval_seconds INTEGER NOT NULL DEFAULT 3600,
+ADD COLUMN fetch_interval_override_seconds INTEGER;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN next_fetch_at,
+DROP COLUMN fetch_interval_seconds,
+DROP COLUMN fetch_interval_override_seconds;
@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE websub_subscriptions (
+id UUID PRIMARY KEY,
+created_at TIMESTAMP NOT NULL,
+updated_at TIMESTAMP NOT NULL,
+feed_id UUID NOT NULL UNIQUE,
+hub_url TEXT NOT NULL,
+topic_url TEXT NOT NULL,
+secret TEXT NOT NULL,
+status TEXT NOT NULL,
+lease_expires_at TIMESTAMP,
+last_attempt_at TIMESTAMP,
+last_error TEXT,
+CONSTRAINT fk_feed_id
+    FOREIGN KEY (feed_id)
+    REFERENCES feeds(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE websub_subscriptions;
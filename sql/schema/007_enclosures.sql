@@ -0,0 +1,32 @@
+-- +goose Up
+CREATE TABLE post_enclosures (
+id UUID PRIMARY KEY,
+created_at TIMESTAMP NOT NULL,
+post_id UUID NOT NULL,
+url TEXT NOT NULL,
+mime_type TEXT NOT NULL,
+length BIGINT NOT NULL,
+UNIQUE (post_id, url),
+CONSTRAINT fk_post_id
+    FOREIGN KEY (post_id)
+    REFERENCES posts(id)
+    ON DELETE CASCADE
+);
+
+CREATE TABLE podcast_episodes (
+post_id UUID PRIMARY KEY,
+created_at TIMESTAMP NOT NULL,
+duration_seconds INTEGER,
+episode INTEGER,
+season INTEGER,
+explicit BOOLEAN,
+image_url TEXT,
+CONSTRAINT fk_post_id
+    FOREIGN KEY (post_id)
+    REFERENCES posts(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE podcast_episodes;
+DROP TABLE post_enclosures;
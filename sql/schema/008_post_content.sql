@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE posts
+ADD COLUMN content TEXT,
+ADD COLUMN author TEXT,
+ADD COLUMN categories TEXT[] NOT NULL DEFAULT '{}',
+ADD COLUMN comments_url TEXT;
+
+-- +goose Down
+ALTER TABLE posts
+DROP COLUMN content,
+DROP COLUMN author,
+DROP COLUMN categories,
+DROP COLUMN comments_url;
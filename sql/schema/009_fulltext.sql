@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN fulltext BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE posts
+ADD COLUMN extracted_content TEXT;
+
+-- +goose Down
+ALTER TABLE posts
+DROP COLUMN extracted_content;
+
+ALTER TABLE feeds
+DROP COLUMN fulltext;
@@ -0,0 +1,43 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN redirect_url TEXT,
+ADD COLUMN redirect_count INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN dead_at TIMESTAMP;
+
+CREATE TABLE feed_aliases (
+url TEXT PRIMARY KEY,
+created_at TIMESTAMP NOT NULL,
+feed_id UUID NOT NULL,
+CONSTRAINT fk_feed_id
+    FOREIGN KEY (feed_id)
+    REFERENCES feeds(id)
+    ON DELETE CASCADE
+);
+
+CREATE TABLE notifications (
+id UUID PRIMARY KEY,
+created_at TIMESTAMP NOT NULL,
+user_id UUID NOT NULL,
+feed_id UUID,
+message TEXT NOT NULL,
+read_at TIMESTAMP,
+CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+CONSTRAINT fk_feed_id
+    FOREIGN KEY (feed_id)
+    REFERENCES feeds(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX notifications_user_id_idx ON notifications (user_id, created_at);
+
+-- +goose Down
+DROP TABLE notifications;
+DROP TABLE feed_aliases;
+
+ALTER TABLE feeds
+DROP COLUMN redirect_url,
+DROP COLUMN redirect_count,
+DROP COLUMN dead_at;
@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE categories (
+id UUID PRIMARY KEY,
+created_at TIMESTAMP NOT NULL,
+updated_at TIMESTAMP NOT NULL,
+user_id UUID NOT NULL,
+parent_id UUID,
+name TEXT NOT NULL,
+CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+CONSTRAINT fk_parent_id
+    FOREIGN KEY (parent_id)
+    REFERENCES categories(id)
+    ON DELETE CASCADE
+);
+
+-- Top-level categories have no parent, and NULLs are never equal in a
+-- UNIQUE constraint.
+CREATE UNIQUE INDEX categories_user_parent_name_idx
+ON categories (user_id, COALESCE(parent_id, '00000000-0000-0000-0000-000000000000'), name);
+
+ALTER TABLE feed_follows
+ADD COLUMN category_id UUID,
+ADD CONSTRAINT fk_category_id
+    FOREIGN KEY (category_id)
+    REFERENCES categories(id)
+    ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE feed_follows
+DROP COLUMN category_id;
+
+DROP TABLE categories;
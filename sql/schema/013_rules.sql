@@ -0,0 +1,39 @@
+-- +goose Up
+CREATE TABLE rules (
+id UUID PRIMARY KEY,
+created_at TIMESTAMP NOT NULL,
+updated_at TIMESTAMP NOT NULL,
+user_id UUID NOT NULL,
+name TEXT NOT NULL,
+conditions TEXT[] NOT NULL,
+actions TEXT[] NOT NULL,
+UNIQUE (user_id, name),
+CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- What a user did with a post. A missing row means unread and not
+-- starred or hidden.
+CREATE TABLE post_states (
+user_id UUID NOT NULL,
+post_id UUID NOT NULL,
+updated_at TIMESTAMP NOT NULL,
+read_at TIMESTAMP,
+starred_at TIMESTAMP,
+hidden_at TIMESTAMP,
+PRIMARY KEY (user_id, post_id),
+CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+CONSTRAINT fk_post_id
+    FOREIGN KEY (post_id)
+    REFERENCES posts(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE post_states;
+DROP TABLE rules;
@@ -0,0 +1,53 @@
+-- +goose Up
+CREATE TABLE alerts (
+id UUID PRIMARY KEY,
+created_at TIMESTAMP NOT NULL,
+updated_at TIMESTAMP NOT NULL,
+user_id UUID NOT NULL,
+name TEXT NOT NULL,
+patterns TEXT[] NOT NULL,
+feed_id UUID,
+target_kind TEXT NOT NULL,
+target TEXT NOT NULL,
+secret TEXT,
+UNIQUE (user_id, name),
+CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+CONSTRAINT fk_feed_id
+    FOREIGN KEY (feed_id)
+    REFERENCES feeds(id)
+    ON DELETE CASCADE
+);
+
+-- One row per alert and post. status is pending until the delivery
+-- succeeds (delivered) or is given up on (failed).
+CREATE TABLE alert_deliveries (
+id UUID PRIMARY KEY,
+created_at TIMESTAMP NOT NULL,
+updated_at TIMESTAMP NOT NULL,
+alert_id UUID NOT NULL,
+post_id UUID NOT NULL,
+match TEXT NOT NULL,
+status TEXT NOT NULL DEFAULT 'pending',
+attempts INTEGER NOT NULL DEFAULT 0,
+last_error TEXT,
+next_attempt_at TIMESTAMP NOT NULL,
+delivered_at TIMESTAMP,
+UNIQUE (alert_id, post_id),
+CONSTRAINT fk_alert_id
+    FOREIGN KEY (alert_id)
+    REFERENCES alerts(id)
+    ON DELETE CASCADE,
+CONSTRAINT fk_post_id
+    FOREIGN KEY (post_id)
+    REFERENCES posts(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX alert_deliveries_pending_idx ON alert_deliveries (next_attempt_at) WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE alert_deliveries;
+DROP TABLE alerts;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- A user with a row here gets a digest of new posts by email every day at
+-- send_hour, in the time zone gator runs in.
+CREATE TABLE digest_schedules (
+user_id UUID PRIMARY KEY,
+created_at TIMESTAMP NOT NULL,
+updated_at TIMESTAMP NOT NULL,
+email TEXT NOT NULL,
+send_hour INTEGER NOT NULL,
+last_sent_at TIMESTAMP,
+CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE digest_schedules;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- The token is the secret part of the URL under which `gator serve`
+-- publishes a user's combined feed.
+CREATE TABLE feed_tokens (
+user_id UUID PRIMARY KEY,
+created_at TIMESTAMP NOT NULL,
+token TEXT UNIQUE NOT NULL,
+CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE feed_tokens;
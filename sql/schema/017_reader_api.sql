@@ -0,0 +1,22 @@
+-- +goose Up
+-- Google Reader clients identify items by a 64-bit integer rather than a
+-- UUID. Existing posts are numbered as the column is added.
+ALTER TABLE posts ADD COLUMN item_number BIGSERIAL;
+CREATE UNIQUE INDEX posts_item_number_idx ON posts (item_number);
+
+-- Reader API clients log in with a password, which gator otherwise has no
+-- use for. Only a PBKDF2 hash of it is kept.
+CREATE TABLE api_passwords (
+user_id UUID PRIMARY KEY,
+created_at TIMESTAMP NOT NULL,
+updated_at TIMESTAMP NOT NULL,
+password_hash TEXT NOT NULL,
+CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE api_passwords;
+ALTER TABLE posts DROP COLUMN item_number;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- The mode of the request sent to the hub that it hasn't verified yet.
+-- Verifications for anything else are refused.
+ALTER TABLE websub_subscriptions ADD COLUMN pending_mode TEXT;
+
+-- +goose Down
+ALTER TABLE websub_subscriptions DROP COLUMN pending_mode;
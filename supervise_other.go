@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "syscall"
+
+func childSysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build unix
+
+package main
+
+import "syscall"
+
+// childSysProcAttr puts the supervised child in its own process group so
+// a terminal Ctrl-C reaches it only once, via the supervisor.
+func childSysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setpgid: true}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jjboykin/gator/internal/database"
+	"github.com/jjboykin/gator/internal/websub"
+)
+
+const (
+	// websubLease is the lease we ask hubs for.
+	websubLease = 7 * 24 * time.Hour
+	// websubRenewBefore is how long before expiry a lease is renewed.
+	websubRenewBefore = 24 * time.Hour
+	// websubRetryAfter is how long to wait before retrying a hub that
+	// failed or never verified our subscription.
+	websubRetryAfter = time.Hour
+	// maxPushBytes limits the size of pushed content.
+	maxPushBytes = 10 << 20
+)
+
+// subscribeToHub subscribes to feed's WebSub hub, if it advertises one.
+// Nothing happens unless public_url is configured, since the hub has to
+// be able to reach `gator serve` to verify the subscription.
+func subscribeToHub(ctx context.Context, s *state, feed database.Feed) error {
+	if s.configPtr.PublicURL == "" {
+		return nil
+	}
+
+	fetched, _, err := fetchFeed(ctx, s.fetcher(), feed.Url)
+	if err != nil {
+		return err
+	}
+	hubs := fetched.links("hub")
+	if len(hubs) == 0 {
+		return nil
+	}
+	topic := feed.Url
+	if self := fetched.links("self"); len(self) > 0 {
+		topic = self[0]
+	}
+
+	sub, err := s.db.UpsertWebSubSubscription(ctx, database.UpsertWebSubSubscriptionParams{
+		ID:            uuid.New(),
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+		FeedID:        feed.ID,
+		HubUrl:        hubs[0],
+		TopicUrl:      topic,
+		Secret:        websub.NewSecret(),
+		Status:        "pending",
+		LastAttemptAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	return sendSubscription(ctx, s, sub)
+}
+
+// sendSubscription sends the subscription request for sub to its hub.
+// A hub that can't be reached marks the subscription failed, which puts
+// the feed back on the regular polling schedule.
+func sendSubscription(ctx context.Context, s *state, sub database.WebsubSubscription) error {
+	err := websub.Subscribe(ctx, s.fetcher().HTTPClient(), websub.Request{
+		Hub:      sub.HubUrl,
+		Topic:    sub.TopicUrl,
+		Callback: websubCallbackURL(s, sub.ID),
+		Secret:   sub.Secret,
+		Lease:    websubLease,
+	})
+	if err == nil {
+		return nil
+	}
+
+	statusErr := s.db.SetWebSubSubscriptionStatus(ctx, database.SetWebSubSubscriptionStatusParams{
+		ID:        sub.ID,
+		Status:    "failed",
+		LastError: sql.NullString{String: err.Error(), Valid: true},
+		UpdatedAt: time.Now(),
+	})
+	return errors.Join(fmt.Errorf("subscribing to hub %s: %w", sub.HubUrl, err), statusErr)
+}
+
+func websubCallbackURL(s *state, id uuid.UUID) string {
+	return strings.TrimRight(s.configPtr.PublicURL, "/") + "/websub/" + id.String()
+}
+
+// renewWebSubSubscriptions renews leases that are about to expire and
+// retries subscriptions that failed or were never verified.
+func renewWebSubSubscriptions(ctx context.Context, s *state) {
+	now := time.Now()
+	subs, err := s.db.GetWebSubSubscriptionsToRenew(ctx, database.GetWebSubSubscriptionsToRenewParams{
+		RetryBefore: now.Add(-websubRetryAfter),
+		RenewBefore: now.Add(websubRenewBefore),
+	})
+	if err != nil {
+		s.logger().Error("couldn't list websub subscriptions", "error", err)
+		return
+	}
+
+	for _, row := range subs {
+		sub, err := s.db.UpsertWebSubSubscription(ctx, database.UpsertWebSubSubscriptionParams{
+			ID:            row.ID,
+			CreatedAt:     row.CreatedAt,
+			UpdatedAt:     time.Now(),
+			FeedID:        row.FeedID,
+			HubUrl:        row.HubUrl,
+			TopicUrl:      row.TopicUrl,
+			Secret:        row.Secret,
+			Status:        "pending",
+			LastAttemptAt: sql.NullTime{Time: time.Now(), Valid: true},
+		})
+		if err != nil {
+			s.logger().Error("couldn't update websub subscription", "subscription_id", row.ID, "error", err)
+			continue
+		}
+		logger := s.logger().With("subscription_id", sub.ID, "feed_url", row.FeedUrl, "hub", sub.HubUrl)
+		if err := sendSubscription(ctx, s, sub); err != nil {
+			logger.Warn("websub subscription failed, falling back to polling", "error", err)
+			continue
+		}
+		logger.Info("websub subscription requested", "previous_status", row.Status)
+	}
+}
+
+func registerWebSubRoutes(mux *http.ServeMux, s *state) {
+	mux.HandleFunc("GET /websub/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handleWebSubVerification(w, r, s)
+	})
+	mux.HandleFunc("POST /websub/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handleWebSubContent(w, r, s)
+	})
+}
+
+func loadWebSubSubscription(r *http.Request, s *state) (database.WebsubSubscription, error) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		return database.WebsubSubscription{}, err
+	}
+	return s.db.GetWebSubSubscription(r.Context(), id)
+}
+
+// handleWebSubVerification answers the hub's intent verification by
+// echoing the challenge for requests we actually sent and that are still
+// pending.
+func handleWebSubVerification(w http.ResponseWriter, r *http.Request, s *state) {
+	sub, err := loadWebSubSubscription(r, s)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	v, err := websub.ParseVerification(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if v.Topic != sub.TopicUrl {
+		http.NotFound(w, r)
+		return
+	}
+	// Only a request we sent and haven't had verified can be confirmed.
+	// Hubs may deny a subscription at any time.
+	if v.Mode != "denied" && (!sub.PendingMode.Valid || sub.PendingMode.String != v.Mode) {
+		http.NotFound(w, r)
+		return
+	}
+
+	logger := s.logger().With("subscription_id", sub.ID, "hub", sub.HubUrl, "topic", sub.TopicUrl)
+	switch v.Mode {
+	case "subscribe":
+		lease := v.Lease
+		if lease <= 0 {
+			lease = websubLease
+		}
+		err = s.db.ActivateWebSubSubscription(r.Context(), database.ActivateWebSubSubscriptionParams{
+			ID:             sub.ID,
+			LeaseExpiresAt: sql.NullTime{Time: time.Now().Add(lease), Valid: true},
+			UpdatedAt:      time.Now(),
+		})
+		logger.Info("websub subscription verified", "lease", lease)
+	case "unsubscribe", "denied":
+		status := "unsubscribed"
+		if v.Mode == "denied" {
+			status = "denied"
+		}
+		err = s.db.SetWebSubSubscriptionStatus(r.Context(), database.SetWebSubSubscriptionStatusParams{
+			ID:        sub.ID,
+			Status:    status,
+			LastError: sql.NullString{String: v.Reason, Valid: v.Reason != ""},
+			UpdatedAt: time.Now(),
+		})
+		logger.Info("websub subscription ended", "mode", v.Mode, "reason", v.Reason)
+	}
+	if err != nil {
+		logger.Error("couldn't update websub subscription", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(v.Challenge))
+}
+
+// handleWebSubContent ingests content pushed by a hub through the same
+// path as polled feeds. Content with a bad signature is acknowledged but
+// dropped, as the spec requires.
+func handleWebSubContent(w http.ResponseWriter, r *http.Request, s *state) {
+	sub, err := loadWebSubSubscription(r, s)
+	if err != nil || sub.Status != "active" {
+		http.NotFound(w, r)
+		return
+	}
+	logger := s.logger().With("subscription_id", sub.ID, "feed_id", sub.FeedID)
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPushBytes+1))
+	if err != nil {
+		http.Error(w, "couldn't read body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxPushBytes {
+		http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+
+	if !websub.VerifySignature(r.Header.Get("X-Hub-Signature"), body, sub.Secret) {
+		logger.Warn("dropping websub content with invalid signature")
+		return
+	}
+
+	fetched, err := parseFeed(body, r.Header.Get("Content-Type"))
+	if err != nil {
+		logger.Warn("couldn't parse websub content", "error", err)
+		s.metrics.parseFailures.Inc()
+		return
+	}
+	feed, err := s.db.GetFeed(r.Context(), sub.FeedID)
+	if err != nil {
+		logger.Error("couldn't load feed for websub content", "error", err)
+		return
+	}
+
+	result := ingestFeed(context.WithoutCancel(r.Context()), s, feed, fetched, logger.With("feed_url", feed.Url))
+	logger.Info("websub content received",
+		"items", len(fetched.Channel.Item),
+		"inserted", result.Inserted,
+		"duplicates", result.Duplicates,
+		"failed", result.Failed,
+	)
+}
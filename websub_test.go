@@ -0,0 +1,294 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jjboykin/gator/internal/database"
+)
+
+const websubTopic = "https://example.com/feed.xml"
+
+// newWebSubTest serves the WebSub callbacks with sub as the only
+// subscription in the database.
+func newWebSubTest(t *testing.T, sub database.WebsubSubscription) (*fakeDB, *httptest.Server) {
+	t.Helper()
+	db := &fakeDB{}
+	db.onArgs("GetWebSubSubscription", func(args []driver.Value) []any {
+		if args[0] != sub.ID.String() {
+			return nil
+		}
+		return []any{sub}
+	})
+	s := newTestState(t, db)
+	s.metrics = newAggregatorMetrics()
+
+	mux := http.NewServeMux()
+	registerWebSubRoutes(mux, s)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return db, server
+}
+
+func websubRequest(t *testing.T, req *http.Request) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestWebSubVerification(t *testing.T) {
+	pending := func(mode string) sql.NullString { return sql.NullString{String: mode, Valid: true} }
+	for _, tt := range []struct {
+		name        string
+		pendingMode sql.NullString
+		// id replaces the subscription's ID in the callback URL.
+		id     string
+		query  url.Values
+		status int
+		// update is the query the verification runs, if any.
+		update string
+	}{
+		{
+			name:        "subscribe",
+			pendingMode: pending("subscribe"),
+			query:       url.Values{"hub.mode": {"subscribe"}, "hub.topic": {websubTopic}, "hub.challenge": {"abc"}, "hub.lease_seconds": {"3600"}},
+			status:      http.StatusOK,
+			update:      "ActivateWebSubSubscription",
+		},
+		{
+			name:        "unsubscribe",
+			pendingMode: pending("unsubscribe"),
+			query:       url.Values{"hub.mode": {"unsubscribe"}, "hub.topic": {websubTopic}, "hub.challenge": {"abc"}},
+			status:      http.StatusOK,
+			update:      "SetWebSubSubscriptionStatus",
+		},
+		{
+			// Hubs may deny a subscription whether or not one is pending.
+			name:   "denied",
+			query:  url.Values{"hub.mode": {"denied"}, "hub.topic": {websubTopic}, "hub.challenge": {"abc"}, "hub.reason": {"spam"}},
+			status: http.StatusOK,
+			update: "SetWebSubSubscriptionStatus",
+		},
+		{
+			name:   "subscribe without a pending request",
+			query:  url.Values{"hub.mode": {"subscribe"}, "hub.topic": {websubTopic}, "hub.challenge": {"abc"}},
+			status: http.StatusNotFound,
+		},
+		{
+			name:        "mode other than the pending one",
+			pendingMode: pending("subscribe"),
+			query:       url.Values{"hub.mode": {"unsubscribe"}, "hub.topic": {websubTopic}, "hub.challenge": {"abc"}},
+			status:      http.StatusNotFound,
+		},
+		{
+			name:        "other topic",
+			pendingMode: pending("subscribe"),
+			query:       url.Values{"hub.mode": {"subscribe"}, "hub.topic": {"https://example.com/other.xml"}, "hub.challenge": {"abc"}},
+			status:      http.StatusNotFound,
+		},
+		{
+			name:        "unknown subscription",
+			pendingMode: pending("subscribe"),
+			id:          uuid.NewString(),
+			query:       url.Values{"hub.mode": {"subscribe"}, "hub.topic": {websubTopic}, "hub.challenge": {"abc"}},
+			status:      http.StatusNotFound,
+		},
+		{
+			name:        "malformed subscription ID",
+			pendingMode: pending("subscribe"),
+			id:          "42",
+			query:       url.Values{"hub.mode": {"subscribe"}, "hub.topic": {websubTopic}, "hub.challenge": {"abc"}},
+			status:      http.StatusNotFound,
+		},
+		{
+			name:        "missing challenge",
+			pendingMode: pending("subscribe"),
+			query:       url.Values{"hub.mode": {"subscribe"}, "hub.topic": {websubTopic}},
+			status:      http.StatusBadRequest,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := database.WebsubSubscription{
+				ID:          uuid.New(),
+				FeedID:      uuid.New(),
+				HubUrl:      "https://hub.example.com/",
+				TopicUrl:    websubTopic,
+				Secret:      "secret",
+				Status:      "pending",
+				PendingMode: tt.pendingMode,
+			}
+			db, server := newWebSubTest(t, sub)
+			id := tt.id
+			if id == "" {
+				id = sub.ID.String()
+			}
+
+			req, err := http.NewRequest("GET", server.URL+"/websub/"+id+"?"+tt.query.Encode(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, body := websubRequest(t, req)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("verification = %s %q, want %d", resp.Status, body, tt.status)
+			}
+			if tt.status == http.StatusOK && body != "abc" {
+				t.Errorf("verification answered %q, want the challenge", body)
+			}
+
+			for _, update := range []string{"ActivateWebSubSubscription", "SetWebSubSubscriptionStatus"} {
+				runs := db.called(update)
+				if update != tt.update {
+					if len(runs) != 0 {
+						t.Errorf("%s ran with %v", update, runs)
+					}
+					continue
+				}
+				if len(runs) != 1 || runs[0][0] != sub.ID.String() {
+					t.Fatalf("%s ran with %v, want once for the subscription", update, runs)
+				}
+				switch tt.query.Get("hub.mode") {
+				case "subscribe":
+					expires := runs[0][1].(time.Time)
+					if until := time.Until(expires); until < 59*time.Minute || until > time.Hour {
+						t.Errorf("lease expires at %v, want an hour from now", expires)
+					}
+				case "unsubscribe":
+					if runs[0][1] != "unsubscribed" || runs[0][2] != nil {
+						t.Errorf("status set with %v, want unsubscribed without an error", runs[0])
+					}
+				case "denied":
+					if runs[0][1] != "denied" || runs[0][2] != "spam" {
+						t.Errorf("status set with %v, want denied for spam", runs[0])
+					}
+				}
+			}
+		})
+	}
+}
+
+// signWebSub returns the X-Hub-Signature a hub sends for body.
+func signWebSub(secret, body string) string {
+	return "sha256=" + hmacHex(sha256.New, secret, body)
+}
+
+func hmacHex(h func() hash.Hash, secret, body string) string {
+	mac := hmac.New(h, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebSubContent(t *testing.T) {
+	const push = `<rss version="2.0"><channel><title>Example</title>
+<item><title>Pushed</title><link>https://example.com/pushed</link></item>
+</channel></rss>`
+
+	for _, tt := range []struct {
+		name      string
+		status    string
+		id        string
+		body      string
+		signature string
+		code      int
+		// ingested is whether the content reaches the feed's posts.
+		ingested bool
+	}{
+		{name: "signed content", status: "active", body: push, signature: signWebSub("secret", push), code: http.StatusAccepted, ingested: true},
+		{name: "SHA-1 signature", status: "active", body: push, signature: "sha1=" + hmacHex(sha1.New, "secret", push), code: http.StatusAccepted, ingested: true},
+		{name: "no signature", status: "active", body: push, code: http.StatusAccepted},
+		{name: "other secret", status: "active", body: push, signature: signWebSub("guess", push), code: http.StatusAccepted},
+		{name: "signature of other content", status: "active", body: push, signature: signWebSub("secret", push+" "), code: http.StatusAccepted},
+		{name: "unknown hash", status: "active", body: push, signature: "md5=00", code: http.StatusAccepted},
+		{name: "malformed signature", status: "active", body: push, signature: "sha256=xyz", code: http.StatusAccepted},
+		{name: "unparseable content", status: "active", body: "not a feed", signature: signWebSub("secret", "not a feed"), code: http.StatusAccepted},
+		{name: "inactive subscription", status: "pending", body: push, signature: signWebSub("secret", push), code: http.StatusNotFound},
+		{name: "unknown subscription", status: "active", id: uuid.NewString(), body: push, signature: signWebSub("secret", push), code: http.StatusNotFound},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := database.WebsubSubscription{
+				ID:       uuid.New(),
+				FeedID:   uuid.New(),
+				HubUrl:   "https://hub.example.com/",
+				TopicUrl: websubTopic,
+				Secret:   "secret",
+				Status:   tt.status,
+			}
+			db, server := newWebSubTest(t, sub)
+			feed := database.Feed{ID: sub.FeedID, Url: websubTopic}
+			db.onArgs("GetFeed", func(args []driver.Value) []any {
+				if args[0] != feed.ID.String() {
+					return nil
+				}
+				return []any{feed}
+			})
+			db.onArgs("CreatePost", func(args []driver.Value) []any {
+				return []any{database.Post{ID: uuid.New(), FeedID: feed.ID, Url: args[4].(string)}}
+			})
+			id := tt.id
+			if id == "" {
+				id = sub.ID.String()
+			}
+
+			req, err := http.NewRequest("POST", server.URL+"/websub/"+id, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/rss+xml")
+			if tt.signature != "" {
+				req.Header.Set("X-Hub-Signature", tt.signature)
+			}
+			resp, body := websubRequest(t, req)
+			if resp.StatusCode != tt.code {
+				t.Fatalf("push = %s %q, want %d", resp.Status, body, tt.code)
+			}
+
+			posts := db.called("CreatePost")
+			if !tt.ingested {
+				if len(posts) != 0 {
+					t.Errorf("content stored as %d posts, want it dropped", len(posts))
+				}
+				return
+			}
+			if len(posts) != 1 || posts[0][4] != "https://example.com/pushed" || posts[0][7] != feed.ID.String() {
+				t.Errorf("CreatePost ran with %v, want the pushed item in the subscribed feed", posts)
+			}
+		})
+	}
+}
+
+func TestWebSubContentTooLarge(t *testing.T) {
+	sub := database.WebsubSubscription{ID: uuid.New(), FeedID: uuid.New(), TopicUrl: websubTopic, Secret: "secret", Status: "active"}
+	db, server := newWebSubTest(t, sub)
+	body := strings.Repeat(" ", maxPushBytes+1)
+	req, err := http.NewRequest("POST", server.URL+"/websub/"+sub.ID.String(), strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Hub-Signature", signWebSub("secret", body))
+	if resp, _ := websubRequest(t, req); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("push of %d bytes = %s, want 413", len(body), resp.Status)
+	}
+	if runs := db.called("GetFeed"); len(runs) != 0 {
+		t.Errorf("oversized content was ingested")
+	}
+}